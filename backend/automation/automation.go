@@ -0,0 +1,181 @@
+// Package automation decides which "when X then Y" rules of a board run on
+// a card and keeps rules that trigger each other from looping. Taking the
+// actions is left to the handlers, which own recording and broadcasting
+// changes.
+package automation
+
+import (
+	"errors"
+	"fmt"
+	"kerjainaja/model"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TriggerCardCreated   = "card_created"
+	TriggerCardMoved     = "card_moved"
+	TriggerLabelAdded    = "label_added"
+	TriggerDueDatePassed = "due_date_passed"
+)
+
+var Triggers = []string{TriggerCardCreated, TriggerCardMoved, TriggerLabelAdded, TriggerDueDatePassed}
+
+const (
+	ActionAssignUser  = "assign_user"
+	ActionSetDueDate  = "set_due_date"
+	ActionAddLabel    = "add_label"
+	ActionMoveCard    = "move_card"
+	ActionPostComment = "post_comment"
+	ActionArchive     = "archive"
+)
+
+var Actions = []string{ActionAssignUser, ActionSetDueDate, ActionAddLabel, ActionMoveCard, ActionPostComment, ActionArchive}
+
+// MaxDepth is how many rules can run one after another because of a single
+// change.
+const MaxDepth = 5
+
+// TriggerOf is the trigger a card activity fires, if any.
+func TriggerOf(activity string) (string, bool) {
+	switch activity {
+	case "created":
+		return TriggerCardCreated, true
+	case "moved":
+		return TriggerCardMoved, true
+	case "label_added":
+		return TriggerLabelAdded, true
+	}
+
+	return "", false
+}
+
+// Validate checks that a rule is complete. Whether the columns, labels and
+// users it names are on the board is up to the caller.
+func Validate(rule model.AutomationRule) error {
+	if !slices.Contains(Triggers, rule.Trigger.Type) {
+		return fmt.Errorf("trigger must be one of %s", strings.Join(Triggers, ", "))
+	}
+
+	if rule.Trigger.ColumnID != nil && rule.Trigger.Type != TriggerCardCreated && rule.Trigger.Type != TriggerCardMoved {
+		return errors.New("only card_created and card_moved triggers take a column")
+	}
+
+	if rule.Trigger.LabelID != nil && rule.Trigger.Type != TriggerLabelAdded {
+		return errors.New("only label_added triggers take a label")
+	}
+
+	if len(rule.Actions) == 0 {
+		return errors.New("a rule needs at least one action")
+	}
+
+	for i, action := range rule.Actions {
+		if err := validateAction(action); err != nil {
+			return fmt.Errorf("action %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func validateAction(action model.AutomationAction) error {
+	switch action.Type {
+	case ActionAssignUser:
+		if action.UserID == nil {
+			return errors.New("user_id is required")
+		}
+	case ActionSetDueDate:
+		if action.DueInDays != nil && (*action.DueInDays < 0 || *action.DueInDays > 3650) {
+			return errors.New("due_in_days must be between 0 and 3650")
+		}
+	case ActionAddLabel:
+		if action.LabelID == nil {
+			return errors.New("label_id is required")
+		}
+	case ActionMoveCard:
+		if action.ColumnID == nil {
+			return errors.New("column_id is required")
+		}
+	case ActionPostComment:
+		if strings.TrimSpace(action.Comment) == "" || len(action.Comment) > 2000 {
+			return errors.New("comment must be 1 to 2000 characters")
+		}
+	case ActionArchive:
+	default:
+		return fmt.Errorf("type must be one of %s", strings.Join(Actions, ", "))
+	}
+
+	return nil
+}
+
+// Event is a trigger happening to a card. Card is its state after the
+// change, loaded with labels and members. LabelIDs are the labels a
+// label_added event added.
+type Event struct {
+	Trigger  string
+	Card     model.Card
+	LabelIDs []uuid.UUID
+}
+
+// Matches reports whether a rule runs for an event.
+func Matches(rule model.AutomationRule, event Event) bool {
+	if !rule.Enabled || rule.Trigger.Type != event.Trigger {
+		return false
+	}
+
+	if rule.Trigger.ColumnID != nil && *rule.Trigger.ColumnID != event.Card.ColumnID {
+		return false
+	}
+
+	if rule.Trigger.LabelID != nil && !slices.Contains(event.LabelIDs, *rule.Trigger.LabelID) {
+		return false
+	}
+
+	for _, id := range rule.Conditions.LabelIDs {
+		if !slices.ContainsFunc(event.Card.Labels, func(l model.Label) bool { return l.ID == id }) {
+			return false
+		}
+	}
+
+	for _, id := range rule.Conditions.MemberIDs {
+		if !slices.ContainsFunc(event.Card.Members, func(u model.User) bool { return u.ID == id }) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Chain follows the rules run because of one change. A rule runs at most
+// once per card in a chain and a chain stops at MaxDepth, so rules that
+// trigger each other can't loop.
+type Chain struct {
+	Depth int
+	ran   map[[2]uuid.UUID]bool
+}
+
+func NewChain() *Chain {
+	return &Chain{ran: map[[2]uuid.UUID]bool{}}
+}
+
+// Next is the chain for the changes made by the rules of this one.
+func (c *Chain) Next() *Chain {
+	return &Chain{Depth: c.Depth + 1, ran: c.ran}
+}
+
+// Enter marks a rule as running on a card, or says why it may not.
+func (c *Chain) Enter(ruleID, cardID uuid.UUID) error {
+	if c.Depth >= MaxDepth {
+		return fmt.Errorf("stopped after %d rules in a row", MaxDepth)
+	}
+
+	key := [2]uuid.UUID{ruleID, cardID}
+	if c.ran[key] {
+		return errors.New("rule already ran on this card in the same chain")
+	}
+	c.ran[key] = true
+
+	return nil
+}
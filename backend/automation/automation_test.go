@@ -0,0 +1,110 @@
+package automation
+
+import (
+	"kerjainaja/model"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMatches(t *testing.T) {
+	todo, done := uuid.New(), uuid.New()
+	bug, urgent := model.Label{ID: uuid.New()}, model.Label{ID: uuid.New()}
+	alice := model.User{ID: uuid.New()}
+
+	card := model.Card{ColumnID: done, Labels: []model.Label{bug}, Members: []model.User{alice}}
+	rule := model.AutomationRule{
+		Enabled:    true,
+		Trigger:    model.AutomationTrigger{Type: TriggerCardMoved, ColumnID: &done},
+		Conditions: model.AutomationConditions{LabelIDs: []uuid.UUID{bug.ID}, MemberIDs: []uuid.UUID{alice.ID}},
+	}
+
+	if !Matches(rule, Event{Trigger: TriggerCardMoved, Card: card}) {
+		t.Error("rule does not match a card moved into its column")
+	}
+
+	if Matches(rule, Event{Trigger: TriggerCardCreated, Card: card}) {
+		t.Error("rule matches another trigger")
+	}
+
+	moved := card
+	moved.ColumnID = todo
+	if Matches(rule, Event{Trigger: TriggerCardMoved, Card: moved}) {
+		t.Error("rule matches a card moved into another column")
+	}
+
+	rule.Conditions.LabelIDs = append(rule.Conditions.LabelIDs, urgent.ID)
+	if Matches(rule, Event{Trigger: TriggerCardMoved, Card: card}) {
+		t.Error("rule matches a card missing a label")
+	}
+
+	labelRule := model.AutomationRule{Enabled: true, Trigger: model.AutomationTrigger{Type: TriggerLabelAdded, LabelID: &urgent.ID}}
+	if Matches(labelRule, Event{Trigger: TriggerLabelAdded, Card: card, LabelIDs: []uuid.UUID{bug.ID}}) {
+		t.Error("label rule matches another label")
+	}
+	if !Matches(labelRule, Event{Trigger: TriggerLabelAdded, Card: card, LabelIDs: []uuid.UUID{urgent.ID}}) {
+		t.Error("label rule does not match its label")
+	}
+
+	labelRule.Enabled = false
+	if Matches(labelRule, Event{Trigger: TriggerLabelAdded, Card: card, LabelIDs: []uuid.UUID{urgent.ID}}) {
+		t.Error("disabled rule matches")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	days := -1
+	column := uuid.New()
+
+	cases := []model.AutomationRule{
+		{Trigger: model.AutomationTrigger{Type: "card_deleted"}, Actions: []model.AutomationAction{{Type: ActionArchive}}},
+		{Trigger: model.AutomationTrigger{Type: TriggerDueDatePassed, ColumnID: &column}, Actions: []model.AutomationAction{{Type: ActionArchive}}},
+		{Trigger: model.AutomationTrigger{Type: TriggerCardCreated}},
+		{Trigger: model.AutomationTrigger{Type: TriggerCardCreated}, Actions: []model.AutomationAction{{Type: ActionMoveCard}}},
+		{Trigger: model.AutomationTrigger{Type: TriggerCardCreated}, Actions: []model.AutomationAction{{Type: ActionSetDueDate, DueInDays: &days}}},
+		{Trigger: model.AutomationTrigger{Type: TriggerCardCreated}, Actions: []model.AutomationAction{{Type: ActionPostComment, Comment: " "}}},
+	}
+
+	for i, rule := range cases {
+		if err := Validate(rule); err == nil {
+			t.Errorf("case %d was accepted", i)
+		}
+	}
+
+	valid := model.AutomationRule{
+		Trigger: model.AutomationTrigger{Type: TriggerCardMoved, ColumnID: &column},
+		Actions: []model.AutomationAction{{Type: ActionSetDueDate}, {Type: ActionPostComment, Comment: "moved"}},
+	}
+	if err := Validate(valid); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestChainStopsLoops(t *testing.T) {
+	rule, other, card := uuid.New(), uuid.New(), uuid.New()
+
+	chain := NewChain()
+	if err := chain.Enter(rule, card); err != nil {
+		t.Fatal(err)
+	}
+
+	next := chain.Next()
+	if err := next.Enter(rule, card); err == nil {
+		t.Error("rule ran twice on a card in one chain")
+	}
+	if err := next.Enter(other, card); err != nil {
+		t.Error(err)
+	}
+
+	if err := NewChain().Enter(rule, card); err != nil {
+		t.Error("a new chain remembers the rules of another one")
+	}
+
+	deep := NewChain()
+	for range MaxDepth {
+		deep = deep.Next()
+	}
+	if err := deep.Enter(uuid.New(), card); err == nil {
+		t.Error("chain went deeper than MaxDepth")
+	}
+}
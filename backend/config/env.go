@@ -1,9 +1,12 @@
 package config
 
 import (
+	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -13,8 +16,9 @@ func LoadEnv() {
 	basePath := filepath.Join(filepath.Dir(b), "..")
 	envPath := filepath.Join(basePath, ".env")
 
+	// without a .env file the process environment is used as it is
 	err := godotenv.Load(envPath)
-	if err != nil {
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		panic(err)
 	}
 }
@@ -24,3 +28,14 @@ func Env(key string) string {
 
 	return os.Getenv(key)
 }
+
+// Duration reads a duration like "15m" from the environment, returning
+// fallback when the variable is empty or not a valid duration.
+func Duration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(Env(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+
+	return value
+}
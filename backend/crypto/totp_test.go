@@ -0,0 +1,68 @@
+package crypto
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// test vectors from RFC 6238 appendix B for the SHA-1 key, cut to 6 digits
+func TestTOTPCode(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for unix, expected := range vectors {
+		code, err := TOTPCode(secret, TOTPStep(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if code != expected {
+			t.Errorf("at %d: expected %s, got %s", unix, expected, code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	previous, _ := TOTPCode(secret, TOTPStep(now)-1)
+	tooOld, _ := TOTPCode(secret, TOTPStep(now)-3)
+
+	if step, ok := ValidateTOTP(secret, previous, now); !ok || step != TOTPStep(now)-1 {
+		t.Errorf("expected the previous code to be accepted for clock drift")
+	}
+
+	if _, ok := ValidateTOTP(secret, tooOld, now); ok {
+		t.Errorf("expected an old code to be rejected")
+	}
+
+	if _, ok := ValidateTOTP(secret, "12345", now); ok {
+		t.Errorf("expected a short code to be rejected")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("kerjainaja", "alice@example.com", "JBSWY3DPEHPK3PXP")
+
+	if !strings.HasPrefix(uri, "otpauth://totp/kerjainaja:alice@example.com?") {
+		t.Errorf("unexpected uri %s", uri)
+	}
+
+	if !strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") || !strings.Contains(uri, "issuer=kerjainaja") {
+		t.Errorf("uri is missing the secret or issuer: %s", uri)
+	}
+}
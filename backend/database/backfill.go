@@ -0,0 +1,165 @@
+package database
+
+import (
+	"kerjainaja/helpers"
+	"kerjainaja/model"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// backfillCardKeys gives boards made before card keys existed a prefix and
+// numbers their cards in the order they were created.
+func backfillCardKeys(db *gorm.DB) error {
+	var boards []model.Board
+	if err := db.Select("id", "name").Where("key_prefix = ''").Find(&boards).Error; err != nil {
+		return err
+	}
+
+	for _, board := range boards {
+		if err := db.Model(&board).UpdateColumn("key_prefix", model.DefaultKeyPrefix(board.Name)).Error; err != nil {
+			return err
+		}
+	}
+
+	var boardIDs []uuid.UUID
+	if err := db.Table("cards").
+		Joins("JOIN columns ON columns.id = cards.column_id").
+		Where("cards.number = 0").
+		Distinct().
+		Pluck("columns.board_id", &boardIDs).Error; err != nil {
+		return err
+	}
+
+	for _, boardID := range boardIDs {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var board model.Board
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&board, "id = ?", boardID).Error; err != nil {
+				return err
+			}
+
+			var cardIDs []uuid.UUID
+			if err := tx.Table("cards").
+				Joins("JOIN columns ON columns.id = cards.column_id").
+				Where("columns.board_id = ? AND cards.number = 0", boardID).
+				Order("cards.created_at, cards.id").
+				Pluck("cards.id", &cardIDs).Error; err != nil {
+				return err
+			}
+
+			for _, id := range cardIDs {
+				board.CardCounter++
+				if err := tx.Model(&model.Card{}).Where("id = ?", id).UpdateColumn("number", board.CardCounter).Error; err != nil {
+					return err
+				}
+			}
+
+			return tx.Model(&board).UpdateColumn("card_counter", board.CardCounter).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// backfillBoardOwners gives boards made before boards had owners one, so
+// their settings can be managed. The members table doesn't say who joined
+// first, so the owner is whoever the activity log says created the board,
+// or else the member whose account is oldest, which the creator's has to
+// be unless they joined with an older one later.
+func backfillBoardOwners(db *gorm.DB) error {
+	var boardIDs []uuid.UUID
+	if err := db.Model(&model.Board{}).Where("owner_id IS NULL").Pluck("id", &boardIDs).Error; err != nil {
+		return err
+	}
+
+	for _, boardID := range boardIDs {
+		var ownerIDs []uuid.UUID
+		err := db.Table("activities").
+			Joins("JOIN board_members ON board_members.board_id = activities.board_id AND board_members.user_id = activities.actor_id").
+			Where("activities.board_id = ? AND activities.entity_type = ? AND activities.action = ?", boardID, "board", "created").
+			Order("activities.created_at").
+			Limit(1).
+			Pluck("activities.actor_id", &ownerIDs).Error
+		if err != nil {
+			return err
+		}
+
+		if len(ownerIDs) == 0 {
+			err := db.Table("users").
+				Joins("JOIN board_members ON board_members.user_id = users.id").
+				Where("board_members.board_id = ?", boardID).
+				Order("users.created_at, users.id").
+				Limit(1).
+				Pluck("users.id", &ownerIDs).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		// a board nobody is a member of stays without an owner
+		if len(ownerIDs) == 0 {
+			continue
+		}
+
+		if err := db.Model(&model.Board{}).Where("id = ? AND owner_id IS NULL", boardID).UpdateColumn("owner_id", ownerIDs[0]).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// convertDueDates readies cards.due_date for its change from free text to a
+// datetime. It runs before AutoMigrate, which can't alter the column while
+// it holds values MySQL won't read as a date. Empty and unparsable dates are
+// cleared, the rest are rewritten in the format MySQL expects.
+func convertDueDates(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&model.Card{}) {
+		return nil
+	}
+
+	columns, err := db.Migrator().ColumnTypes(&model.Card{})
+	if err != nil {
+		return err
+	}
+
+	text := false
+	for _, c := range columns {
+		if c.Name() == "due_date" {
+			kind := strings.ToLower(c.DatabaseTypeName())
+			text = strings.Contains(kind, "text") || strings.Contains(kind, "char")
+		}
+	}
+	if !text {
+		return nil
+	}
+
+	var cards []struct {
+		ID      string
+		DueDate *string
+	}
+	if err := db.Table("cards").Select("id", "due_date").Where("due_date IS NOT NULL").Find(&cards).Error; err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, card := range cards {
+			var value any
+			if due, err := helpers.ParseDate(strings.TrimSpace(*card.DueDate)); err == nil && due != nil {
+				value = due.In(time.Local).Format(time.DateTime)
+			}
+
+			if err := tx.Table("cards").Where("id = ?", card.ID).UpdateColumn("due_date", value).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
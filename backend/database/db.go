@@ -1,9 +1,12 @@
 package database
 
 import (
+	"errors"
 	"fmt"
 	"kerjainaja/config"
 	"kerjainaja/model"
+	"kerjainaja/search"
+	"log"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -11,6 +14,12 @@ import (
 
 var DB *gorm.DB
 
+// Search is the full-text search backend matching the database driver.
+var Search search.Engine = search.MySQL{}
+
+// Models are the tables AutoMigrate keeps up to date.
+var Models = []any{&model.User{}, &model.Board{}, &model.Column{}, &model.Card{}, &model.Label{}, &model.Activity{}, &model.SavedFilter{}, &model.PersonalAccessToken{}, &model.Session{}, &model.UserToken{}, &model.RecoveryCode{}, &model.LoginThrottle{}, &model.AuditLog{}, &model.UserIdentity{}, &model.Workspace{}, &model.WorkspaceMember{}, &model.BoardTemplate{}, &model.Comment{}, &model.CalendarFeed{}, &model.Webhook{}, &model.WebhookDelivery{}, &model.InboundWebhook{}, &model.InboundCard{}, &model.GitIntegration{}, &model.CardCommit{}, &model.AutomationRule{}, &model.AutomationRun{}}
+
 func InitDB() {
 	DB_HOST := config.Env("DB_HOST")
 	DB_DATABASE := config.Env("DB_DATABASE")
@@ -28,5 +37,46 @@ func InitDB() {
 
 	DB = db
 
-	DB.AutoMigrate(&model.User{}, &model.Board{}, &model.Column{}, &model.Card{})
+	if err := convertDueDates(DB); err != nil {
+		panic(err)
+	}
+
+	if err := DB.AutoMigrate(Models...); err != nil {
+		panic(err)
+	}
+
+	if err := Search.Migrate(DB); err != nil {
+		panic(err)
+	}
+
+	if err := backfillCardKeys(DB); err != nil {
+		panic(err)
+	}
+
+	if err := backfillBoardOwners(DB); err != nil {
+		panic(err)
+	}
+
+	if email := config.Env("ADMIN_EMAIL"); email != "" {
+		if err := bootstrapAdmin(DB, email); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// bootstrapAdmin makes the account with ADMIN_EMAIL the first admin, later ones
+// are promoted through the admin api. The email has to be verified, or whoever
+// registers the address first would get the role.
+func bootstrapAdmin(db *gorm.DB, email string) error {
+	var user model.User
+	err := db.Where("email = ? AND email_verified_at IS NOT NULL", email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("admin: no verified account has the ADMIN_EMAIL %s, nobody was made admin", email)
+		return nil
+	}
+	if err != nil || user.IsAdmin() {
+		return err
+	}
+
+	return db.Model(&user).Update("role", model.RoleAdmin).Error
 }
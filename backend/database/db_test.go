@@ -0,0 +1,51 @@
+package database
+
+import (
+	"kerjainaja/model"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestBootstrapAdminNeedsVerifiedEmail(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := `CREATE TABLE users (id TEXT PRIMARY KEY, name TEXT, username TEXT, email TEXT, password TEXT, role TEXT DEFAULT 'user',
+		email_verified_at DATETIME, pending_email TEXT, totp_secret TEXT, totp_enabled BOOLEAN, totp_last_step INTEGER, disabled_at DATETIME,
+		avatar_url TEXT, timezone TEXT, locale TEXT, created_at DATETIME, updated_at DATETIME)`
+	if err := db.Exec(users).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	squatter := model.User{Name: "Squatter", Username: "squatter", Email: "admin@example.com", Password: "x"}
+	if err := db.Create(&squatter).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bootstrapAdmin(db, "admin@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	db.First(&squatter, "id = ?", squatter.ID)
+	if squatter.IsAdmin() {
+		t.Fatal("expected an unverified account not to be made admin")
+	}
+
+	now := time.Now()
+	db.Model(&squatter).Update("email_verified_at", now)
+
+	if err := bootstrapAdmin(db, "admin@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	db.First(&squatter, "id = ?", squatter.ID)
+	if !squatter.IsAdmin() {
+		t.Error("expected the verified account to be made admin")
+	}
+}
@@ -0,0 +1,231 @@
+// Package export converts boards to and from a versioned JSON document used
+// for backups and for moving a board between installations.
+package export
+
+import (
+	"errors"
+	"fmt"
+	"kerjainaja/model"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Version is the document format written by Build. Bump it on incompatible
+// changes and keep Validate accepting the versions Import can still read.
+const Version = 1
+
+type Document struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Board      Board     `json:"board"`
+}
+
+type Board struct {
+	Name       string   `json:"name"`
+	Visibility string   `json:"visibility,omitempty"`
+	Members    []Member `json:"members"`
+	Labels     []Label  `json:"labels"`
+	Columns    []Column `json:"columns"`
+}
+
+// Member refers to a user by username, ids are never exported since they
+// mean nothing on another installation.
+type Member struct {
+	Username string `json:"username"`
+	Name     string `json:"name,omitempty"`
+	Owner    bool   `json:"owner,omitempty"`
+}
+
+type Label struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+type Column struct {
+	Name     string `json:"name"`
+	WIPLimit int    `json:"wip_limit,omitempty"`
+	WIPMode  string `json:"wip_mode,omitempty"`
+	Cards    []Card `json:"cards"`
+}
+
+type Card struct {
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	DueDate     *time.Time   `json:"due_date,omitempty"`
+	Archived    bool         `json:"archived,omitempty"`
+	Labels      []string     `json:"labels,omitempty"`
+	Members     []string     `json:"members,omitempty"`
+	Watchers    []string     `json:"watchers,omitempty"`
+	Comments    []Comment    `json:"comments,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+type Comment struct {
+	Author    string    `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Attachment is part of the format so documents from installations that
+// store attachments can be read. kerjainaja has no attachment storage yet,
+// exports leave it empty and imports skip it.
+type Attachment struct {
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+}
+
+// Build exports a board loaded with its members, columns, cards and their
+// members, watchers, labels and comments. Columns and cards keep the order
+// they were created in.
+func Build(board model.Board, labels []model.Label, now time.Time) Document {
+	doc := Document{
+		Version:    Version,
+		ExportedAt: now.UTC(),
+		Board: Board{
+			Name:       board.Name,
+			Visibility: board.Visibility,
+			Members:    make([]Member, 0, len(board.Members)),
+			Labels:     make([]Label, 0, len(labels)),
+			Columns:    make([]Column, 0, len(board.Columns)),
+		},
+	}
+
+	for _, m := range board.Members {
+		doc.Board.Members = append(doc.Board.Members, Member{
+			Username: m.Username,
+			Name:     m.Name,
+			Owner:    board.OwnerID != nil && *board.OwnerID == m.ID,
+		})
+	}
+
+	for _, l := range labels {
+		doc.Board.Labels = append(doc.Board.Labels, Label{Name: l.Name, Color: l.Color})
+	}
+
+	columns := slices.Clone(board.Columns)
+	slices.SortStableFunc(columns, func(a, b model.Column) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	for _, col := range columns {
+		column := Column{Name: col.Name, Cards: make([]Card, 0, len(col.Cards))}
+		if col.WIPLimit > 0 {
+			column.WIPLimit = col.WIPLimit
+			column.WIPMode = col.WIPMode
+		}
+
+		cards := slices.Clone(col.Cards)
+		slices.SortStableFunc(cards, func(a, b model.Card) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+		for _, c := range cards {
+			card := Card{
+				Title:       c.Title,
+				Description: c.Description,
+				DueDate:     c.DueDate,
+				Archived:    c.Archived,
+				CreatedAt:   c.CreatedAt.UTC(),
+			}
+
+			for _, l := range c.Labels {
+				card.Labels = append(card.Labels, l.Name)
+			}
+			for _, m := range c.Members {
+				card.Members = append(card.Members, m.Username)
+			}
+			for _, w := range c.Watchers {
+				card.Watchers = append(card.Watchers, w.Username)
+			}
+
+			comments := slices.Clone(c.Comments)
+			slices.SortStableFunc(comments, func(a, b model.Comment) int { return a.CreatedAt.Compare(b.CreatedAt) })
+			for _, cm := range comments {
+				card.Comments = append(card.Comments, Comment{Author: cm.AuthorUsername, Body: cm.Body, CreatedAt: cm.CreatedAt.UTC()})
+			}
+
+			column.Cards = append(column.Cards, card)
+		}
+
+		doc.Board.Columns = append(doc.Board.Columns, column)
+	}
+
+	return doc
+}
+
+// Validate checks a document before anything is imported, so a broken file
+// doesn't leave half a board behind.
+func (d Document) Validate() error {
+	if d.Version < 1 || d.Version > Version {
+		return fmt.Errorf("document version %d is not supported, expected 1 to %d", d.Version, Version)
+	}
+
+	if strings.TrimSpace(d.Board.Name) == "" {
+		return errors.New("board name is missing")
+	}
+
+	if v := d.Board.Visibility; v != "" && v != model.VisibilityPrivate && v != model.VisibilityWorkspace {
+		return fmt.Errorf("board visibility %q is not valid", v)
+	}
+
+	labels := make(map[string]bool, len(d.Board.Labels))
+	for i, l := range d.Board.Labels {
+		if strings.TrimSpace(l.Name) == "" {
+			return fmt.Errorf("label %d has no name", i+1)
+		}
+		if labels[l.Name] {
+			return fmt.Errorf("label %q is listed twice", l.Name)
+		}
+		labels[l.Name] = true
+	}
+
+	for i, col := range d.Board.Columns {
+		if strings.TrimSpace(col.Name) == "" {
+			return fmt.Errorf("column %d has no name", i+1)
+		}
+
+		for j, card := range col.Cards {
+			if strings.TrimSpace(card.Title) == "" {
+				return fmt.Errorf("card %d of column %q has no title", j+1, col.Name)
+			}
+
+			for _, name := range card.Labels {
+				if !labels[name] {
+					return fmt.Errorf("card %q uses unknown label %q", card.Title, name)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Usernames lists every username the document refers to, for resolving them
+// to users before Import.
+func (d Document) Usernames() []string {
+	var names []string
+	add := func(name string) {
+		if name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	for _, m := range d.Board.Members {
+		add(m.Username)
+	}
+	for _, col := range d.Board.Columns {
+		for _, card := range col.Cards {
+			for _, name := range card.Members {
+				add(name)
+			}
+			for _, name := range card.Watchers {
+				add(name)
+			}
+			for _, c := range card.Comments {
+				add(c.Author)
+			}
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,111 @@
+package export
+
+import (
+	"encoding/json"
+	"kerjainaja/model"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func testBoard() (model.Board, []model.Label) {
+	now := time.Date(2025, 5, 1, 9, 0, 0, 0, time.UTC)
+	alice := model.User{ID: uuid.New(), Username: "alice", Name: "Alice"}
+	bob := model.User{ID: uuid.New(), Username: "bob", Name: "Bob"}
+	bug := model.Label{ID: uuid.New(), Name: "bug", Color: "red"}
+
+	board := model.Board{
+		Name:    "Roadmap",
+		OwnerID: &alice.ID,
+		Members: []model.User{alice, bob},
+		Columns: []model.Column{
+			{Name: "Done", WIPLimit: 3, WIPMode: model.WIPHard, CreatedAt: now.Add(time.Minute), Cards: []model.Card{
+				{Title: "second", CreatedAt: now.Add(2 * time.Minute)},
+				{Title: "first", CreatedAt: now.Add(time.Minute), Members: []model.User{bob}, Watchers: []model.User{alice}, Labels: []model.Label{bug},
+					Comments: []model.Comment{{AuthorUsername: "bob", Body: "shipped", CreatedAt: now}}},
+			}},
+			{Name: "Todo", CreatedAt: now},
+		},
+	}
+
+	return board, []model.Label{bug}
+}
+
+func TestBuildKeepsOrderAndUsesUsernames(t *testing.T) {
+	board, labels := testBoard()
+	doc := Build(board, labels, time.Now())
+
+	if doc.Version != Version {
+		t.Fatalf("version = %d, want %d", doc.Version, Version)
+	}
+
+	if doc.Board.Columns[0].Name != "Todo" || doc.Board.Columns[1].Name != "Done" {
+		t.Errorf("columns are not in creation order: %+v", doc.Board.Columns)
+	}
+
+	if done := doc.Board.Columns[1]; done.WIPLimit != 3 || done.WIPMode != model.WIPHard {
+		t.Errorf("wip limit = %d %q, want 3 hard", done.WIPLimit, done.WIPMode)
+	}
+
+	cards := doc.Board.Columns[1].Cards
+	if cards[0].Title != "first" || cards[1].Title != "second" {
+		t.Errorf("cards are not in creation order: %+v", cards)
+	}
+
+	if !slices.Equal(cards[0].Members, []string{"bob"}) || !slices.Equal(cards[0].Watchers, []string{"alice"}) || !slices.Equal(cards[0].Labels, []string{"bug"}) {
+		t.Errorf("card references = %+v", cards[0])
+	}
+
+	if !doc.Board.Members[0].Owner || doc.Board.Members[1].Owner {
+		t.Errorf("owner flag = %+v", doc.Board.Members)
+	}
+
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(encoded), board.Members[0].ID.String()) {
+		t.Error("export contains user ids")
+	}
+
+	if err := doc.Validate(); err != nil {
+		t.Errorf("exported document does not validate: %v", err)
+	}
+
+	if got := doc.Usernames(); !slices.Equal(got, []string{"alice", "bob"}) {
+		t.Errorf("usernames = %v", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	board, labels := testBoard()
+
+	tests := []struct {
+		name   string
+		change func(*Document)
+		want   string
+	}{
+		{"future version", func(d *Document) { d.Version = Version + 1 }, "not supported"},
+		{"missing version", func(d *Document) { d.Version = 0 }, "not supported"},
+		{"no name", func(d *Document) { d.Board.Name = " " }, "name is missing"},
+		{"duplicate label", func(d *Document) { d.Board.Labels = append(d.Board.Labels, d.Board.Labels[0]) }, "listed twice"},
+		{"unknown label", func(d *Document) { d.Board.Labels = nil }, "unknown label"},
+		{"empty column", func(d *Document) { d.Board.Columns[0].Name = "" }, "has no name"},
+		{"empty card", func(d *Document) { d.Board.Columns[1].Cards[1].Title = "" }, "has no title"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := Build(board, labels, time.Now())
+			tt.change(&doc)
+
+			err := doc.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
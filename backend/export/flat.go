@@ -0,0 +1,155 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"kerjainaja/model"
+	"strings"
+	"time"
+)
+
+// Writer writes a board column by column and card by card, so it can be
+// streamed without the whole board being loaded. Column is called before the
+// cards of that column, Flush pushes what was written so far to the client.
+type Writer interface {
+	Column(col model.Column) error
+	Card(col model.Column, card model.Card) error
+	Flush() error
+}
+
+// CSVHeader are the columns of a CSV export, one row per card.
+var CSVHeader = []string{"key", "column", "title", "description", "members", "labels", "due_date", "archived", "created_at"}
+
+type csvWriter struct {
+	w   *csv.Writer
+	loc *time.Location
+}
+
+// NewCSV writes the header and returns a Writer producing one row per card.
+// Times are written in loc.
+func NewCSV(w io.Writer, loc *time.Location) (Writer, error) {
+	c := &csvWriter{w: csv.NewWriter(w), loc: loc}
+	if err := c.w.Write(CSVHeader); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *csvWriter) Column(model.Column) error {
+	return nil
+}
+
+func (c *csvWriter) Card(col model.Column, card model.Card) error {
+	due := ""
+	if card.DueDate != nil {
+		due = card.DueDate.In(c.loc).Format("2006-01-02 15:04")
+	}
+
+	return c.w.Write([]string{
+		card.Key,
+		csvCell(col.Name),
+		csvCell(card.Title),
+		csvCell(card.Description),
+		csvCell(strings.Join(usernames(card.Members), ", ")),
+		csvCell(strings.Join(labelNames(card.Labels), ", ")),
+		due,
+		fmt.Sprint(card.Archived),
+		card.CreatedAt.In(c.loc).Format("2006-01-02 15:04"),
+	})
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// csvCell keeps spreadsheets from reading user text as a formula.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+
+	return s
+}
+
+type markdownWriter struct {
+	w   *bufio.Writer
+	loc *time.Location
+}
+
+// NewMarkdown writes the board title and returns a Writer producing a
+// section per column with the cards listed under it. Times are written in
+// loc.
+func NewMarkdown(w io.Writer, board model.Board, loc *time.Location) (Writer, error) {
+	m := &markdownWriter{w: bufio.NewWriter(w), loc: loc}
+	if _, err := fmt.Fprintf(m.w, "# %s\n", markdownLine(board.Name)); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (m *markdownWriter) Column(col model.Column) error {
+	_, err := fmt.Fprintf(m.w, "\n## %s\n", markdownLine(col.Name))
+	return err
+}
+
+func (m *markdownWriter) Card(_ model.Column, card model.Card) error {
+	title := markdownLine(card.Title)
+	if card.Key != "" {
+		title = card.Key + " " + title
+	}
+	if card.Archived {
+		title += " (archived)"
+	}
+
+	fmt.Fprintf(m.w, "\n### %s\n\n", title)
+
+	if names := usernames(card.Members); len(names) > 0 {
+		fmt.Fprintf(m.w, "- Members: @%s\n", strings.Join(names, ", @"))
+	}
+	if names := labelNames(card.Labels); len(names) > 0 {
+		fmt.Fprintf(m.w, "- Labels: %s\n", strings.Join(names, ", "))
+	}
+	if card.DueDate != nil {
+		fmt.Fprintf(m.w, "- Due: %s\n", card.DueDate.In(m.loc).Format("2006-01-02 15:04"))
+	}
+
+	if desc := strings.TrimSpace(card.Description); desc != "" {
+		fmt.Fprintf(m.w, "\n%s\n", desc)
+	}
+
+	// bufio keeps the first write error, Flush reports it
+	return nil
+}
+
+func (m *markdownWriter) Flush() error {
+	return m.w.Flush()
+}
+
+// markdownLine keeps a name on a single line so it can't break out of its
+// heading.
+func markdownLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func usernames(users []model.User) []string {
+	names := make([]string, 0, len(users))
+	for _, u := range users {
+		names = append(names, u.Username)
+	}
+
+	return names
+}
+
+func labelNames(labels []model.Label) []string {
+	names := make([]string, 0, len(labels))
+	for _, l := range labels {
+		names = append(names, l.Name)
+	}
+
+	return names
+}
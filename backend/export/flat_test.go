@@ -0,0 +1,107 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeBoard(t *testing.T, w Writer) {
+	t.Helper()
+
+	board, _ := testBoard()
+	for _, col := range board.Columns {
+		if err := w.Column(col); err != nil {
+			t.Fatal(err)
+		}
+		for _, card := range col.Cards {
+			if err := w.Card(col, card); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCSVWritesRowPerCard(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewCSV(&buf, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	board, _ := testBoard()
+	due := time.Date(2025, 6, 1, 17, 0, 0, 0, time.UTC)
+	board.Columns[0].Cards[0].DueDate = &due
+	board.Columns[0].Cards[0].Title = "=HYPERLINK(\"x\")"
+	board.Columns[0].Cards[0].Key = "KA-1"
+
+	for _, card := range board.Columns[0].Cards {
+		if err := w.Card(board.Columns[0], card); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 3 || strings.Join(rows[0], ",") != strings.Join(CSVHeader, ",") {
+		t.Fatalf("rows = %q", rows)
+	}
+
+	if rows[1][0] != "KA-1" || rows[1][2] != "'=HYPERLINK(\"x\")" || rows[1][6] != "2025-06-01 17:00" {
+		t.Errorf("first row = %q", rows[1])
+	}
+
+	if rows[2][1] != "Done" || rows[2][4] != "bob" || rows[2][5] != "bug" {
+		t.Errorf("second row = %q", rows[2])
+	}
+}
+
+func TestCSVUsesLocation(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewCSV(&buf, time.FixedZone("WIB", 7*3600))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeBoard(t, w)
+
+	if !strings.Contains(buf.String(), "2025-05-01 16:02") {
+		t.Errorf("created_at is not in the given location:\n%s", buf.String())
+	}
+}
+
+func TestMarkdownGroupsByColumn(t *testing.T) {
+	var buf bytes.Buffer
+	board, _ := testBoard()
+	board.Name = "Road\nmap"
+
+	w, err := NewMarkdown(&buf, board, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeBoard(t, w)
+
+	out := buf.String()
+	for _, want := range []string{"# Road map\n", "\n## Done\n", "\n### first\n", "- Members: @bob\n", "- Labels: bug\n", "\n## Todo\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("markdown is missing %q:\n%s", want, out)
+		}
+	}
+
+	if strings.Index(out, "## Done") > strings.Index(out, "### first") {
+		t.Errorf("card is not under its column:\n%s", out)
+	}
+}
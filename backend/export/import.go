@@ -0,0 +1,149 @@
+package export
+
+import (
+	"kerjainaja/model"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Report tells what of a document could not be carried over.
+type Report struct {
+	UnmappedUsers      []string `json:"unmapped_users"`
+	SkippedAttachments int      `json:"skipped_attachments"`
+}
+
+// Import recreates the board of a validated document with new ids. users
+// maps the usernames of the document to local users, anyone missing is left
+// off the board and its cards and listed in the report. Comments are kept
+// under their original username and only linked to an account when the
+// importer wrote them. The importer owns the new board.
+func Import(tx *gorm.DB, d Document, importer model.User, users map[string]model.User, workspaceID *uuid.UUID) (model.Board, Report, error) {
+	report := Report{UnmappedUsers: []string{}}
+	for _, name := range d.Usernames() {
+		if _, ok := users[name]; !ok {
+			report.UnmappedUsers = append(report.UnmappedUsers, name)
+		}
+	}
+
+	board := model.Board{
+		Name:        d.Board.Name,
+		OwnerID:     &importer.ID,
+		WorkspaceID: workspaceID,
+		Visibility:  model.VisibilityPrivate,
+	}
+	if workspaceID != nil && d.Board.Visibility != "" {
+		board.Visibility = d.Board.Visibility
+	}
+
+	if err := tx.Create(&board).Error; err != nil {
+		return board, report, err
+	}
+
+	members := []model.User{importer}
+	for _, m := range d.Board.Members {
+		if user, ok := users[m.Username]; ok && !slices.ContainsFunc(members, func(u model.User) bool { return u.ID == user.ID }) {
+			members = append(members, user)
+		}
+	}
+
+	if err := tx.Model(&board).Omit("Members.*").Association("Members").Append(members); err != nil {
+		return board, report, err
+	}
+
+	boardUser := func(name string) (model.User, bool) {
+		user, ok := users[name]
+		if !ok {
+			return user, false
+		}
+		return user, slices.ContainsFunc(members, func(u model.User) bool { return u.ID == user.ID })
+	}
+
+	labels := make(map[string]model.Label, len(d.Board.Labels))
+	for _, l := range d.Board.Labels {
+		label := model.Label{BoardID: board.ID, Name: l.Name, Color: l.Color}
+		if err := tx.Create(&label).Error; err != nil {
+			return board, report, err
+		}
+		labels[l.Name] = label
+	}
+
+	// rows are ordered by creation time, every card needs a later one than the
+	// card before it. Documents without timestamps get the current time.
+	var at time.Time
+	next := func(want time.Time) time.Time {
+		switch {
+		case !want.IsZero() && want.After(at):
+			at = want
+		case at.IsZero():
+			at = time.Now()
+		default:
+			at = at.Add(time.Millisecond)
+		}
+		return at
+	}
+
+	columnAt := time.Now()
+	for _, col := range d.Board.Columns {
+		columnAt = columnAt.Add(time.Millisecond)
+		column := model.Column{BoardID: board.ID, Name: col.Name, CreatedAt: columnAt}
+		column.WIPLimit, column.WIPMode = model.WIPSettings(col.WIPLimit, col.WIPMode)
+		if err := tx.Create(&column).Error; err != nil {
+			return board, report, err
+		}
+
+		at = time.Time{}
+		for _, c := range col.Cards {
+			card := model.Card{
+				ColumnID:    column.ID,
+				Title:       c.Title,
+				Description: c.Description,
+				DueDate:     c.DueDate,
+				Archived:    c.Archived,
+				CreatedAt:   next(c.CreatedAt),
+			}
+
+			for _, name := range c.Labels {
+				card.Labels = append(card.Labels, labels[name])
+			}
+			for _, name := range c.Members {
+				if user, ok := boardUser(name); ok {
+					card.Members = append(card.Members, user)
+				}
+			}
+			for _, name := range c.Watchers {
+				if user, ok := boardUser(name); ok {
+					card.Watchers = append(card.Watchers, user)
+				}
+			}
+
+			if err := tx.Omit("Labels.*", "Members.*", "Watchers.*").Create(&card).Error; err != nil {
+				return board, report, err
+			}
+
+			for _, cm := range c.Comments {
+				comment := model.Comment{
+					CardID:         card.ID,
+					AuthorUsername: cm.Author,
+					Body:           cm.Body,
+					CreatedAt:      cm.CreatedAt,
+				}
+				// a comment only becomes an account's own when the importer
+				// wrote it, the document can put any name on the rest
+				if user, ok := users[cm.Author]; ok && user.ID == importer.ID {
+					comment.AuthorID = &importer.ID
+				}
+
+				if err := tx.Create(&comment).Error; err != nil {
+					return board, report, err
+				}
+			}
+
+			report.SkippedAttachments += len(c.Attachments)
+		}
+	}
+
+	return board, report, nil
+}
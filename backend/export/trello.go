@@ -0,0 +1,267 @@
+package export
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// The parts of a Trello board export that are imported.
+type trelloBoard struct {
+	Name       string            `json:"name"`
+	Lists      []trelloList      `json:"lists"`
+	Cards      []trelloCard      `json:"cards"`
+	Labels     []trelloLabel     `json:"labels"`
+	Members    []trelloMember    `json:"members"`
+	Checklists []trelloChecklist `json:"checklists"`
+	Actions    []trelloAction    `json:"actions"`
+}
+
+type trelloList struct {
+	ID     string  `json:"id"`
+	Name   string  `json:"name"`
+	Closed bool    `json:"closed"`
+	Pos    float64 `json:"pos"`
+}
+
+type trelloCard struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Desc        string             `json:"desc"`
+	IDList      string             `json:"idList"`
+	Closed      bool               `json:"closed"`
+	Due         *time.Time         `json:"due"`
+	Pos         float64            `json:"pos"`
+	IDLabels    []string           `json:"idLabels"`
+	IDMembers   []string           `json:"idMembers"`
+	Attachments []trelloAttachment `json:"attachments"`
+}
+
+type trelloLabel struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+type trelloMember struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	FullName string `json:"fullName"`
+	Email    string `json:"email"`
+}
+
+type trelloChecklist struct {
+	Name       string            `json:"name"`
+	IDCard     string            `json:"idCard"`
+	Pos        float64           `json:"pos"`
+	CheckItems []trelloCheckItem `json:"checkItems"`
+}
+
+type trelloCheckItem struct {
+	Name  string  `json:"name"`
+	State string  `json:"state"`
+	Pos   float64 `json:"pos"`
+}
+
+type trelloAction struct {
+	Type string    `json:"type"`
+	Date time.Time `json:"date"`
+	Data struct {
+		Text string `json:"text"`
+		Card struct {
+			ID string `json:"id"`
+		} `json:"card"`
+	} `json:"data"`
+	MemberCreator struct {
+		Username string `json:"username"`
+	} `json:"memberCreator"`
+}
+
+type trelloAttachment struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Bytes    int64  `json:"bytes"`
+	MimeType string `json:"mimeType"`
+}
+
+// TrelloReport lists what of a Trello board has no place in kerjainaja.
+type TrelloReport struct {
+	// ClosedLists are archived lists, they and their cards are not imported.
+	ClosedLists  []string `json:"closed_lists"`
+	SkippedCards int      `json:"skipped_cards"`
+	// Checklists have no counterpart, they are appended to the card
+	// description as markdown task lists.
+	Checklists int `json:"checklists_in_description"`
+}
+
+// FromTrello converts a Trello board JSON export into a document that can be
+// imported. Lists become columns and archived cards stay archived. Members
+// are referred to by their Trello username, emails maps those usernames to
+// the email address when the export has one.
+func FromTrello(data []byte) (Document, map[string]string, TrelloReport, error) {
+	report := TrelloReport{ClosedLists: []string{}}
+	emails := make(map[string]string)
+
+	var tb trelloBoard
+	if err := json.Unmarshal(data, &tb); err != nil {
+		return Document{}, emails, report, fmt.Errorf("not a trello export: %w", err)
+	}
+
+	if tb.Name == "" || tb.Lists == nil {
+		return Document{}, emails, report, errors.New("not a trello export: board name or lists are missing")
+	}
+
+	doc := Document{
+		Version:    Version,
+		ExportedAt: time.Now().UTC(),
+		Board:      Board{Name: tb.Name, Members: []Member{}, Labels: []Label{}, Columns: []Column{}},
+	}
+
+	usernames := make(map[string]string, len(tb.Members))
+	for _, m := range tb.Members {
+		usernames[m.ID] = m.Username
+		doc.Board.Members = append(doc.Board.Members, Member{Username: m.Username, Name: m.FullName})
+		if m.Email != "" {
+			emails[m.Username] = m.Email
+		}
+	}
+
+	// trello labels may have no name or share one, kerjainaja needs unique names
+	labelNames := make(map[string]string, len(tb.Labels))
+	for _, l := range tb.Labels {
+		name := strings.TrimSpace(l.Name)
+		if name == "" {
+			name = l.Color
+		}
+		if name == "" {
+			name = "label"
+		}
+
+		unique := name
+		for i := 2; slices.ContainsFunc(doc.Board.Labels, func(x Label) bool { return x.Name == unique }); i++ {
+			unique = fmt.Sprintf("%s %d", name, i)
+		}
+
+		labelNames[l.ID] = unique
+		doc.Board.Labels = append(doc.Board.Labels, Label{Name: unique, Color: l.Color})
+	}
+
+	checklists := make(map[string][]trelloChecklist)
+	for _, c := range tb.Checklists {
+		checklists[c.IDCard] = append(checklists[c.IDCard], c)
+	}
+
+	comments := make(map[string][]Comment)
+	for _, a := range tb.Actions {
+		if a.Type == "commentCard" && a.Data.Card.ID != "" {
+			comments[a.Data.Card.ID] = append(comments[a.Data.Card.ID], Comment{
+				Author:    a.MemberCreator.Username,
+				Body:      a.Data.Text,
+				CreatedAt: a.Date,
+			})
+		}
+	}
+
+	lists := slices.Clone(tb.Lists)
+	slices.SortStableFunc(lists, func(a, b trelloList) int { return cmp.Compare(a.Pos, b.Pos) })
+
+	columns := make(map[string]int, len(lists))
+	for _, l := range lists {
+		if l.Closed {
+			report.ClosedLists = append(report.ClosedLists, l.Name)
+			continue
+		}
+
+		name := strings.TrimSpace(l.Name)
+		if name == "" {
+			name = "Untitled"
+		}
+
+		columns[l.ID] = len(doc.Board.Columns)
+		doc.Board.Columns = append(doc.Board.Columns, Column{Name: name, Cards: []Card{}})
+	}
+
+	cards := slices.Clone(tb.Cards)
+	slices.SortStableFunc(cards, func(a, b trelloCard) int { return cmp.Compare(a.Pos, b.Pos) })
+
+	for _, c := range cards {
+		index, ok := columns[c.IDList]
+		if !ok {
+			report.SkippedCards++
+			continue
+		}
+
+		title := strings.TrimSpace(c.Name)
+		if title == "" {
+			title = "Untitled"
+		}
+
+		card := Card{
+			Title:       title,
+			Description: c.Desc,
+			DueDate:     c.Due,
+			Archived:    c.Closed,
+		}
+
+		for _, id := range c.IDLabels {
+			if name, ok := labelNames[id]; ok {
+				card.Labels = append(card.Labels, name)
+			}
+		}
+
+		for _, id := range c.IDMembers {
+			if username, ok := usernames[id]; ok {
+				card.Members = append(card.Members, username)
+			}
+		}
+
+		if lists := checklists[c.ID]; len(lists) > 0 {
+			card.Description = appendChecklists(card.Description, lists)
+			report.Checklists += len(lists)
+		}
+
+		// trello lists the newest comment first
+		cardComments := comments[c.ID]
+		slices.SortStableFunc(cardComments, func(a, b Comment) int { return a.CreatedAt.Compare(b.CreatedAt) })
+		card.Comments = cardComments
+
+		for _, a := range c.Attachments {
+			card.Attachments = append(card.Attachments, Attachment{Name: a.Name, URL: a.URL, Size: a.Bytes, ContentType: a.MimeType})
+		}
+
+		doc.Board.Columns[index].Cards = append(doc.Board.Columns[index].Cards, card)
+	}
+
+	return doc, emails, report, nil
+}
+
+func appendChecklists(description string, lists []trelloChecklist) string {
+	slices.SortStableFunc(lists, func(a, b trelloChecklist) int { return cmp.Compare(a.Pos, b.Pos) })
+
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(description, "\n"))
+
+	for _, list := range lists {
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString("### " + list.Name + "\n")
+
+		items := slices.Clone(list.CheckItems)
+		slices.SortStableFunc(items, func(x, y trelloCheckItem) int { return cmp.Compare(x.Pos, y.Pos) })
+
+		for _, item := range items {
+			mark := " "
+			if item.State == "complete" {
+				mark = "x"
+			}
+			b.WriteString("\n- [" + mark + "] " + item.Name)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,94 @@
+package export
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const trelloFixture = `{
+	"name": "Old board",
+	"lists": [
+		{"id": "l2", "name": "Done", "pos": 2},
+		{"id": "l1", "name": "Todo", "pos": 1},
+		{"id": "l3", "name": "Old", "pos": 3, "closed": true}
+	],
+	"labels": [
+		{"id": "a", "name": "", "color": "red"},
+		{"id": "b", "name": "red", "color": "orange"}
+	],
+	"members": [
+		{"id": "m1", "username": "ana", "fullName": "Ana", "email": "ana@example.com"},
+		{"id": "m2", "username": "budi", "fullName": "Budi"}
+	],
+	"cards": [
+		{"id": "c2", "name": "second", "idList": "l1", "pos": 20, "idLabels": ["a", "b"], "idMembers": ["m2"]},
+		{"id": "c1", "name": "first", "desc": "notes", "idList": "l1", "pos": 10, "due": "2025-03-01T12:00:00.000Z", "closed": true,
+			"attachments": [{"name": "spec.pdf", "url": "https://example.com/spec.pdf", "bytes": 10, "mimeType": "application/pdf"}]},
+		{"id": "c3", "name": "gone", "idList": "l3", "pos": 1}
+	],
+	"checklists": [
+		{"idCard": "c1", "name": "Steps", "pos": 1, "checkItems": [
+			{"name": "two", "state": "incomplete", "pos": 2},
+			{"name": "one", "state": "complete", "pos": 1}
+		]}
+	],
+	"actions": [
+		{"type": "commentCard", "date": "2025-02-02T00:00:00.000Z", "data": {"text": "later", "card": {"id": "c1"}}, "memberCreator": {"username": "budi"}},
+		{"type": "commentCard", "date": "2025-02-01T00:00:00.000Z", "data": {"text": "earlier", "card": {"id": "c1"}}, "memberCreator": {"username": "ana"}},
+		{"type": "updateCard", "date": "2025-02-03T00:00:00.000Z", "data": {"card": {"id": "c1"}}}
+	]
+}`
+
+func TestFromTrello(t *testing.T) {
+	doc, emails, report, err := FromTrello([]byte(trelloFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := doc.Validate(); err != nil {
+		t.Fatalf("converted document does not validate: %v", err)
+	}
+
+	if len(doc.Board.Columns) != 2 || doc.Board.Columns[0].Name != "Todo" {
+		t.Fatalf("columns = %+v", doc.Board.Columns)
+	}
+
+	if !slices.Equal(report.ClosedLists, []string{"Old"}) || report.SkippedCards != 1 || report.Checklists != 1 {
+		t.Errorf("report = %+v", report)
+	}
+
+	if emails["ana"] != "ana@example.com" || len(emails) != 1 {
+		t.Errorf("emails = %v", emails)
+	}
+
+	cards := doc.Board.Columns[0].Cards
+	first, second := cards[0], cards[1]
+	if first.Title != "first" || second.Title != "second" {
+		t.Fatalf("cards are not in trello order: %+v", cards)
+	}
+
+	if !first.Archived || first.DueDate == nil || len(first.Attachments) != 1 {
+		t.Errorf("first card = %+v", first)
+	}
+
+	if !strings.Contains(first.Description, "notes\n\n### Steps\n\n- [x] one\n- [ ] two") {
+		t.Errorf("checklist is not in the description: %q", first.Description)
+	}
+
+	if len(first.Comments) != 2 || first.Comments[0].Body != "earlier" || first.Comments[1].Author != "budi" {
+		t.Errorf("comments = %+v", first.Comments)
+	}
+
+	// the unnamed red label takes the color as name, so the label named red
+	// needs another one
+	if !slices.Equal(second.Labels, []string{"red", "red 2"}) || !slices.Equal(second.Members, []string{"budi"}) {
+		t.Errorf("second card = %+v", second)
+	}
+}
+
+func TestFromTrelloRejectsOtherJSON(t *testing.T) {
+	if _, _, _, err := FromTrello([]byte(`{"version": 1, "board": {}}`)); err == nil {
+		t.Error("a kerjainaja document was accepted as trello export")
+	}
+}
@@ -0,0 +1,90 @@
+package filter
+
+import (
+	"kerjainaja/helpers"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Context carries what a filter needs to be resolved for a request.
+type Context struct {
+	Now      time.Time
+	Username string
+}
+
+// Apply adds the filter conditions to a query on the cards table.
+func (f Filter) Apply(db *gorm.DB, c Context) *gorm.DB {
+	for _, t := range f.Terms {
+		sql, args := t.condition(c)
+		if t.Negate {
+			sql = "NOT (" + sql + ")"
+		}
+		db = db.Where(sql, args...)
+	}
+
+	return db
+}
+
+func (t Term) condition(c Context) (string, []any) {
+	switch t.Field {
+	case "member":
+		username := t.Value
+		if strings.EqualFold(username, "me") {
+			username = c.Username
+		}
+		return "cards.id IN (SELECT card_members.card_id FROM card_members JOIN users ON users.id = card_members.user_id WHERE users.username = ?)", []any{username}
+	case "label":
+		return "cards.id IN (SELECT card_labels.card_id FROM card_labels JOIN labels ON labels.id = card_labels.label_id WHERE LOWER(labels.name) = ?)", []any{strings.ToLower(t.Value)}
+	case "is":
+		switch t.Value {
+		case "archived":
+			return "cards.archived = ?", []any{true}
+		case "assigned":
+			return "cards.id IN (SELECT card_members.card_id FROM card_members)", nil
+		case "overdue":
+			return "cards.due_date IS NOT NULL AND cards.due_date < ?", []any{c.Now}
+		}
+	case "due":
+		return t.due.condition(c.Now)
+	}
+
+	like := "%" + helpers.EscapeLike(strings.ToLower(t.Value)) + "%"
+	return "(LOWER(cards.title) LIKE ? OR LOWER(cards.description) LIKE ?)", []any{like, like}
+}
+
+func (d dueRange) condition(now time.Time) (string, []any) {
+	switch d.kind {
+	case "none":
+		return "cards.due_date IS NULL", nil
+	case "any":
+		return "cards.due_date IS NOT NULL", nil
+	case "overdue":
+		return "cards.due_date IS NOT NULL AND cards.due_date < ?", []any{now}
+	}
+
+	if d.isDate && d.kind == "on" {
+		return "cards.due_date >= ? AND cards.due_date < ?", []any{d.date, d.date.AddDate(0, 0, 1)}
+	}
+
+	bound := now.Add(d.relative)
+	if d.isDate {
+		bound = d.date
+		// a date bound covers the whole day
+		if (d.kind == "before" && d.orEqual) || (d.kind == "after" && !d.orEqual) {
+			bound = bound.AddDate(0, 0, 1)
+		}
+		if d.kind == "before" {
+			return "cards.due_date IS NOT NULL AND cards.due_date < ?", []any{bound}
+		}
+		return "cards.due_date IS NOT NULL AND cards.due_date >= ?", []any{bound}
+	}
+
+	op := map[bool]map[string]string{
+		false: {"before": "<", "after": ">"},
+		true:  {"before": "<=", "after": ">="},
+	}[d.orEqual][d.kind]
+
+	return "cards.due_date IS NOT NULL AND cards.due_date " + op + " ?", []any{bound}
+}
@@ -0,0 +1,260 @@
+// Package filter parses the card filter language used by board views, e.g.
+//
+//	member:alice label:bug due:<7d -is:archived "release notes"
+//
+// and turns it into GORM conditions on the cards table. Values never reach
+// the SQL text, they are always bound as parameters.
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var fields = []string{"member", "label", "due", "is"}
+
+// Term is a single condition of a filter. Field is empty for free text.
+type Term struct {
+	Negate bool
+	Field  string
+	Value  string
+	Pos    int
+
+	due dueRange
+}
+
+type Filter struct {
+	Terms []Term
+}
+
+type ParseError struct {
+	Pos int
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("filter: %s at position %d", e.Msg, e.Pos+1)
+}
+
+// Parse reads a filter expression. An empty expression matches every card.
+func Parse(input string) (Filter, error) {
+	var f Filter
+
+	runes := []rune(input)
+	i := 0
+	for {
+		for i < len(runes) && unicode.IsSpace(runes[i]) {
+			i++
+		}
+		if i >= len(runes) {
+			break
+		}
+
+		term := Term{Pos: i}
+		if runes[i] == '-' {
+			term.Negate = true
+			i++
+			if i >= len(runes) || unicode.IsSpace(runes[i]) {
+				return f, &ParseError{Pos: term.Pos, Msg: `"-" must be followed by a condition`}
+			}
+		}
+
+		word, next, err := readValue(runes, i)
+		if err != nil {
+			return f, err
+		}
+
+		if runes[i] != '"' && next < len(runes) && runes[next] == ':' {
+			term.Field = strings.ToLower(word)
+			if !contains(fields, term.Field) {
+				return f, &ParseError{Pos: i, Msg: fmt.Sprintf("unknown field %q, expected one of %s", word, strings.Join(fields, ", "))}
+			}
+
+			valuePos := next + 1
+			if valuePos >= len(runes) || unicode.IsSpace(runes[valuePos]) {
+				return f, &ParseError{Pos: valuePos, Msg: fmt.Sprintf("missing value for %q", term.Field)}
+			}
+
+			term.Value, next, err = readValue(runes, valuePos)
+			if err != nil {
+				return f, err
+			}
+
+			if err := term.validate(valuePos); err != nil {
+				return f, err
+			}
+		} else {
+			term.Value = word
+		}
+
+		if term.Value == "" {
+			return f, &ParseError{Pos: term.Pos, Msg: "empty value"}
+		}
+
+		f.Terms = append(f.Terms, term)
+		i = next
+	}
+
+	return f, nil
+}
+
+// readValue reads a bare word up to whitespace or ':' or a double quoted
+// string with backslash escapes.
+func readValue(runes []rune, i int) (string, int, error) {
+	if runes[i] != '"' {
+		start := i
+		for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != ':' {
+			i++
+		}
+		if i < len(runes) && runes[i] == ':' && i == start {
+			return "", i, &ParseError{Pos: i, Msg: `unexpected ":"`}
+		}
+		return string(runes[start:i]), i, nil
+	}
+
+	start := i
+	i++
+	var b strings.Builder
+	for i < len(runes) {
+		switch runes[i] {
+		case '\\':
+			if i+1 < len(runes) {
+				b.WriteRune(runes[i+1])
+				i += 2
+				continue
+			}
+		case '"':
+			return b.String(), i + 1, nil
+		}
+		b.WriteRune(runes[i])
+		i++
+	}
+
+	return "", i, &ParseError{Pos: start, Msg: "unterminated quoted value"}
+}
+
+var isValues = []string{"archived", "assigned", "overdue"}
+
+func (t *Term) validate(pos int) error {
+	switch t.Field {
+	case "is":
+		t.Value = strings.ToLower(t.Value)
+		if !contains(isValues, t.Value) {
+			return &ParseError{Pos: pos, Msg: fmt.Sprintf("unknown value %q for is, expected one of %s", t.Value, strings.Join(isValues, ", "))}
+		}
+	case "due":
+		due, err := parseDue(t.Value)
+		if err != nil {
+			return &ParseError{Pos: pos, Msg: err.Error()}
+		}
+		t.due = due
+	}
+
+	return nil
+}
+
+// String renders the filter back in canonical form.
+func (f Filter) String() string {
+	parts := make([]string, 0, len(f.Terms))
+	for _, t := range f.Terms {
+		var b strings.Builder
+		if t.Negate {
+			b.WriteString("-")
+		}
+		if t.Field != "" {
+			b.WriteString(t.Field)
+			b.WriteString(":")
+		}
+		if strings.ContainsFunc(t.Value, func(r rune) bool { return unicode.IsSpace(r) || r == '"' || r == ':' }) {
+			b.WriteString(`"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(t.Value) + `"`)
+		} else {
+			b.WriteString(t.Value)
+		}
+		parts = append(parts, b.String())
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// dueRange describes a due:... value. Relative bounds are resolved against
+// the current time when the filter is applied.
+type dueRange struct {
+	kind     string // "none", "any", "overdue", "before", "after", "on"
+	relative time.Duration
+	date     time.Time
+	isDate   bool
+	orEqual  bool
+}
+
+func parseDue(value string) (dueRange, error) {
+	switch strings.ToLower(value) {
+	case "none", "any", "overdue":
+		return dueRange{kind: strings.ToLower(value)}, nil
+	}
+
+	var due dueRange
+	rest := value
+	switch {
+	case strings.HasPrefix(rest, "<="):
+		due.kind, due.orEqual, rest = "before", true, rest[2:]
+	case strings.HasPrefix(rest, ">="):
+		due.kind, due.orEqual, rest = "after", true, rest[2:]
+	case strings.HasPrefix(rest, "<"):
+		due.kind, rest = "before", rest[1:]
+	case strings.HasPrefix(rest, ">"):
+		due.kind, rest = "after", rest[1:]
+	default:
+		due.kind = "on"
+	}
+
+	if date, err := time.ParseInLocation("2006-01-02", rest, time.Local); err == nil {
+		due.date, due.isDate = date, true
+		return due, nil
+	}
+
+	if due.kind == "on" {
+		return due, fmt.Errorf("invalid due value %q, expected none, any, overdue, a date like 2025-01-31 or a range like <7d", value)
+	}
+
+	duration, err := parseRelative(rest)
+	if err != nil {
+		return due, fmt.Errorf("invalid due value %q: %s", value, err)
+	}
+	due.relative = duration
+
+	return due, nil
+}
+
+func parseRelative(value string) (time.Duration, error) {
+	if len(value) < 2 {
+		return 0, fmt.Errorf("expected a number followed by h, d or w")
+	}
+
+	var n int
+	if _, err := fmt.Sscanf(value[:len(value)-1], "%d", &n); err != nil || fmt.Sprint(n) != value[:len(value)-1] {
+		return 0, fmt.Errorf("expected a number followed by h, d or w")
+	}
+
+	switch value[len(value)-1] {
+	case 'h':
+		return time.Duration(n) * time.Hour, nil
+	case 'd':
+		return time.Duration(n) * 24 * time.Hour, nil
+	case 'w':
+		return time.Duration(n) * 7 * 24 * time.Hour, nil
+	}
+
+	return 0, fmt.Errorf("unknown unit %q, expected h, d or w", value[len(value)-1:])
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
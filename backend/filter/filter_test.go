@@ -0,0 +1,93 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	f, err := Parse(`member:alice label:"high priority" due:<7d -is:archived release`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Term{
+		{Field: "member", Value: "alice"},
+		{Field: "label", Value: "high priority"},
+		{Field: "due", Value: "<7d"},
+		{Field: "is", Value: "archived", Negate: true},
+		{Value: "release"},
+	}
+
+	if len(f.Terms) != len(expected) {
+		t.Fatalf("expected %d terms, got %d", len(expected), len(f.Terms))
+	}
+
+	for i, term := range f.Terms {
+		if term.Field != expected[i].Field || term.Value != expected[i].Value || term.Negate != expected[i].Negate {
+			t.Errorf("term %d: expected %+v, got %+v", i, expected[i], term)
+		}
+	}
+
+	if got := f.String(); got != `member:alice label:"high priority" due:<7d -is:archived release` {
+		t.Errorf("unexpected canonical form %q", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := map[string]string{
+		"lable:bug":         `unknown field "lable"`,
+		"member:":           `missing value for "member"`,
+		"is:done":           `unknown value "done" for is`,
+		"due:soon":          `invalid due value "soon"`,
+		"due:<7y":           `unknown unit "y"`,
+		`label:"oops`:       "unterminated quoted value",
+		"bug -":             `"-" must be followed by a condition`,
+		"label:a:b":         `unexpected ":"`,
+		"due:<seven-days-d": "expected a number",
+	}
+
+	for input, message := range cases {
+		_, err := Parse(input)
+		if err == nil {
+			t.Errorf("%q: expected an error", input)
+			continue
+		}
+
+		if !strings.Contains(err.Error(), message) {
+			t.Errorf("%q: expected error containing %q, got %q", input, message, err)
+		}
+	}
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	_, err := Parse("label:bug is:nope")
+
+	parseErr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected a ParseError, got %v", err)
+	}
+
+	if parseErr.Pos != 13 {
+		t.Errorf("expected error at the value of is, got position %d", parseErr.Pos)
+	}
+}
+
+func TestDueCondition(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	f, err := Parse("due:<7d")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sql, args := f.Terms[0].condition(Context{Now: now})
+	if sql != "cards.due_date IS NOT NULL AND cards.due_date < ?" {
+		t.Errorf("unexpected sql %q", sql)
+	}
+
+	if bound := args[0].(time.Time); !bound.Equal(now.Add(7 * 24 * time.Hour)) {
+		t.Errorf("unexpected bound %s", bound)
+	}
+}
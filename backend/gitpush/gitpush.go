@@ -0,0 +1,108 @@
+// Package gitpush reads push payloads from GitHub, GitLab or any sender
+// using the same shape, and finds the card keys commit messages mention.
+package gitpush
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Push is the part of a push payload kerjainaja uses. GitHub and GitLab
+// both send commits in this shape, they only differ in where the
+// repository name is.
+type Push struct {
+	Ref        string     `json:"ref"`
+	Repository repository `json:"repository"`
+	Project    repository `json:"project"`
+	Commits    []Commit   `json:"commits"`
+}
+
+type repository struct {
+	Name              string `json:"name"`
+	FullName          string `json:"full_name"`
+	PathWithNamespace string `json:"path_with_namespace"`
+}
+
+type Commit struct {
+	ID        string    `json:"id"`
+	Message   string    `json:"message"`
+	URL       string    `json:"url"`
+	Timestamp time.Time `json:"timestamp"`
+	Author    struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	} `json:"author"`
+}
+
+// Parse reads a push payload. A payload without commits, like the ping
+// GitHub sends when a hook is added, is not an error.
+func Parse(data []byte) (Push, error) {
+	var push Push
+	if err := json.Unmarshal(data, &push); err != nil {
+		return push, errors.New("payload is not a push event: " + err.Error())
+	}
+
+	for i, c := range push.Commits {
+		if c.ID == "" {
+			return push, errors.New("commit " + strconv.Itoa(i+1) + " has no id")
+		}
+	}
+
+	return push, nil
+}
+
+// RepositoryName is the full name of the pushed repository.
+func (p Push) RepositoryName() string {
+	for _, name := range []string{p.Repository.FullName, p.Project.PathWithNamespace, p.Repository.Name, p.Project.Name} {
+		if name != "" {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// Branch is the pushed branch, or the full ref when it isn't one.
+func (p Push) Branch() string {
+	return strings.TrimPrefix(p.Ref, "refs/heads/")
+}
+
+// Ref is a card key mentioned in a commit message. Close is set when the
+// key follows a closing keyword, like "fixes KA-42".
+type Ref struct {
+	Number int
+	Close  bool
+}
+
+// Refs finds the keys with the given prefix in a message. Keys are matched
+// case-insensitively and a key mentioned twice is returned once, closing if
+// any mention closes it.
+func Refs(message, prefix string) []Ref {
+	pattern := regexp.MustCompile(`(?i)(?:\b(fix(?:es|ed)?|close[sd]?|resolve[sd]?)\b:?\s+)?\b` + regexp.QuoteMeta(prefix) + `-(\d+)\b`)
+
+	var refs []Ref
+	for _, match := range pattern.FindAllStringSubmatch(message, -1) {
+		number, err := strconv.Atoi(match[2])
+		if err != nil || number <= 0 {
+			continue
+		}
+
+		closing := match[1] != ""
+		found := false
+		for i := range refs {
+			if refs[i].Number == number {
+				refs[i].Close = refs[i].Close || closing
+				found = true
+			}
+		}
+		if !found {
+			refs = append(refs, Ref{Number: number, Close: closing})
+		}
+	}
+
+	return refs
+}
@@ -0,0 +1,53 @@
+package gitpush
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRefs(t *testing.T) {
+	cases := []struct {
+		message string
+		want    []Ref
+	}{
+		{"Add login page for KA-1", []Ref{{Number: 1}}},
+		{"fixes KA-42", []Ref{{Number: 42, Close: true}}},
+		{"Closes: ka-7, refs KA-8", []Ref{{Number: 7, Close: true}, {Number: 8}}},
+		{"KA-3 first\n\nResolved KA-3", []Ref{{Number: 3, Close: true}}},
+		{"prefix clash XKA-5 and KA-0", nil},
+		{"closes #12", nil},
+	}
+
+	for _, c := range cases {
+		if got := Refs(c.message, "KA"); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Refs(%q) = %+v, want %+v", c.message, got, c.want)
+		}
+	}
+}
+
+func TestParseGitHubAndGitLab(t *testing.T) {
+	github := `{"ref":"refs/heads/main","repository":{"name":"app","full_name":"acme/app"},
+		"commits":[{"id":"abc","message":"fixes KA-1","url":"https://github.com/acme/app/commit/abc","timestamp":"2025-05-01T10:00:00+07:00","author":{"name":"Alice","email":"alice@example.com"}}]}`
+	gitlab := `{"object_kind":"push","ref":"refs/heads/dev","project":{"name":"app","path_with_namespace":"acme/app"},
+		"commits":[{"id":"def","message":"KA-2","url":"https://gitlab.com/acme/app/-/commit/def","timestamp":"2025-05-01T10:00:00Z","author":{"name":"Bob","email":"bob@example.com"}}]}`
+
+	push, err := Parse([]byte(github))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if push.RepositoryName() != "acme/app" || push.Branch() != "main" || push.Commits[0].Author.Name != "Alice" {
+		t.Errorf("github push = %+v", push)
+	}
+
+	push, err = Parse([]byte(gitlab))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if push.RepositoryName() != "acme/app" || push.Branch() != "dev" || push.Commits[0].ID != "def" {
+		t.Errorf("gitlab push = %+v", push)
+	}
+
+	if _, err := Parse([]byte(`{"commits":[{"message":"no id"}]}`)); err == nil {
+		t.Error("commit without id was accepted")
+	}
+}
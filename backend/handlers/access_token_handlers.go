@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"kerjainaja/database"
+	"kerjainaja/helpers"
+	"kerjainaja/model"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func GetAccessTokens() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		var tokens []model.PersonalAccessToken
+		if err := database.DB.Where("user_id = ?", user.ID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get tokens")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, tokens, "success get tokens")
+	}
+}
+
+func CreateAccessToken() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.NewAccessToken
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		// a leaked token must not be able to mint more tokens
+		user, ok := currentUser(ctx)
+		if !ok || !requireSession(ctx) {
+			return
+		}
+
+		scopes := []string{}
+		for _, scope := range req.Scopes {
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+		if len(scopes) == 0 {
+			scopes = append(scopes, model.ScopeRead)
+		}
+
+		secret, hash, err := helpers.GenerateSecret(model.AccessTokenPrefix)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to generate token")
+			return
+		}
+
+		accessToken := model.PersonalAccessToken{
+			UserID:    user.ID,
+			Name:      req.Name,
+			Prefix:    secret[:len(model.AccessTokenPrefix)+6],
+			TokenHash: hash,
+			Scopes:    strings.Join(scopes, ","),
+		}
+
+		if req.ExpiresInDays > 0 {
+			expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+			accessToken.ExpiresAt = &expiresAt
+		}
+
+		if err := database.DB.Create(&accessToken).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to create token")
+			return
+		}
+
+		data := map[string]any{
+			"token":        secret,
+			"access_token": accessToken,
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, data, "success create token, copy it now because it will not be shown again")
+	}
+}
+
+func RevokeAccessToken() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		id, err := uuid.Parse(ctx.Param("id"))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "token id is not valid")
+			return
+		}
+
+		result := database.DB.Model(&model.PersonalAccessToken{}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, user.ID).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to revoke token")
+			return
+		}
+
+		if result.RowsAffected == 0 {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "token is not found")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success revoke token")
+	}
+}
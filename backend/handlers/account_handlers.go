@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"kerjainaja/config"
+	"kerjainaja/crypto"
+	"kerjainaja/database"
+	"kerjainaja/helpers"
+	"kerjainaja/mailer"
+	"kerjainaja/model"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// issueUserToken replaces any pending token of the same purpose with a new
+// one and returns its plain value.
+func issueUserToken(user model.User, purpose string, ttl time.Duration) (string, error) {
+	secret, hash, err := helpers.GenerateSecret("")
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	if err := database.DB.Model(&model.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", user.ID, purpose).
+		Update("used_at", now).Error; err != nil {
+		return "", err
+	}
+
+	userToken := model.UserToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	if err := database.DB.Create(&userToken).Error; err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+// consumeUserToken marks a token as used. The used_at condition makes sure a
+// token can't be used twice even by concurrent requests.
+func consumeUserToken(secret string, purpose string) (model.UserToken, error) {
+	var userToken model.UserToken
+	if err := database.DB.First(&userToken, "token_hash = ? AND purpose = ?", helpers.HashSecret(secret), purpose).Error; err != nil {
+		return userToken, errors.New("token is invalid")
+	}
+
+	if userToken.UsedAt != nil {
+		return userToken, errors.New("token is already used")
+	}
+
+	now := time.Now()
+	if userToken.ExpiresAt.Before(now) {
+		return userToken, errors.New("token is expired")
+	}
+
+	result := database.DB.Model(&userToken).Where("used_at IS NULL").Update("used_at", now)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return userToken, errors.New("token is already used")
+	}
+
+	return userToken, nil
+}
+
+func appLink(path string, token string) string {
+	return fmt.Sprintf("%s%s?token=%s", config.Env("APP_URL"), path, url.QueryEscape(token))
+}
+
+func sendVerificationEmail(user model.User) error {
+	token, err := issueUserToken(user, model.TokenEmailVerification, 24*time.Hour)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hi %s,\n\nconfirm your email address by opening the link below. The link is valid for 24 hours.\n\n%s\n", user.Name, appLink("/verify-email", token))
+	return mailer.Default.Send(user.Email, "Verify your kerjainaja email", body)
+}
+
+// sendEmailChangeEmail asks for a confirmation at the new address, the email
+// of the account only changes once the link is opened.
+func sendEmailChangeEmail(user model.User, email string) error {
+	token, err := issueUserToken(user, model.TokenEmailChange, 24*time.Hour)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hi %s,\n\nconfirm this address as the new email of your kerjainaja account by opening the link below. The link is valid for 24 hours.\n\n%s\n", user.Name, appLink("/confirm-email", token))
+	return mailer.Default.Send(email, "Confirm your new kerjainaja email", body)
+}
+
+func sendPasswordResetEmail(user model.User) error {
+	token, err := issueUserToken(user, model.TokenPasswordReset, time.Hour)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hi %s,\n\nsomeone asked to reset your kerjainaja password. Open the link below within an hour to choose a new one, or ignore this email.\n\n%s\n", user.Name, appLink("/reset-password", token))
+	return mailer.Default.Send(user.Email, "Reset your kerjainaja password", body)
+}
+
+func emailVerificationRequired() bool {
+	return config.Env("REQUIRE_VERIFIED_EMAIL") == "true"
+}
+
+func ForgotPassword() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !passwordLoginAllowed(ctx) {
+			return
+		}
+
+		var req model.ForgotPassword
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		// the answer is the same whether the email exists or not
+		message := "if the email is registered, a reset link has been sent"
+
+		var user model.User
+		if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusOK, true, nil, message)
+			return
+		}
+
+		if err := sendPasswordResetEmail(user); err != nil {
+			log.Printf("mailer: failed to send reset email to %s: %v", user.Email, err)
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, message)
+	}
+}
+
+func ResetPassword() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !passwordLoginAllowed(ctx) {
+			return
+		}
+
+		var req model.ResetPassword
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		userToken, err := consumeUserToken(req.Token, model.TokenPasswordReset)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+			return
+		}
+
+		if err := database.DB.Model(&model.User{}).
+			Where("id = ?", userToken.UserID).
+			Update("password", crypto.HashPassword(req.Password)).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to reset password")
+			return
+		}
+
+		// whoever knew the old password must not stay logged in
+		if err := revokeSessions("user_id = ?", userToken.UserID); err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to revoke sessions")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success reset password")
+	}
+}
+
+func SendEmailVerification() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		if user.EmailVerifiedAt != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "email is already verified")
+			return
+		}
+
+		if err := sendVerificationEmail(user); err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to send verification email")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "verification email has been sent")
+	}
+}
+
+func VerifyEmail() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.VerifyEmail
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		userToken, err := consumeUserToken(req.Token, model.TokenEmailVerification)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+			return
+		}
+
+		if err := database.DB.Model(&model.User{}).
+			Where("id = ?", userToken.UserID).
+			Update("email_verified_at", time.Now()).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to verify email")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success verify email")
+	}
+}
+
+func ConfirmEmailChange() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.VerifyEmail
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		userToken, err := consumeUserToken(req.Token, model.TokenEmailChange)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+			return
+		}
+
+		var user model.User
+		if err := database.DB.First(&user, "id = ?", userToken.UserID).Error; err != nil || user.PendingEmail == "" {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "there is no email change to confirm")
+			return
+		}
+
+		// the address may have been registered since the change was asked for
+		var count int64
+		database.DB.Model(&model.User{}).Where("email = ? AND id <> ?", user.PendingEmail, user.ID).Count(&count)
+		if count > 0 {
+			helpers.ResponseJson(ctx, http.StatusConflict, false, nil, "email sudah terdaftar")
+			return
+		}
+
+		if err := database.DB.Model(&user).Updates(map[string]any{
+			"email":             user.PendingEmail,
+			"email_verified_at": time.Now(),
+			"pending_email":     "",
+		}).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to change email")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success change email")
+	}
+}
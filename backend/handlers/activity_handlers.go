@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"encoding/json"
+	"kerjainaja/automation"
+	"kerjainaja/database"
+	"kerjainaja/helpers"
+	"kerjainaja/model"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// recordActivity appends an entry to the board activity log and streams it to
+// SSE clients and webhooks. before and after are diffed, pass nil for the missing side of
+// a creation or deletion. Failures are logged and never fail the request.
+func recordActivity(boardID uuid.UUID, actor model.User, entityType string, entityID uuid.UUID, action string, before any, after any) {
+	recordChainActivity(automation.NewChain(), boardID, actor, entityType, entityID, action, before, after)
+}
+
+// recordChainActivity is recordActivity for changes made by automation
+// rules, the rules the entry triggers in turn continue the chain.
+func recordChainActivity(chain *automation.Chain, boardID uuid.UUID, actor model.User, entityType string, entityID uuid.UUID, action string, before any, after any) {
+	changes, err := helpers.Diff(before, after)
+	if err != nil {
+		log.Printf("activity: failed to diff %s %s: %v", entityType, entityID, err)
+		return
+	}
+
+	changesBytes, err := json.Marshal(changes)
+	if err != nil {
+		log.Printf("activity: failed to encode %s %s: %v", entityType, entityID, err)
+		return
+	}
+
+	activity := model.Activity{
+		BoardID:       boardID,
+		ActorID:       actor.ID,
+		ActorUsername: actor.Username,
+		EntityType:    entityType,
+		EntityID:      entityID,
+		Action:        action,
+		Changes:       changesBytes,
+	}
+
+	if err := database.DB.Create(&activity).Error; err != nil {
+		log.Printf("activity: failed to record %s %s: %v", entityType, entityID, err)
+		return
+	}
+
+	BroadcastBoardEvent(boardID, "activity", activity)
+
+	runAutomations(chain, activity)
+}
+
+func boardSnapshot(board model.Board) map[string]any {
+	return map[string]any{
+		"name": board.Name,
+	}
+}
+
+func columnSnapshot(column model.Column) map[string]any {
+	return map[string]any{
+		"name": column.Name,
+	}
+}
+
+func cardSnapshot(card model.Card) map[string]any {
+	members := make([]string, 0, len(card.Members))
+	for _, m := range card.Members {
+		members = append(members, m.Username)
+	}
+
+	watchers := make([]string, 0, len(card.Watchers))
+	for _, w := range card.Watchers {
+		watchers = append(watchers, w.Username)
+	}
+
+	labels := make([]string, 0, len(card.Labels))
+	for _, l := range card.Labels {
+		labels = append(labels, l.Name)
+	}
+
+	return map[string]any{
+		"key":         card.Key,
+		"title":       card.Title,
+		"description": card.Description,
+		"due_date":    card.DueDate,
+		"archived":    card.Archived,
+		"column_id":   card.ColumnID,
+		"members":     members,
+		"watchers":    watchers,
+		"labels":      labels,
+	}
+}
+
+func listActivities(ctx *gin.Context, query any, args ...any) {
+	page, limit := helpers.Pagination(ctx)
+
+	var total int64
+	if err := database.DB.Model(&model.Activity{}).Where(query, args...).Count(&total).Error; err != nil {
+		helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get activity")
+		return
+	}
+
+	var activities []model.Activity
+	if err := database.DB.
+		Where(query, args...).
+		Order("created_at DESC").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&activities).Error; err != nil {
+		helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get activity")
+		return
+	}
+
+	data := helpers.Page{
+		Items: activities,
+		Page:  page,
+		Limit: limit,
+		Total: total,
+	}
+
+	helpers.ResponseJson(ctx, http.StatusOK, true, data, "success get activity")
+}
+
+func GetBoardActivity() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		boardID, err := uuid.Parse(ctx.Param("id"))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board id is not valid")
+			return
+		}
+
+		if !isBoardMember(boardID, user.ID) {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this board")
+			return
+		}
+
+		listActivities(ctx, "board_id = ?", boardID)
+	}
+}
+
+func GetCardHistory() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		cardID, err := uuid.Parse(ctx.Param("id"))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "card id is not valid")
+			return
+		}
+
+		// the card may already be deleted, so the board is taken from its history
+		var first model.Activity
+		if err := database.DB.Where("entity_type = ? AND entity_id = ?", "card", cardID).First(&first).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "card history is not found")
+			return
+		}
+
+		if !isBoardMember(first.BoardID, user.ID) {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this board")
+			return
+		}
+
+		listActivities(ctx, "entity_type = ? AND entity_id = ?", "card", cardID)
+	}
+}
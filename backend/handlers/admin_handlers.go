@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"fmt"
+	"kerjainaja/crypto"
+	"kerjainaja/database"
+	"kerjainaja/helpers"
+	"kerjainaja/model"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RequireAdmin guards the admin routes. The role is read from the database
+// rather than the token so a demoted admin loses access right away.
+func RequireAdmin() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			ctx.Abort()
+			return
+		}
+
+		if !user.IsAdmin() {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "admin only")
+			ctx.Abort()
+			return
+		}
+
+		if !requireSession(ctx) {
+			ctx.Abort()
+			return
+		}
+
+		ctx.Set("admin", user)
+		ctx.Next()
+	}
+}
+
+func adminUser(ctx *gin.Context) model.User {
+	return ctx.MustGet("admin").(model.User)
+}
+
+// auditAdmin records an admin action against the user it affected.
+func auditAdmin(ctx *gin.Context, userID *uuid.UUID, action string, detail string) {
+	admin := adminUser(ctx)
+
+	if err := database.DB.Create(&model.AuditLog{
+		UserID: userID,
+		Action: action,
+		IP:     ctx.ClientIP(),
+		Detail: fmt.Sprintf("%s by admin %s", detail, admin.Username),
+	}).Error; err != nil {
+		log.Printf("audit: failed to record %s: %v", action, err)
+	}
+}
+
+// targetUser loads the user named by the :id parameter.
+func targetUser(ctx *gin.Context) (model.User, bool) {
+	var user model.User
+
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "user id is not valid")
+		return user, false
+	}
+
+	if err := database.DB.First(&user, "id = ?", id).Error; err != nil {
+		helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "user is not found")
+		return user, false
+	}
+
+	return user, true
+}
+
+func AdminGetUsers() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		page, limit := helpers.Pagination(ctx)
+
+		query := database.DB.Model(&model.User{})
+		if q := ctx.Query("q"); q != "" {
+			like := "%" + helpers.EscapeLike(q) + "%"
+			query = query.Where("username LIKE ? OR email LIKE ? OR name LIKE ?", like, like, like)
+		}
+
+		switch ctx.Query("status") {
+		case "disabled":
+			query = query.Where("disabled_at IS NOT NULL")
+		case "active":
+			query = query.Where("disabled_at IS NULL")
+		}
+
+		if role := ctx.Query("role"); role != "" {
+			query = query.Where("role = ?", role)
+		}
+
+		query = query.Session(&gorm.Session{})
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get users")
+			return
+		}
+
+		var users []model.User
+		if err := query.Order("created_at DESC").Limit(limit).Offset((page - 1) * limit).Find(&users).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get users")
+			return
+		}
+
+		data := helpers.Page{
+			Items: users,
+			Page:  page,
+			Limit: limit,
+			Total: total,
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, data, "success get users")
+	}
+}
+
+func AdminDisableUser() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := targetUser(ctx)
+		if !ok {
+			return
+		}
+
+		if user.ID == adminUser(ctx).ID {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "you can not disable your own account")
+			return
+		}
+
+		if user.DisabledAt != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "user is already disabled")
+			return
+		}
+
+		if err := database.DB.Model(&user).Update("disabled_at", time.Now()).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to disable user")
+			return
+		}
+
+		if err := revokeSessions("user_id = ?", user.ID); err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to revoke sessions")
+			return
+		}
+
+		auditAdmin(ctx, &user.ID, "user_disabled", user.Username+" disabled")
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, user, "success disable user")
+	}
+}
+
+func AdminEnableUser() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := targetUser(ctx)
+		if !ok {
+			return
+		}
+
+		if user.DisabledAt == nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "user is not disabled")
+			return
+		}
+
+		if err := database.DB.Model(&user).Update("disabled_at", nil).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to enable user")
+			return
+		}
+
+		auditAdmin(ctx, &user.ID, "user_enabled", user.Username+" enabled")
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, user, "success enable user")
+	}
+}
+
+func AdminResetPassword() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.AdminResetPassword
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := targetUser(ctx)
+		if !ok {
+			return
+		}
+
+		if req.Password == "" {
+			if err := sendPasswordResetEmail(user); err != nil {
+				helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to send reset email")
+				return
+			}
+
+			auditAdmin(ctx, &user.ID, "password_reset_sent", "reset link sent to "+user.Email)
+
+			helpers.ResponseJson(ctx, http.StatusOK, true, nil, "reset link has been sent")
+			return
+		}
+
+		if err := database.DB.Model(&user).Update("password", crypto.HashPassword(req.Password)).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to reset password")
+			return
+		}
+
+		if err := revokeSessions("user_id = ?", user.ID); err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to revoke sessions")
+			return
+		}
+
+		clearLoginFailures(user.Email)
+
+		auditAdmin(ctx, &user.ID, "password_reset", "password of "+user.Username+" changed")
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success reset password")
+	}
+}
+
+func AdminLogoutUser() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := targetUser(ctx)
+		if !ok {
+			return
+		}
+
+		if err := revokeSessions("user_id = ?", user.ID); err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to revoke sessions")
+			return
+		}
+
+		auditAdmin(ctx, &user.ID, "sessions_revoked", "all sessions of "+user.Username+" revoked")
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success logout user")
+	}
+}
+
+func AdminChangeRole() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.ChangeRole
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := targetUser(ctx)
+		if !ok {
+			return
+		}
+
+		// keeps at least one admin around
+		if user.ID == adminUser(ctx).ID && req.Role != model.RoleAdmin {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "you can not remove your own admin role")
+			return
+		}
+
+		before := user.Role
+		if err := database.DB.Model(&user).Update("role", req.Role).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to change role")
+			return
+		}
+
+		auditAdmin(ctx, &user.ID, "role_changed", fmt.Sprintf("role of %s changed from %s to %s", user.Username, before, req.Role))
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, user, "success change role")
+	}
+}
+
+func AdminGetBoards() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		page, limit := helpers.Pagination(ctx)
+
+		query := database.DB.Model(&model.Board{})
+		if q := ctx.Query("q"); q != "" {
+			query = query.Where("name LIKE ?", "%"+helpers.EscapeLike(q)+"%")
+		}
+
+		if ownerID := ctx.Query("owner_id"); ownerID != "" {
+			parsedID, err := uuid.Parse(ownerID)
+			if err != nil {
+				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "owner id is not valid")
+				return
+			}
+			query = query.Where("owner_id = ?", parsedID)
+		}
+
+		query = query.Session(&gorm.Session{})
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get boards")
+			return
+		}
+
+		var boards []model.Board
+		if err := query.
+			Preload("Members").
+			Order("created_at DESC").
+			Limit(limit).
+			Offset((page - 1) * limit).
+			Find(&boards).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get boards")
+			return
+		}
+
+		data := helpers.Page{
+			Items: boards,
+			Page:  page,
+			Limit: limit,
+			Total: total,
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, data, "success get boards")
+	}
+}
+
+func AdminTransferBoard() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.TransferBoard
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		boardID, err := uuid.Parse(ctx.Param("id"))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board id is not valid")
+			return
+		}
+
+		var board model.Board
+		if err := database.DB.First(&board, "id = ?", boardID).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "board is not found")
+			return
+		}
+
+		var owner model.User
+		if err := database.DB.First(&owner, "id = ?", req.UserID).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "user is not found")
+			return
+		}
+
+		before := map[string]any{"owner_id": board.OwnerID}
+
+		// the new owner has to be able to open the board
+		if err := setBoardOwner(board, owner, true); err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to transfer board")
+			return
+		}
+
+		recordActivity(board.ID, adminUser(ctx), "board", board.ID, "owner_changed", before, map[string]any{"owner_id": owner.ID})
+		auditAdmin(ctx, &owner.ID, "board_transferred", fmt.Sprintf("board %s transferred to %s", board.ID, owner.Username))
+
+		if err := broadcastBoard(board.ID); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board is not found")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, board, "success transfer board")
+	}
+}
@@ -5,14 +5,18 @@ import (
 	"kerjainaja/database"
 	"kerjainaja/helpers"
 	"kerjainaja/model"
+	"log"
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 func Login() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		if !passwordLoginAllowed(ctx) {
+			return
+		}
+
 		var u model.Login
 
 		if err := ctx.ShouldBindJSON(&u); err != nil {
@@ -22,17 +26,22 @@ func Login() gin.HandlerFunc {
 
 		tokenHeader := ctx.Request.Header.Get("Authorization")
 
-		if tokenHeader != "" {
+		if len(tokenHeader) > len("Bearer ") {
 			tokenHeader = tokenHeader[len("Bearer "):]
-			_, err := helpers.ParseAndValidateToken(tokenHeader)
+			_, err := validateSessionToken(tokenHeader)
 			if err == nil {
 				helpers.ResponseJson(ctx, http.StatusOK, true, nil, "sudah login")
 				return
 			}
 		}
 
+		if !checkLoginLock(ctx, u.Email) {
+			return
+		}
+
 		var user model.User
 		if err := database.DB.Where("email = ?", u.Email).First(&user).Error; err != nil {
+			recordLoginFailure(ctx, u.Email, nil)
 			helpers.ResponseJson(ctx, http.StatusNotAcceptable, false, nil, "email / password salah")
 			return
 		}
@@ -40,18 +49,33 @@ func Login() gin.HandlerFunc {
 		// hashed := crypto.HashPassword(u.Password)
 
 		if err := crypto.ValidatePassword(user.Password, u.Password); err != nil {
+			recordLoginFailure(ctx, u.Email, &user.ID)
 			helpers.ResponseJson(ctx, http.StatusNotAcceptable, false, nil, "email / password salah")
 			return
 		}
 
-		tokenJwt, err := helpers.CreateTokenSession(user.ID, user.Username, user.Role, time.Now().Add(24*time.Hour))
-		if err != nil {
-			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "Gagal membuat token jwt : "+err.Error())
+		if user.DisabledAt != nil {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "akun dinonaktifkan")
 			return
 		}
 
-		data := map[string]string{
-			"token": tokenJwt,
+		if user.TOTPEnabled {
+			data, err := twoFactorChallenge(user)
+			if err != nil {
+				helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "Gagal membuat token jwt : "+err.Error())
+				return
+			}
+
+			helpers.ResponseJson(ctx, http.StatusOK, true, data, "masukkan kode two factor")
+			return
+		}
+
+		clearLoginFailures(user.Email)
+
+		data, err := issueSession(ctx, user)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "Gagal membuat token jwt : "+err.Error())
+			return
 		}
 
 		helpers.ResponseJson(ctx, http.StatusOK, true, data, "Sukses login!")
@@ -60,6 +84,10 @@ func Login() gin.HandlerFunc {
 
 func Register() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		if !passwordLoginAllowed(ctx) {
+			return
+		}
+
 		var reg model.Register
 
 		if err := ctx.ShouldBindJSON(&reg); err != nil {
@@ -94,6 +122,10 @@ func Register() gin.HandlerFunc {
 			return
 		}
 
+		if err := sendVerificationEmail(newUser); err != nil {
+			log.Printf("mailer: failed to send verification email to %s: %v", newUser.Email, err)
+		}
+
 		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "Success register")
 	}
 }
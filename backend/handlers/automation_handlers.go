@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"errors"
+	"kerjainaja/automation"
+	"kerjainaja/database"
+	"kerjainaja/helpers"
+	"kerjainaja/model"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// findAutomationRule loads the rule named by the :id parameter for an admin
+// of its board.
+func findAutomationRule(ctx *gin.Context, user model.User) (model.AutomationRule, bool) {
+	var rule model.AutomationRule
+
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "rule id is not valid")
+		return rule, false
+	}
+
+	if err := database.DB.First(&rule, "id = ?", id).Error; err != nil {
+		helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "rule is not found")
+		return rule, false
+	}
+
+	var board model.Board
+	if err := database.DB.First(&board, "id = ?", rule.BoardID).Error; err != nil || !isBoardAdmin(board, user.ID) {
+		helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "rule is not found")
+		return rule, false
+	}
+
+	return rule, true
+}
+
+// setAutomationRule copies a request into a rule and checks that the
+// columns, labels and users it names are on the rule's board.
+func setAutomationRule(rule *model.AutomationRule, req model.AutomationRuleRequest) error {
+	rule.Name = req.Name
+	rule.Trigger = req.Trigger
+	rule.Conditions = req.Conditions
+	rule.Actions = req.Actions
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+
+	if err := automation.Validate(*rule); err != nil {
+		return err
+	}
+
+	var columnIDs, labelIDs []uuid.UUID
+	userIDs := []string{}
+
+	if rule.Trigger.ColumnID != nil {
+		columnIDs = append(columnIDs, *rule.Trigger.ColumnID)
+	}
+	if rule.Trigger.LabelID != nil {
+		labelIDs = append(labelIDs, *rule.Trigger.LabelID)
+	}
+	labelIDs = append(labelIDs, rule.Conditions.LabelIDs...)
+	for _, id := range rule.Conditions.MemberIDs {
+		userIDs = append(userIDs, id.String())
+	}
+
+	for _, action := range rule.Actions {
+		switch action.Type {
+		case automation.ActionMoveCard:
+			columnIDs = append(columnIDs, *action.ColumnID)
+		case automation.ActionAddLabel:
+			labelIDs = append(labelIDs, *action.LabelID)
+		case automation.ActionAssignUser:
+			userIDs = append(userIDs, action.UserID.String())
+		}
+	}
+
+	columnIDs = uniqueIDs(columnIDs)
+	if len(columnIDs) > 0 {
+		var count int64
+		database.DB.Model(&model.Column{}).Where("board_id = ? AND id IN ?", rule.BoardID, columnIDs).Count(&count)
+		if int(count) != len(columnIDs) {
+			return errors.New("column is not found on this board")
+		}
+	}
+
+	labelIDs = uniqueIDs(labelIDs)
+	if len(labelIDs) > 0 {
+		var count int64
+		database.DB.Model(&model.Label{}).Where("board_id = ? AND id IN ?", rule.BoardID, labelIDs).Count(&count)
+		if int(count) != len(labelIDs) {
+			return errors.New("label is not found on this board")
+		}
+	}
+
+	if len(userIDs) > 0 {
+		if _, err := boardMembersByID(rule.BoardID, userIDs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func uniqueIDs(ids []uuid.UUID) []uuid.UUID {
+	unique := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !slices.Contains(unique, id) {
+			unique = append(unique, id)
+		}
+	}
+
+	return unique
+}
+
+func automationSnapshot(rule model.AutomationRule) map[string]any {
+	return map[string]any{
+		"name":    rule.Name,
+		"trigger": rule.Trigger.Type,
+		"enabled": rule.Enabled,
+	}
+}
+
+func GetAutomationRules() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		board, ok := adminBoard(ctx, user)
+		if !ok {
+			return
+		}
+
+		var rules []model.AutomationRule
+		if err := database.DB.Where("board_id = ?", board.ID).Order("created_at").Find(&rules).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get rules")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, rules, "success get rules")
+	}
+}
+
+func CreateAutomationRule() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.AutomationRuleRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok || !requireSession(ctx) {
+			return
+		}
+
+		board, ok := adminBoard(ctx, user)
+		if !ok {
+			return
+		}
+
+		rule := model.AutomationRule{
+			BoardID:     board.ID,
+			Enabled:     true,
+			CreatedByID: user.ID,
+		}
+
+		if err := setAutomationRule(&rule, req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+			return
+		}
+
+		if err := database.DB.Create(&rule).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to create rule")
+			return
+		}
+
+		recordActivity(board.ID, user, "automation", rule.ID, "created", nil, automationSnapshot(rule))
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, rule, "success create rule")
+	}
+}
+
+func UpdateAutomationRule() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.AutomationRuleRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		rule, ok := findAutomationRule(ctx, user)
+		if !ok {
+			return
+		}
+
+		before := automationSnapshot(rule)
+
+		if err := setAutomationRule(&rule, req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+			return
+		}
+
+		if err := database.DB.Model(&rule).Select("name", "trigger_event", "conditions", "actions", "enabled").Updates(&rule).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to update rule")
+			return
+		}
+
+		recordActivity(rule.BoardID, user, "automation", rule.ID, "updated", before, automationSnapshot(rule))
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, rule, "success update rule")
+	}
+}
+
+func DeleteAutomationRule() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		rule, ok := findAutomationRule(ctx, user)
+		if !ok {
+			return
+		}
+
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("rule_id = ?", rule.ID).Delete(&model.AutomationRun{}).Error; err != nil {
+				return err
+			}
+
+			return tx.Delete(&rule).Error
+		})
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to delete rule")
+			return
+		}
+
+		recordActivity(rule.BoardID, user, "automation", rule.ID, "deleted", automationSnapshot(rule), nil)
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success delete rule")
+	}
+}
+
+// GetAutomationRuns lists the execution log of a board's rules, newest
+// first. It can be narrowed with rule_id, card_id and status.
+func GetAutomationRuns() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		board, ok := adminBoard(ctx, user)
+		if !ok {
+			return
+		}
+
+		query := database.DB.Model(&model.AutomationRun{}).Where("board_id = ?", board.ID)
+		for _, param := range []string{"rule_id", "card_id"} {
+			if value := ctx.Query(param); value != "" {
+				id, err := uuid.Parse(value)
+				if err != nil {
+					helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, param+" is not valid")
+					return
+				}
+				query = query.Where(param+" = ?", id)
+			}
+		}
+		if status := ctx.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		query = query.Session(&gorm.Session{})
+
+		page, limit := helpers.Pagination(ctx)
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get runs")
+			return
+		}
+
+		var runs []model.AutomationRun
+		if err := query.Order("created_at DESC").Limit(limit).Offset((page - 1) * limit).Find(&runs).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get runs")
+			return
+		}
+
+		data := helpers.Page{
+			Items: runs,
+			Page:  page,
+			Limit: limit,
+			Total: total,
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, data, "success get runs")
+	}
+}
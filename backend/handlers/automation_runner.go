@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kerjainaja/automation"
+	"kerjainaja/database"
+	"kerjainaja/model"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// runAutomations runs the rules a card activity triggers. It runs before the
+// handler that made the change responds, so the rules' changes go out with
+// it.
+func runAutomations(chain *automation.Chain, activity model.Activity) {
+	if activity.EntityType != "card" {
+		return
+	}
+
+	trigger, ok := automation.TriggerOf(activity.Action)
+	if !ok {
+		return
+	}
+
+	var rules []model.AutomationRule
+	if err := database.DB.Where("board_id = ? AND enabled = ?", activity.BoardID, true).Order("created_at").Find(&rules).Error; err != nil {
+		log.Printf("automation: failed to get the rules of board %s: %v", activity.BoardID, err)
+		return
+	}
+
+	rules = slices.DeleteFunc(rules, func(r model.AutomationRule) bool { return r.Trigger.Type != trigger })
+	if len(rules) == 0 {
+		return
+	}
+
+	var card model.Card
+	if err := database.DB.Preload("Members").Preload("Labels").First(&card, "id = ?", activity.EntityID).Error; err != nil {
+		return
+	}
+
+	event := automation.Event{Trigger: trigger, Card: card}
+	if trigger == automation.TriggerLabelAdded {
+		event.LabelIDs = addedLabels(activity)
+	}
+
+	if runRules(chain, activity.BoardID, rules, event) && chain.Depth == 0 {
+		if err := broadcastBoard(activity.BoardID); err != nil {
+			log.Printf("automation: failed to broadcast board %s: %v", activity.BoardID, err)
+		}
+	}
+}
+
+// addedLabels finds the labels a label_added activity added from the label
+// names in its changes.
+func addedLabels(activity model.Activity) []uuid.UUID {
+	var changes map[string]json.RawMessage
+	if err := json.Unmarshal(activity.Changes, &changes); err != nil {
+		return nil
+	}
+
+	var labels struct {
+		Before []string `json:"before"`
+		After  []string `json:"after"`
+	}
+	if err := json.Unmarshal(changes["labels"], &labels); err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, name := range labels.After {
+		if !slices.Contains(labels.Before, name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	var ids []uuid.UUID
+	database.DB.Model(&model.Label{}).Where("board_id = ? AND name IN ?", activity.BoardID, names).Pluck("id", &ids)
+	return ids
+}
+
+// runRules runs the rules matching an event and logs every run. It reports
+// whether any rule ran.
+func runRules(chain *automation.Chain, boardID uuid.UUID, rules []model.AutomationRule, event automation.Event) bool {
+	ran := false
+
+	for _, rule := range rules {
+		if !automation.Matches(rule, event) {
+			continue
+		}
+
+		run := model.AutomationRun{
+			RuleID:  rule.ID,
+			BoardID: boardID,
+			CardID:  event.Card.ID,
+			Event:   event.Trigger,
+			Depth:   chain.Depth,
+			Status:  model.AutomationSucceeded,
+		}
+
+		if err := chain.Enter(rule.ID, event.Card.ID); err != nil {
+			run.Status = model.AutomationSkipped
+			run.Error = err.Error()
+		} else {
+			ran = true
+			steps, err := applyRule(chain.Next(), rule, event.Card.ID)
+			run.Steps = steps
+			if err != nil {
+				run.Status = model.AutomationFailed
+				run.Error = err.Error()
+			}
+		}
+
+		if err := database.DB.Create(&run).Error; err != nil {
+			log.Printf("automation: failed to log run of rule %s: %v", rule.ID, err)
+		}
+	}
+
+	return ran
+}
+
+// applyRule takes the actions of a rule one by one, on behalf of its
+// creator. The card is loaded again before every action since the rules an
+// action triggers may have changed it. A failed action stops the rule, the
+// ones before it stay done.
+func applyRule(chain *automation.Chain, rule model.AutomationRule, cardID uuid.UUID) ([]string, error) {
+	steps := []string{}
+
+	var actor model.User
+	if err := database.DB.First(&actor, "id = ? AND disabled_at IS NULL", rule.CreatedByID).Error; err != nil || !isBoardMember(rule.BoardID, actor.ID) {
+		return steps, errors.New("the creator of this rule no longer has access to the board")
+	}
+
+	for _, action := range rule.Actions {
+		var card model.Card
+		if err := database.DB.Preload("Members").Preload("Watchers").Preload("Labels").First(&card, "id = ?", cardID).Error; err != nil {
+			return steps, errors.New("card is not found")
+		}
+
+		step, err := applyAction(chain, rule, actor, card, action)
+		if err != nil {
+			return steps, fmt.Errorf("%s: %w", action.Type, err)
+		}
+		steps = append(steps, action.Type+": "+step)
+	}
+
+	return steps, nil
+}
+
+func applyAction(chain *automation.Chain, rule model.AutomationRule, actor model.User, card model.Card, action model.AutomationAction) (string, error) {
+	who := "rule " + rule.Name
+	before := cardSnapshot(card)
+
+	record := func(activity string) {
+		after := cardSnapshot(card)
+		after["automation"] = rule.Name
+		recordChainActivity(chain, rule.BoardID, actor, "card", card.ID, activity, before, after)
+	}
+
+	switch action.Type {
+	case automation.ActionAssignUser:
+		assignees, err := boardMembersByID(rule.BoardID, []string{action.UserID.String()})
+		if err != nil {
+			return "", err
+		}
+
+		assignee := assignees[0]
+		if containsUser(card.Members, assignee.ID) {
+			return assignee.Username + " already assigned", nil
+		}
+
+		if err := database.DB.Model(&card).Association("Members").Append(&assignee); err != nil {
+			return "", errors.New("failed to assign")
+		}
+
+		record("assigned")
+		notifyWatchers(card, fmt.Sprintf("%s assigned %s to %s", who, assignee.Username, card.Title))
+		return "assigned " + assignee.Username, nil
+
+	case automation.ActionSetDueDate:
+		var dueDate *time.Time
+		if action.DueInDays != nil {
+			due := time.Now().AddDate(0, 0, *action.DueInDays)
+			dueDate = &due
+		}
+
+		if err := database.DB.Model(&card).Update("due_date", dueDate).Error; err != nil {
+			return "", errors.New("failed to update due date")
+		}
+		card.DueDate = dueDate
+
+		record("updated")
+		notifyWatchers(card, fmt.Sprintf("%s updated %s", who, card.Title))
+		if dueDate == nil {
+			return "cleared the due date", nil
+		}
+		return "set the due date to " + dueDate.Format(time.RFC3339), nil
+
+	case automation.ActionAddLabel:
+		var label model.Label
+		if err := database.DB.First(&label, "id = ? AND board_id = ?", action.LabelID, rule.BoardID).Error; err != nil {
+			return "", errors.New("label is not found on this board")
+		}
+
+		if slices.ContainsFunc(card.Labels, func(l model.Label) bool { return l.ID == label.ID }) {
+			return "label " + label.Name + " already added", nil
+		}
+
+		if err := database.DB.Model(&card).Association("Labels").Append(&label); err != nil {
+			return "", errors.New("failed to add label")
+		}
+
+		record("label_added")
+		notifyWatchers(card, fmt.Sprintf("%s added label %s to %s", who, label.Name, card.Title))
+		return "added label " + label.Name, nil
+
+	case automation.ActionMoveCard:
+		var column model.Column
+		if err := database.DB.First(&column, "id = ? AND board_id = ?", action.ColumnID, rule.BoardID).Error; err != nil {
+			return "", errors.New("column is not found on this board")
+		}
+
+		if card.ColumnID == column.ID {
+			return "already in " + column.Name, nil
+		}
+
+		var wip *model.WIPStatus
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			// an archived card doesn't count against the limit
+			if !card.Archived {
+				var err error
+				if column, wip, err = admitCard(tx, column.ID); err != nil {
+					return err
+				}
+			}
+
+			return tx.Model(&card).Update("column_id", column.ID).Error
+		})
+		if errors.Is(err, errWIPLimit) {
+			return "", err
+		}
+		if err != nil {
+			return "", errors.New("failed to move card")
+		}
+		card.ColumnID = column.ID
+
+		record("moved")
+		notifyWatchers(card, fmt.Sprintf("%s moved %s", who, card.Title))
+		warnWIP(column, wip)
+		return "moved to " + column.Name, nil
+
+	case automation.ActionPostComment:
+		comment := model.Comment{
+			CardID:         card.ID,
+			AuthorID:       &actor.ID,
+			AuthorUsername: actor.Username,
+			Body:           action.Comment,
+		}
+
+		if err := database.DB.Create(&comment).Error; err != nil {
+			return "", errors.New("failed to create comment")
+		}
+
+		recordChainActivity(chain, rule.BoardID, actor, "card", card.ID, "commented", nil, map[string]any{"comment": comment.Body, "automation": rule.Name})
+		notifyWatchers(card, fmt.Sprintf("%s commented on %s", who, card.Title))
+		return "posted a comment", nil
+
+	case automation.ActionArchive:
+		if card.Archived {
+			return "already archived", nil
+		}
+
+		if err := database.DB.Model(&card).Update("archived", true).Error; err != nil {
+			return "", errors.New("failed to archive card")
+		}
+		card.Archived = true
+
+		record("updated")
+		notifyWatchers(card, fmt.Sprintf("%s archived %s", who, card.Title))
+		return "archived", nil
+	}
+
+	return "", errors.New("unknown action")
+}
+
+// RunDueDateAutomations runs the due_date_passed rules every interval until
+// ctx is done.
+func RunDueDateAutomations(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			runDueDateRules(time.Now())
+		}
+	}
+}
+
+// runDueDateRules runs every due_date_passed rule on the cards that became
+// overdue since the rule was made. The conditions are checked once, when
+// the due date passes, and a card is only picked up again when its due date
+// moves past the last run of the rule on it.
+func runDueDateRules(now time.Time) {
+	var rules []model.AutomationRule
+	if err := database.DB.Where("enabled = ?", true).Find(&rules).Error; err != nil {
+		log.Printf("automation: failed to get rules: %v", err)
+		return
+	}
+
+	for _, rule := range rules {
+		if rule.Trigger.Type != automation.TriggerDueDatePassed {
+			continue
+		}
+
+		var cards []model.Card
+		err := database.DB.Preload("Members").Preload("Labels").
+			Joins("JOIN columns ON columns.id = cards.column_id").
+			Where("columns.board_id = ? AND cards.archived = ? AND cards.due_date <= ? AND cards.due_date > ?", rule.BoardID, false, now, rule.CreatedAt).
+			Where("NOT EXISTS (SELECT 1 FROM automation_runs WHERE automation_runs.rule_id = ? AND automation_runs.card_id = cards.id AND automation_runs.created_at >= cards.due_date)", rule.ID).
+			Limit(100).
+			Find(&cards).Error
+		if err != nil {
+			log.Printf("automation: failed to get overdue cards of rule %s: %v", rule.ID, err)
+			continue
+		}
+
+		ran := false
+		for _, card := range cards {
+			event := automation.Event{Trigger: automation.TriggerDueDatePassed, Card: card}
+			if !automation.Matches(rule, event) {
+				run := model.AutomationRun{
+					RuleID:  rule.ID,
+					BoardID: rule.BoardID,
+					CardID:  card.ID,
+					Event:   event.Trigger,
+					Status:  model.AutomationSkipped,
+					Error:   "card does not meet the conditions",
+				}
+				if err := database.DB.Create(&run).Error; err != nil {
+					log.Printf("automation: failed to log run of rule %s: %v", rule.ID, err)
+				}
+				continue
+			}
+
+			if runRules(automation.NewChain(), rule.BoardID, []model.AutomationRule{rule}, event) {
+				ran = true
+			}
+		}
+
+		if ran {
+			if err := broadcastBoard(rule.BoardID); err != nil {
+				log.Printf("automation: failed to broadcast board %s: %v", rule.BoardID, err)
+			}
+		}
+	}
+}
@@ -2,15 +2,41 @@ package handlers
 
 import (
 	"kerjainaja/database"
+	"kerjainaja/filter"
 	"kerjainaja/helpers"
 	"kerjainaja/model"
+	"kerjainaja/templates"
 	"net/http"
 	"slices"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+// newBoardWorkspace checks the user may create a board, and may add it to the
+// workspace when workspaceID is set. Every way of making a board goes through
+// it, the workspace is nil for a board outside of one.
+func newBoardWorkspace(ctx *gin.Context, user model.User, workspaceID string) (*model.Workspace, bool) {
+	if emailVerificationRequired() && user.EmailVerifiedAt == nil {
+		helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "verify your email before creating boards")
+		return nil, false
+	}
+
+	if workspaceID == "" {
+		return nil, true
+	}
+
+	workspace, ok := boardWorkspace(ctx, user, workspaceID)
+	if !ok {
+		return nil, false
+	}
+
+	return &workspace, true
+}
+
 func CreateBoard() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var req model.CreateBoard
@@ -20,107 +46,109 @@ func CreateBoard() gin.HandlerFunc {
 			return
 		}
 
-		tokenHeader := ctx.Request.Header.Get("Authorization")
-		if tokenHeader == "" {
-			helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, "access denied")
+		user, ok := currentUser(ctx)
+		if !ok {
 			return
 		}
 
-		token := tokenHeader[len("Bearer "):]
-
-		claims, err := helpers.ParseAndValidateToken(token)
-		if err != nil {
-			helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, err.Error())
+		workspace, ok := newBoardWorkspace(ctx, user, req.WorkspaceID)
+		if !ok {
 			return
 		}
 
-		userID := claims["sub"]
+		board := model.Board{
+			Name:       req.Name,
+			OwnerID:    &user.ID,
+			Visibility: model.VisibilityPrivate,
+			KeyPrefix:  strings.ToUpper(req.KeyPrefix),
+		}
 
-		var user model.User
-		if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
-			helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, "user is not found")
+		if board.KeyPrefix != "" && !model.ValidKeyPrefix(board.KeyPrefix) {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "key prefix must be 2 to 10 letters or digits and start with a letter")
 			return
 		}
 
-		board := model.Board{
-			Name: req.Name,
+		if workspace != nil {
+			board.WorkspaceID = &workspace.ID
+			board.Visibility = workspace.DefaultVisibility
+			if req.Visibility != "" {
+				board.Visibility = req.Visibility
+			}
 		}
 
-		if err := database.DB.Create(&board).Error; err != nil {
-			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to create board")
-			return
+		var content templates.Content
+		if req.TemplateID != "" {
+			var err error
+			if content, err = templateContent(user, req.TemplateID); err != nil {
+				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+				return
+			}
 		}
 
-		if err := database.DB.Model(&board).Association("Members").Append(&user); err != nil {
-			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to association : "+err.Error())
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&board).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Model(&board).Omit("Members.*").Association("Members").Append(&user); err != nil {
+				return err
+			}
+
+			return templates.Apply(tx, board.ID, content)
+		})
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to create board")
 			return
 		}
 
+		recordActivity(board.ID, user, "board", board.ID, "created", nil, boardSnapshot(board))
+
 		helpers.ResponseJson(ctx, http.StatusOK, true, board, "Success create new board")
 	}
 }
 
 func GetBoard() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		tokenHeader := ctx.Request.Header.Get("Authorization")
-		if tokenHeader == "" {
-			helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, "Access Denied")
-			return
-		}
-
-		token := tokenHeader[len("Bearer "):]
-		if token == "" {
-			helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, "Access Denied")
-			return
-		}
-
-		claims, err := helpers.ParseAndValidateToken(token)
-		if err != nil {
-			helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, err.Error())
+		user, ok := currentUser(ctx)
+		if !ok {
 			return
 		}
 
-		userID := claims["sub"]
-
-		var user model.User
-		if err := database.DB.Preload("Boards").First(&user, "id = ?", userID).Error; err != nil {
-			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "user not found")
+		boards := []model.Board{}
+		if err := database.DB.Model(&user).Association("Boards").Find(&boards); err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get boards")
 			return
 		}
 
 		data := map[string]any{
-			"boards": user.Boards,
+			"boards": boards,
 		}
 
 		helpers.ResponseJson(ctx, http.StatusOK, true, data, "success get boards")
 	}
 }
 
-func GetBoards() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		tokenHeader := ctx.Request.Header.Get("Authorization")
-		if tokenHeader == "" {
-			helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, "Access Denied")
-			return
-		}
-
-		token := tokenHeader[len("Bearer "):]
-		if token == "" {
-			helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, "Access Denied")
-			return
-		}
+// canJoinBoard reports whether opening a board makes the user a member.
+// Anyone with the link can join a board outside a workspace, workspace
+// members can join its workspace boards, and private workspace boards
+// only take members that are added to them.
+func canJoinBoard(board model.Board, user model.User) bool {
+	if board.WorkspaceID == nil {
+		return true
+	}
 
-		claims, err := helpers.ParseAndValidateToken(token)
-		if err != nil {
-			helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, err.Error())
-			return
-		}
+	if board.Visibility != model.VisibilityWorkspace {
+		return false
+	}
 
-		userID := claims["sub"]
+	_, ok := workspaceMembership(*board.WorkspaceID, user.ID)
+	return ok
+}
 
-		var user model.User
-		if err := database.DB.Preload("Boards").First(&user, "id = ?", userID).Error; err != nil {
-			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "user not found")
+func GetBoards() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
 			return
 		}
 
@@ -132,12 +160,16 @@ func GetBoards() gin.HandlerFunc {
 			return
 		}
 
-		var board model.Board
-		if err := database.DB.
-			Preload("Members").
-			Preload("Columns.Cards").
-			Preload("Columns.Cards.Members").
-			First(&board, "id = ?", parsedID).Error; err != nil {
+		cardFilter, err := requestFilter(ctx, user, parsedID)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+			return
+		}
+
+		board, err := loadBoard(parsedID, func(db *gorm.DB) *gorm.DB {
+			return cardFilter.Apply(db, filter.Context{Now: time.Now(), Username: user.Username})
+		})
+		if err != nil {
 			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "board not found")
 			return
 		}
@@ -146,11 +178,24 @@ func GetBoards() gin.HandlerFunc {
 		if !slices.ContainsFunc(board.Members, func(m model.User) bool {
 			return m.ID == user.ID
 		}) {
+			if !canJoinBoard(board, user) {
+				helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this board")
+				return
+			}
+
+			// opening a board joins it, which a read-only token can't do
+			if !canWrite(ctx) {
+				helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "token is missing the "+model.ScopeWrite+" scope to join this board")
+				return
+			}
+
 			if err := database.DB.Model(&board).Association("Members").Append(&user); err != nil {
 				helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to append user")
 				return
 			}
 			isJoined = false
+
+			recordActivity(board.ID, user, "board", board.ID, "member_joined", nil, map[string]any{"member": user.Username})
 		}
 
 		data := model.ResponseBoards{
@@ -160,13 +205,12 @@ func GetBoards() gin.HandlerFunc {
 			Columns: board.Columns,
 		}
 
+		// the response may be filtered, other clients get the full board
 		if !isJoined {
-			broadcastData, err := helpers.CreateJsonBytes(data)
-			if err != nil {
-				panic(err)
+			if err := broadcastBoard(parsedID); err != nil {
+				helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "board not found")
+				return
 			}
-
-			BroadcastEventWithType("board_update", string(broadcastData))
 		}
 
 		helpers.ResponseJson(ctx, http.StatusOK, true, data, "success get boards")
@@ -175,10 +219,8 @@ func GetBoards() gin.HandlerFunc {
 
 func LeaveBoard() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-
-		tokenHeader := ctx.Request.Header.Get("Authorization")
-		if tokenHeader == "" {
-			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "token is empty")
+		user, ok := currentUser(ctx)
+		if !ok {
 			return
 		}
 
@@ -188,28 +230,6 @@ func LeaveBoard() gin.HandlerFunc {
 			return
 		}
 
-		token := tokenHeader[len("Bearer "):]
-
-		claims, err := helpers.ParseAndValidateToken(token)
-		if err != nil {
-			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "token is not valid")
-			return
-		}
-
-		userIDclaims := claims["sub"].(string)
-
-		userID, err := uuid.Parse(userIDclaims)
-		if err != nil {
-			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "userid is not valid")
-			return
-		}
-
-		var user model.User
-		if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
-			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "user is not found")
-			return
-		}
-
 		var board model.Board
 		if err := database.DB.Preload("Members").First(&board, "id = ?", id).Error; err != nil {
 			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board is not found")
@@ -228,11 +248,18 @@ func LeaveBoard() gin.HandlerFunc {
 			return
 		}
 
+		if board.OwnerID != nil && *board.OwnerID == user.ID && len(board.Members) > 1 {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "transfer the board ownership before leaving")
+			return
+		}
+
 		if err := database.DB.Model(&board).Association("Members").Delete(&user); err != nil {
 			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, err.Error())
 			return
 		}
 
+		recordActivity(board.ID, user, "board", board.ID, "member_left", map[string]any{"member": user.Username}, nil)
+
 		if err := database.DB.
 			Preload("Members").
 			Preload("Columns.Cards").
@@ -242,13 +269,208 @@ func LeaveBoard() gin.HandlerFunc {
 			return
 		}
 
-		jsonBytes, err := helpers.CreateJsonBytes(board)
+		BroadcastBoardEvent(board.ID, "board_update", board)
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "leave board")
+	}
+}
+
+// setBoardOwner hands a board to another user. With addMember the new owner
+// joins the board if they aren't a member yet.
+func setBoardOwner(board model.Board, owner model.User, addMember bool) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&board).Update("owner_id", owner.ID).Error; err != nil {
+			return err
+		}
+
+		if addMember && !isBoardMember(board.ID, owner.ID) {
+			return tx.Model(&board).Association("Members").Append(&owner)
+		}
+
+		return nil
+	})
+}
+
+// deleteBoard removes a board with its columns, cards, comments, labels,
+// saved filters, calendar feeds, webhooks, git integrations, automation
+// rules and activity.
+func deleteBoard(tx *gorm.DB, boardID uuid.UUID) error {
+	var cardIDs []uuid.UUID
+	if err := tx.Model(&model.Card{}).
+		Where("column_id IN (?)", tx.Model(&model.Column{}).Select("id").Where("board_id = ?", boardID)).
+		Pluck("id", &cardIDs).Error; err != nil {
+		return err
+	}
+
+	if len(cardIDs) > 0 {
+		for _, table := range []string{"card_members", "card_watchers", "card_labels"} {
+			if err := tx.Exec("DELETE FROM "+table+" WHERE card_id IN ?", cardIDs).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("card_id IN ?", cardIDs).Delete(&model.Comment{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("card_id IN ?", cardIDs).Delete(&model.CardCommit{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Where("id IN ?", cardIDs).Delete(&model.Card{}).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Where("board_id = ?", boardID).Delete(&model.Column{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("board_id = ?", boardID).Delete(&model.Label{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("board_id = ?", boardID).Delete(&model.SavedFilter{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("board_id = ?", boardID).Delete(&model.CalendarFeed{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("webhook_id IN (?)", tx.Model(&model.Webhook{}).Select("id").Where("board_id = ?", boardID)).Delete(&model.WebhookDelivery{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("board_id = ?", boardID).Delete(&model.Webhook{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("webhook_id IN (?)", tx.Model(&model.InboundWebhook{}).Select("id").Where("board_id = ?", boardID)).Delete(&model.InboundCard{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("board_id = ?", boardID).Delete(&model.InboundWebhook{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("board_id = ?", boardID).Delete(&model.GitIntegration{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("board_id = ?", boardID).Delete(&model.AutomationRun{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("board_id = ?", boardID).Delete(&model.AutomationRule{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("board_id = ?", boardID).Delete(&model.Activity{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Exec("DELETE FROM board_members WHERE board_id = ?", boardID).Error; err != nil {
+		return err
+	}
+
+	return tx.Delete(&model.Board{}, "id = ?", boardID).Error
+}
+
+func TransferBoard() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.TransferBoard
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		boardID, err := uuid.Parse(ctx.Param("id"))
 		if err != nil {
-			panic(err)
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board id is not valid")
+			return
 		}
 
-		BroadcastEventWithType("board_update", string(jsonBytes))
+		var board model.Board
+		if err := database.DB.First(&board, "id = ?", boardID).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "board is not found")
+			return
+		}
 
-		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "leave board")
+		if board.OwnerID == nil || *board.OwnerID != user.ID {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "only the board owner can transfer it")
+			return
+		}
+
+		owners, err := boardMembersByID(board.ID, []string{req.UserID})
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+			return
+		}
+
+		before := map[string]any{"owner_id": board.OwnerID}
+
+		if err := setBoardOwner(board, owners[0], false); err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to transfer board")
+			return
+		}
+
+		recordActivity(board.ID, user, "board", board.ID, "owner_changed", before, map[string]any{"owner_id": owners[0].ID})
+
+		if err := broadcastBoard(board.ID); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board is not found")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success transfer board")
+	}
+}
+
+// UpdateKeyPrefix changes the prefix of the board's card keys. Card numbers
+// stay the same, so KA-42 becomes OPS-42.
+func UpdateKeyPrefix() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.UpdateKeyPrefix
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		prefix := strings.ToUpper(req.KeyPrefix)
+		if !model.ValidKeyPrefix(prefix) {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "key prefix must be 2 to 10 letters or digits and start with a letter")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		board, ok := adminBoard(ctx, user)
+		if !ok {
+			return
+		}
+
+		before := map[string]any{"key_prefix": board.KeyPrefix}
+
+		if err := database.DB.Model(&board).Update("key_prefix", prefix).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to update key prefix")
+			return
+		}
+
+		recordActivity(board.ID, user, "board", board.ID, "key_prefix_changed", before, map[string]any{"key_prefix": prefix})
+
+		if err := broadcastBoard(board.ID); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board is not found")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, board, "success update key prefix")
 	}
 }
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"kerjainaja/config"
+	"kerjainaja/database"
+	"kerjainaja/helpers"
+	"kerjainaja/ical"
+	"kerjainaja/model"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// calendarHistory is how far back a feed still lists cards that were due.
+const calendarHistory = 365 * 24 * time.Hour
+
+// feedURL is the address calendar apps subscribe to.
+func feedURL(ctx *gin.Context, secret string) string {
+	return apiBaseURL(ctx) + "/calendar/" + secret + ".ics"
+}
+
+func GetCalendarFeeds() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		var feeds []model.CalendarFeed
+		if err := database.DB.Where("user_id = ? AND revoked_at IS NULL", user.ID).Order("created_at DESC").Find(&feeds).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get calendar feeds")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, feeds, "success get calendar feeds")
+	}
+}
+
+func CreateCalendarFeed() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.NewCalendarFeed
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		// a feed link is a credential too, so a leaked token can't make one
+		user, ok := currentUser(ctx)
+		if !ok || !requireSession(ctx) {
+			return
+		}
+
+		secret, hash, err := helpers.GenerateSecret(model.CalendarFeedPrefix)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to generate token")
+			return
+		}
+
+		feed := model.CalendarFeed{
+			UserID:    user.ID,
+			Prefix:    secret[:len(model.CalendarFeedPrefix)+6],
+			TokenHash: hash,
+		}
+
+		if req.BoardID != "" {
+			boardID := uuid.MustParse(req.BoardID)
+			if !isBoardMember(boardID, user.ID) {
+				helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this board")
+				return
+			}
+			feed.BoardID = &boardID
+		}
+
+		if err := database.DB.Create(&feed).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to create calendar feed")
+			return
+		}
+
+		data := map[string]any{
+			"url":  feedURL(ctx, secret),
+			"feed": feed,
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, data, "success create calendar feed, copy the link now because it will not be shown again")
+	}
+}
+
+func RevokeCalendarFeed() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		id, err := uuid.Parse(ctx.Param("id"))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "feed id is not valid")
+			return
+		}
+
+		result := database.DB.Model(&model.CalendarFeed{}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, user.ID).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to revoke calendar feed")
+			return
+		}
+
+		if result.RowsAffected == 0 {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "calendar feed is not found")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success revoke calendar feed")
+	}
+}
+
+// ServeCalendarFeed serves the feed behind a token. Calendar apps can't log in, so
+// the token in the path is the only credential. Cards are read on every
+// request, edited cards change in place through their UID and deleted or
+// archived ones drop out. Add ?type=todo for to-dos instead of events.
+func ServeCalendarFeed() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		secret := strings.TrimSuffix(ctx.Param("token"), ".ics")
+
+		var feed model.CalendarFeed
+		if err := database.DB.First(&feed, "token_hash = ? AND revoked_at IS NULL", helpers.HashSecret(secret)).Error; err != nil {
+			ctx.String(http.StatusNotFound, "calendar feed is not found")
+			return
+		}
+
+		var user model.User
+		if err := database.DB.First(&user, "id = ? AND disabled_at IS NULL", feed.UserID).Error; err != nil {
+			ctx.String(http.StatusNotFound, "calendar feed is not found")
+			return
+		}
+
+		// a board feed goes quiet once its owner leaves the board
+		if feed.BoardID != nil && !isBoardMember(*feed.BoardID, user.ID) {
+			ctx.String(http.StatusNotFound, "calendar feed is not found")
+			return
+		}
+
+		database.DB.Model(&feed).Update("last_used_at", time.Now())
+
+		cal, err := feedCalendar(feed, user)
+		if err != nil {
+			log.Printf("calendar: failed to build feed %s: %v", feed.ID, err)
+			ctx.String(http.StatusInternalServerError, "failed to build calendar feed")
+			return
+		}
+		cal.Todo = ctx.Query("type") == "todo"
+
+		ctx.Header("Content-Type", "text/calendar; charset=utf-8")
+		ctx.Header("Cache-Control", "no-store")
+		ctx.Status(http.StatusOK)
+		if err := cal.Write(ctx.Writer); err != nil {
+			log.Printf("calendar: failed to write feed %s: %v", feed.ID, err)
+		}
+	}
+}
+
+// feedCalendar lists the cards of a feed that have a due date.
+func feedCalendar(feed model.CalendarFeed, user model.User) (ical.Calendar, error) {
+	query := database.DB.
+		Preload("Labels").
+		Where("cards.due_date IS NOT NULL AND cards.due_date > ? AND cards.archived = ?", time.Now().Add(-calendarHistory), false).
+		Order("cards.due_date")
+
+	cal := ical.Calendar{Name: "kerjainaja - " + user.Username}
+	if feed.BoardID != nil {
+		var board model.Board
+		if err := database.DB.Select("name").First(&board, "id = ?", *feed.BoardID).Error; err != nil {
+			return cal, err
+		}
+		cal.Name = "kerjainaja - " + board.Name
+
+		query = query.Where("cards.column_id IN (SELECT id FROM columns WHERE board_id = ?)", *feed.BoardID)
+	} else {
+		query = query.
+			Where("cards.id IN (SELECT card_id FROM card_members WHERE user_id = ?)", user.ID).
+			Where("cards.column_id IN (SELECT columns.id FROM columns JOIN board_members ON board_members.board_id = columns.board_id WHERE board_members.user_id = ?)", user.ID)
+	}
+
+	var cards []model.Card
+	if err := query.Find(&cards).Error; err != nil {
+		return cal, err
+	}
+
+	type cardBoard struct {
+		ColumnID  uuid.UUID
+		BoardID   uuid.UUID
+		BoardName string
+	}
+
+	columnIDs := make([]uuid.UUID, 0, len(cards))
+	for _, card := range cards {
+		columnIDs = append(columnIDs, card.ColumnID)
+	}
+
+	var rows []cardBoard
+	if len(columnIDs) > 0 {
+		if err := database.DB.Table("columns").
+			Select("columns.id AS column_id, boards.id AS board_id, boards.name AS board_name").
+			Joins("JOIN boards ON boards.id = columns.board_id").
+			Where("columns.id IN ?", columnIDs).
+			Scan(&rows).Error; err != nil {
+			return cal, err
+		}
+	}
+
+	boards := make(map[uuid.UUID]cardBoard, len(rows))
+	for _, row := range rows {
+		boards[row.ColumnID] = row
+	}
+
+	appURL := config.Env("APP_URL")
+	for _, card := range cards {
+		board := boards[card.ColumnID]
+
+		item := ical.Item{
+			UID:         card.ID.String() + "@kerjainaja",
+			Summary:     card.Title,
+			Description: card.Description,
+			URL:         appURL + "/boards/" + board.BoardID.String(),
+			At:          *card.DueDate,
+			Created:     card.CreatedAt,
+			Modified:    card.UpdatedAt,
+		}
+		if feed.BoardID == nil {
+			item.Summary = "[" + board.BoardName + "] " + card.Title
+		}
+		for _, l := range card.Labels {
+			item.Categories = append(item.Categories, l.Name)
+		}
+
+		cal.Items = append(cal.Items, item)
+	}
+
+	return cal, nil
+}
@@ -1,13 +1,16 @@
 package handlers
 
 import (
+	"fmt"
 	"kerjainaja/database"
 	"kerjainaja/helpers"
 	"kerjainaja/model"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 func CreateNewCard() gin.HandlerFunc {
@@ -18,52 +21,66 @@ func CreateNewCard() gin.HandlerFunc {
 			return
 		}
 
-		tokenHeader := ctx.Request.Header.Get("Authorization")
-		if tokenHeader == "" {
-			helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, "token is empty")
+		user, ok := currentUser(ctx)
+		if !ok {
 			return
 		}
 
-		token := tokenHeader[len("Bearer "):]
-
-		claims, err := helpers.ParseAndValidateToken(token)
+		parsedColumnID, err := uuid.Parse(req.ColumnID)
 		if err != nil {
-			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "id is not valid")
 			return
 		}
 
-		userID := claims["sub"]
-
-		var user model.User
-		if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
-			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "user is not found")
+		var column model.Column
+		if err := database.DB.First(&column, "id = ?", parsedColumnID).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "column is not found")
 			return
 		}
 
-		parsedColumnID, err := uuid.Parse(req.ColumnID)
+		dueDate, err := helpers.ParseDate(req.DueDate)
 		if err != nil {
-			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "id is not valid")
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
 			return
 		}
 
-		var column model.Column
-		if err := database.DB.First(&column, "id = ?", parsedColumnID).Error; err != nil {
-			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "column is not found")
-			return
+		members := []model.User{user}
+		if len(req.MemberIDs) > 0 {
+			members, err = boardMembersByID(column.BoardID, req.MemberIDs)
+			if err != nil {
+				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+				return
+			}
 		}
 
 		newCard := model.Card{
 			Title:       req.Title,
 			Description: req.Description,
+			DueDate:     dueDate,
 			ColumnID:    column.ID,
-			Members:     []model.User{user},
+			Members:     members,
 		}
 
-		if err := database.DB.Create(&newCard).Error; err != nil {
+		var wip *model.WIPStatus
+		err = database.DB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if column, wip, err = admitCard(tx, column.ID); err != nil {
+				return err
+			}
+
+			return tx.Create(&newCard).Error
+		})
+		if wipResponse(ctx, err) {
+			return
+		}
+		if err != nil {
 			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "unable to make a card")
 			return
 		}
 
+		recordActivity(column.BoardID, user, "card", newCard.ID, "created", nil, cardSnapshot(newCard))
+		note := warnWIP(column, wip)
+
 		// var card model.Card
 		// if err := database.DB.Preload("Members").First(&card, "id = ?", newCard.ID).Error; err != nil {
 		// 	helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "card is not found")
@@ -83,44 +100,19 @@ func CreateNewCard() gin.HandlerFunc {
 			return
 		}
 
-		var board model.Board
-		if err := database.DB.Preload("Members").Preload("Columns").Preload("Columns.Cards").Preload("Columns.Cards.Members").First(&board, "id = ?", column.BoardID).Error; err != nil {
+		if err := broadcastBoard(column.BoardID); err != nil {
 			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board is not found")
 			return
 		}
 
-		jsonBytes, err := helpers.CreateJsonBytes(board)
-		if err != nil {
-			panic(err)
-		}
-
-		BroadcastEventWithType("board_update", string(jsonBytes))
-
-		helpers.ResponseJson(ctx, http.StatusOK, true, newCard, "success create new card")
+		helpers.ResponseJson(ctx, http.StatusOK, true, newCard, "success create new card"+note)
 	}
 }
 
 func DeleteCard() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		tokenHeader := ctx.Request.Header.Get("Authorization")
-		if tokenHeader == "" {
-			helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, "token is empty")
-			return
-		}
-
-		token := tokenHeader[len("Bearer "):]
-
-		claims, err := helpers.ParseAndValidateToken(token)
-		if err != nil {
-			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
-			return
-		}
-
-		userID := claims["sub"]
-
-		var user model.User
-		if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
-			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "user is not found")
+		user, ok := currentUser(ctx)
+		if !ok {
 			return
 		}
 
@@ -134,7 +126,7 @@ func DeleteCard() gin.HandlerFunc {
 		}
 
 		var card model.Card
-		if err := database.DB.Preload("Members").First(&card, "id = ?", parsedcardid).Error; err != nil {
+		if err := database.DB.Preload("Members").Preload("Watchers").Preload("Labels").First(&card, "id = ?", parsedcardid).Error; err != nil {
 			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "card is not found")
 			return
 		}
@@ -145,16 +137,45 @@ func DeleteCard() gin.HandlerFunc {
 			return
 		}
 
+		// clearing the associations empties them on card as well, the activity
+		// and the watchers to notify are taken from the card as it was
+		deleted := card
+		before := cardSnapshot(card)
+
 		if err := database.DB.Model(&card).Association("Members").Clear(); err != nil {
 			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to delete a card")
 			return
 		}
 
+		if err := database.DB.Model(&card).Association("Watchers").Clear(); err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to delete a card")
+			return
+		}
+
+		if err := database.DB.Model(&card).Association("Labels").Clear(); err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to delete a card")
+			return
+		}
+
+		if err := database.DB.Where("card_id = ?", card.ID).Delete(&model.Comment{}).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to delete a card")
+			return
+		}
+
+		if err := database.DB.Where("card_id = ?", card.ID).Delete(&model.CardCommit{}).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to delete a card")
+			return
+		}
+
 		if err := database.DB.Delete(&card).Error; err != nil {
 			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to delete a card")
 			return
 		}
 
+		recordActivity(column.BoardID, user, "card", card.ID, "deleted", before, nil)
+
+		notifyWatchers(deleted, fmt.Sprintf("%s deleted %s", user.Username, card.Title))
+
 		// jsonBytes, err := helpers.CreateJsonBytes(card)
 		// if err != nil {
 		// 	panic(err)
@@ -162,44 +183,19 @@ func DeleteCard() gin.HandlerFunc {
 
 		// BroadcastEventWithType("card_deleted", string(jsonBytes))
 
-		var board model.Board
-		if err := database.DB.Preload("Members").Preload("Columns").Preload("Columns.Cards").Preload("Columns.Cards.Members").First(&board, "id = ?", column.BoardID).Error; err != nil {
+		if err := broadcastBoard(column.BoardID); err != nil {
 			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board is not found")
 			return
 		}
 
-		jsonBytes, err := helpers.CreateJsonBytes(board)
-		if err != nil {
-			panic(err)
-		}
-
-		BroadcastEventWithType("board_update", string(jsonBytes))
-
 		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success delete a card")
 	}
 }
 
 func JoinCard() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		tokenHeader := ctx.Request.Header.Get("Authorization")
-		if tokenHeader == "" {
-			helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, "token is empty")
-			return
-		}
-
-		token := tokenHeader[len("Bearer "):]
-
-		claims, err := helpers.ParseAndValidateToken(token)
-		if err != nil {
-			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
-			return
-		}
-
-		userID := claims["sub"]
-
-		var user model.User
-		if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
-			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "user is not found")
+		user, ok := currentUser(ctx)
+		if !ok {
 			return
 		}
 
@@ -225,11 +221,25 @@ func JoinCard() gin.HandlerFunc {
 			}
 		}
 
+		var column model.Column
+		if err := database.DB.First(&column, "id = ?", card.ColumnID).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "column is not found")
+			return
+		}
+
+		if !isBoardMember(column.BoardID, user.ID) {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this board")
+			return
+		}
+
+		before := cardSnapshot(card)
 		if err := database.DB.Model(&card).Association("Members").Append(&user); err != nil {
 			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to join")
 			return
 		}
 
+		recordActivity(column.BoardID, user, "card", card.ID, "member_joined", before, cardSnapshot(card))
+
 		// if err := database.DB.Preload("Members").First(&card, "id = ?", parsedcardid).Error; err != nil {
 		// 	helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "card is not found")
 		// 	return
@@ -242,25 +252,11 @@ func JoinCard() gin.HandlerFunc {
 
 		// BroadcastEventWithType("card_update", string(jsonBytes))
 
-		var column model.Column
-		if err := database.DB.Preload("Cards").Preload("Cards.Members").First(&column, "id = ?", card.ColumnID).Error; err != nil {
-			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "column is not found")
-			return
-		}
-
-		var board model.Board
-		if err := database.DB.Preload("Members").Preload("Columns").Preload("Columns.Cards").Preload("Columns.Cards.Members").First(&board, "id = ?", column.BoardID).Error; err != nil {
+		if err := broadcastBoard(column.BoardID); err != nil {
 			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board is not found")
 			return
 		}
 
-		jsonBytes, err := helpers.CreateJsonBytes(board)
-		if err != nil {
-			panic(err)
-		}
-
-		BroadcastEventWithType("board_update", string(jsonBytes))
-
 		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success join")
 
 	}
@@ -268,25 +264,8 @@ func JoinCard() gin.HandlerFunc {
 
 func LeaveCard() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		tokenHeader := ctx.Request.Header.Get("Authorization")
-		if tokenHeader == "" {
-			helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, "token is empty")
-			return
-		}
-
-		token := tokenHeader[len("Bearer "):]
-
-		claims, err := helpers.ParseAndValidateToken(token)
-		if err != nil {
-			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
-			return
-		}
-
-		userID := claims["sub"]
-
-		var user model.User
-		if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
-			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "user is not found")
+		user, ok := currentUser(ctx)
+		if !ok {
 			return
 		}
 
@@ -317,6 +296,7 @@ func LeaveCard() gin.HandlerFunc {
 			return
 		}
 
+		before := cardSnapshot(card)
 		if err := database.DB.Model(&card).Association("Members").Delete(&user); err != nil {
 			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to leave")
 			return
@@ -340,19 +320,391 @@ func LeaveCard() gin.HandlerFunc {
 			return
 		}
 
+		recordActivity(column.BoardID, user, "card", card.ID, "member_left", before, cardSnapshot(card))
+
+		if err := broadcastBoard(column.BoardID); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board is not found")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success leave")
+	}
+}
+
+// findCard loads a card together with the column it lives in. When it returns
+// false the error response is already written.
+func findCard(ctx *gin.Context) (model.Card, model.Column, bool) {
+	var card model.Card
+	var column model.Column
+
+	parsedcardid, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "card id is not valid")
+		return card, column, false
+	}
+
+	if err := database.DB.Preload("Members").Preload("Watchers").Preload("Labels").First(&card, "id = ?", parsedcardid).Error; err != nil {
+		helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "card is not found")
+		return card, column, false
+	}
+
+	if err := database.DB.First(&column, "id = ?", card.ColumnID).Error; err != nil {
+		helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "column is not found")
+		return card, column, false
+	}
+
+	return card, column, true
+}
+
+// GetCardByKey finds a card of a board by its key, like KA-42, or by its
+// number alone.
+func GetCardByKey() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		boardID, err := uuid.Parse(ctx.Param("id"))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board id is not valid")
+			return
+		}
+
 		var board model.Board
-		if err := database.DB.Preload("Members").Preload("Columns").Preload("Columns.Cards").Preload("Columns.Cards.Members").First(&board, "id = ?", column.BoardID).Error; err != nil {
+		if err := database.DB.First(&board, "id = ?", boardID).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "board is not found")
+			return
+		}
+
+		if !isBoardMember(board.ID, user.ID) {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this board")
+			return
+		}
+
+		number, ok := board.ParseCardKey(ctx.Param("key"))
+		if !ok {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "card is not found")
+			return
+		}
+
+		var card model.Card
+		if err := database.DB.Preload("Members").Preload("Watchers").Preload("Labels").
+			Joins("JOIN columns ON columns.id = cards.column_id").
+			Where("columns.board_id = ? AND cards.number = ?", board.ID, number).
+			First(&card).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "card is not found")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, card, "success get card")
+	}
+}
+
+// notifyWatchers tells the watchers of a card that something happened to it.
+// Clients pick the event up over SSE and show it to the listed users.
+func notifyWatchers(card model.Card, message string) {
+	if len(card.Watchers) == 0 {
+		return
+	}
+
+	userIDs := make([]uuid.UUID, 0, len(card.Watchers))
+	for _, w := range card.Watchers {
+		userIDs = append(userIDs, w.ID)
+	}
+
+	var column model.Column
+	if err := database.DB.Select("board_id").First(&column, "id = ?", card.ColumnID).Error; err != nil {
+		log.Printf("notification: failed to find the board of card %s: %v", card.ID, err)
+		return
+	}
+
+	BroadcastBoardEvent(column.BoardID, "card_notification", map[string]any{
+		"card_id":  card.ID,
+		"user_ids": userIDs,
+		"message":  message,
+	})
+}
+
+func AssignCard() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.CardMemberRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		card, column, ok := findCard(ctx)
+		if !ok {
+			return
+		}
+
+		if !isBoardMember(column.BoardID, user.ID) {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this board")
+			return
+		}
+
+		assignees, err := boardMembersByID(column.BoardID, []string{req.UserID})
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+			return
+		}
+
+		assignee := assignees[0]
+		if containsUser(card.Members, assignee.ID) {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "user already assigned")
+			return
+		}
+
+		before := cardSnapshot(card)
+		if err := database.DB.Model(&card).Association("Members").Append(&assignee); err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to assign")
+			return
+		}
+
+		recordActivity(column.BoardID, user, "card", card.ID, "assigned", before, cardSnapshot(card))
+
+		notifyWatchers(card, fmt.Sprintf("%s assigned %s to %s", user.Username, assignee.Username, card.Title))
+
+		if err := broadcastBoard(column.BoardID); err != nil {
 			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board is not found")
 			return
 		}
 
-		jsonBytes, err := helpers.CreateJsonBytes(board)
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success assign")
+	}
+}
+
+func UnassignCard() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		card, column, ok := findCard(ctx)
+		if !ok {
+			return
+		}
+
+		if !isBoardMember(column.BoardID, user.ID) {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this board")
+			return
+		}
+
+		assigneeID, err := uuid.Parse(ctx.Param("userId"))
 		if err != nil {
-			panic(err)
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "user id is not valid")
+			return
+		}
+
+		if !containsUser(card.Members, assigneeID) {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "user is not assigned")
+			return
 		}
 
-		BroadcastEventWithType("board_update", string(jsonBytes))
+		assignee := model.User{ID: assigneeID}
+		before := cardSnapshot(card)
+		if err := database.DB.Model(&card).Association("Members").Delete(&assignee); err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to unassign")
+			return
+		}
 
-		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success leave")
+		recordActivity(column.BoardID, user, "card", card.ID, "unassigned", before, cardSnapshot(card))
+
+		notifyWatchers(card, fmt.Sprintf("%s updated the assignees of %s", user.Username, card.Title))
+
+		if err := broadcastBoard(column.BoardID); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board is not found")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success unassign")
+	}
+}
+
+func WatchCard() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		card, column, ok := findCard(ctx)
+		if !ok {
+			return
+		}
+
+		if !isBoardMember(column.BoardID, user.ID) {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this board")
+			return
+		}
+
+		if containsUser(card.Watchers, user.ID) {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "user already watching")
+			return
+		}
+
+		before := cardSnapshot(card)
+		if err := database.DB.Model(&card).Association("Watchers").Append(&user); err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to watch")
+			return
+		}
+
+		recordActivity(column.BoardID, user, "card", card.ID, "watched", before, cardSnapshot(card))
+
+		if err := broadcastBoard(column.BoardID); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board is not found")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success watch")
+	}
+}
+
+func UnwatchCard() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		card, column, ok := findCard(ctx)
+		if !ok {
+			return
+		}
+
+		if !containsUser(card.Watchers, user.ID) {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "user is not watching")
+			return
+		}
+
+		before := cardSnapshot(card)
+		if err := database.DB.Model(&card).Association("Watchers").Delete(&user); err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to unwatch")
+			return
+		}
+
+		recordActivity(column.BoardID, user, "card", card.ID, "unwatched", before, cardSnapshot(card))
+
+		if err := broadcastBoard(column.BoardID); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board is not found")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success unwatch")
+	}
+}
+
+func UpdateCard() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.UpdateCard
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		card, column, ok := findCard(ctx)
+		if !ok {
+			return
+		}
+
+		if !isBoardMember(column.BoardID, user.ID) {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this board")
+			return
+		}
+
+		before := cardSnapshot(card)
+		action := "updated"
+		wasArchived := card.Archived
+		destination := column
+
+		if req.Title != nil {
+			if *req.Title == "" {
+				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "title is empty")
+				return
+			}
+			card.Title = *req.Title
+		}
+
+		if req.Description != nil {
+			card.Description = *req.Description
+		}
+
+		if req.DueDate != nil {
+			dueDate, err := helpers.ParseDate(*req.DueDate)
+			if err != nil {
+				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+				return
+			}
+			card.DueDate = dueDate
+		}
+
+		if req.Archived != nil {
+			card.Archived = *req.Archived
+		}
+
+		if req.ColumnID != nil {
+			targetID, err := uuid.Parse(*req.ColumnID)
+			if err != nil {
+				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "column id is not valid")
+				return
+			}
+
+			var target model.Column
+			if err := database.DB.First(&target, "id = ? AND board_id = ?", targetID, column.BoardID).Error; err != nil {
+				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "column is not found on this board")
+				return
+			}
+
+			if target.ID != card.ColumnID {
+				card.ColumnID = target.ID
+				destination = target
+				action = "moved"
+			}
+		}
+
+		var wip *model.WIPStatus
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			// Only a card coming into a column counts against its limit,
+			// edits to a card already there do not.
+			if !card.Archived && (wasArchived || destination.ID != column.ID) {
+				var err error
+				if destination, wip, err = admitCard(tx, destination.ID); err != nil {
+					return err
+				}
+			}
+
+			return tx.Model(&card).Select("Title", "Description", "DueDate", "Archived", "ColumnID").Updates(&card).Error
+		})
+		if wipResponse(ctx, err) {
+			return
+		}
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to update card")
+			return
+		}
+
+		recordActivity(column.BoardID, user, "card", card.ID, action, before, cardSnapshot(card))
+
+		notifyWatchers(card, fmt.Sprintf("%s %s %s", user.Username, action, card.Title))
+		note := warnWIP(destination, wip)
+
+		if err := broadcastBoard(column.BoardID); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board is not found")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, card, "success update card"+note)
 	}
 }
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"kerjainaja/database"
+	"kerjainaja/helpers"
+	"kerjainaja/model"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDeleteCardKeepsWhatItClears(t *testing.T) {
+	s := newTestServer(t)
+	s.router.DELETE("/cards/:id", DeleteCard())
+
+	owner, token := s.user("owner")
+	watcher, _ := s.user("watcher")
+
+	board := model.Board{Name: "Board", OwnerID: &owner.ID, Members: []model.User{owner, watcher}}
+	if err := database.DB.Create(&board).Error; err != nil {
+		t.Fatal(err)
+	}
+	column := model.Column{Name: "Todo", BoardID: board.ID}
+	label := model.Label{Name: "bug", BoardID: board.ID}
+	database.DB.Create(&column)
+	database.DB.Create(&label)
+
+	card := model.Card{
+		Title:    "Release",
+		ColumnID: column.ID,
+		Members:  []model.User{owner},
+		Watchers: []model.User{watcher},
+		Labels:   []model.Label{label},
+	}
+	if err := database.DB.Create(&card).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	commit := model.CardCommit{CardID: card.ID, SHA: "a1b2c3", Message: "fix release"}
+	if err := database.DB.Create(&commit).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	events := s.events()
+
+	if res := s.request(http.MethodDelete, "/cards/"+card.ID.String(), token, nil); res.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", res.Code, res.Msg)
+	}
+
+	var activity model.Activity
+	if err := database.DB.First(&activity, "entity_id = ? AND action = ?", card.ID, "deleted").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var changes map[string]helpers.Change
+	if err := json.Unmarshal(activity.Changes, &changes); err != nil {
+		t.Fatal(err)
+	}
+	for field, want := range map[string]string{"members": "owner", "watchers": "watcher", "labels": "bug"} {
+		before, _ := changes[field].Before.([]any)
+		if !slices.Contains(before, any(want)) {
+			t.Errorf("expected the deleted activity to keep %s %q, got %v", field, want, changes[field].Before)
+		}
+	}
+
+	var commits int64
+	database.DB.Model(&model.CardCommit{}).Where("card_id = ?", card.ID).Count(&commits)
+	if commits != 0 {
+		t.Errorf("expected the commits of the card to be deleted, got %d", commits)
+	}
+
+	notified := slices.ContainsFunc(events(), func(event string) bool {
+		return strings.HasPrefix(event, "event: card_notification\n") && strings.Contains(event, watcher.ID.String())
+	})
+	if !notified {
+		t.Error("expected the watcher to be notified of the deletion")
+	}
+}
@@ -1,15 +1,113 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"kerjainaja/database"
 	"kerjainaja/helpers"
 	"kerjainaja/model"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var errWIPLimit = errors.New("column is at its WIP limit")
+
+// setWIP fills in the WIP status of the columns that have a limit.
+func setWIP(columns []model.Column) {
+	var ids []uuid.UUID
+	for _, c := range columns {
+		if c.WIPLimit > 0 {
+			ids = append(ids, c.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	var counts []struct {
+		ColumnID uuid.UUID
+		Count    int
+	}
+	if err := database.DB.Model(&model.Card{}).
+		Select("column_id, COUNT(*) AS count").
+		Where("column_id IN ? AND archived = ?", ids, false).
+		Group("column_id").
+		Scan(&counts).Error; err != nil {
+		log.Printf("wip: failed to count cards: %v", err)
+		return
+	}
+
+	for i := range columns {
+		count := 0
+		for _, c := range counts {
+			if c.ColumnID == columns[i].ID {
+				count = c.Count
+			}
+		}
+		columns[i].WIP = columns[i].WIPStatusOf(count)
+	}
+}
+
+// admitCard checks inside tx that one more card fits in a column. A full
+// column with a hard limit refuses it, one with a soft limit takes it and the
+// returned status says the column went over. The column row stays locked
+// until tx ends, so the card has to be written in tx for concurrent cards to
+// see it. The column is read again under the lock, the limit may have
+// changed since the caller loaded it.
+func admitCard(tx *gorm.DB, columnID uuid.UUID) (model.Column, *model.WIPStatus, error) {
+	var column model.Column
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&column, "id = ?", columnID).Error; err != nil {
+		return column, nil, err
+	}
+
+	if column.WIPLimit <= 0 {
+		return column, nil, nil
+	}
+
+	var count int64
+	if err := tx.Model(&model.Card{}).Where("column_id = ? AND archived = ?", column.ID, false).Count(&count).Error; err != nil {
+		return column, nil, err
+	}
+
+	if column.WIPMode == model.WIPHard && int(count) >= column.WIPLimit {
+		return column, column.WIPStatusOf(int(count)), fmt.Errorf("%w, %s holds at most %d", errWIPLimit, column.Name, column.WIPLimit)
+	}
+
+	return column, column.WIPStatusOf(int(count) + 1), nil
+}
+
+// warnWIP tells clients and webhooks when a card went into a column past its
+// soft limit. It returns the note handlers add to their response.
+func warnWIP(column model.Column, status *model.WIPStatus) string {
+	if status == nil || !status.Over {
+		return ""
+	}
+
+	BroadcastBoardEvent(column.BoardID, "wip_limit", map[string]any{
+		"column_id": column.ID,
+		"column":    column.Name,
+		"wip":       status,
+	})
+
+	return fmt.Sprintf(", %s is over its WIP limit of %d", column.Name, column.WIPLimit)
+}
+
+// wipResponse answers a request refused by a hard WIP limit. It reports
+// whether err was one.
+func wipResponse(ctx *gin.Context, err error) bool {
+	if !errors.Is(err, errWIPLimit) {
+		return false
+	}
+
+	helpers.ResponseJson(ctx, http.StatusConflict, false, nil, err.Error())
+	return true
+}
+
 func CreateColumn() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var req model.AddBoard
@@ -19,6 +117,11 @@ func CreateColumn() gin.HandlerFunc {
 			return
 		}
 
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
 		parsedID, err := uuid.Parse(req.BoardID)
 		if err != nil {
 			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "id is not valid")
@@ -47,12 +150,9 @@ func CreateColumn() gin.HandlerFunc {
 			return
 		}
 
-		jsonBytes, err := helpers.CreateJsonBytes(column)
-		if err != nil {
-			panic(err)
-		}
+		BroadcastBoardEvent(column.BoardID, "column_update", column)
 
-		BroadcastEventWithType("column_update", string(jsonBytes))
+		recordActivity(board.ID, user, "column", column.ID, "created", nil, columnSnapshot(column))
 
 		helpers.ResponseJson(ctx, http.StatusOK, true, colum, "success add column!")
 	}
@@ -66,25 +166,8 @@ func EditColumn() gin.HandlerFunc {
 			return
 		}
 
-		tokenHeader := ctx.Request.Header.Get("Authorization")
-		if tokenHeader == "" {
-			helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, "token is not found")
-			return
-		}
-
-		token := tokenHeader[len("Bearer "):]
-
-		claim, err := helpers.ParseAndValidateToken(token)
-		if err != nil {
-			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
-			return
-		}
-
-		userID := claim["sub"]
-
-		var user model.User
-		if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
-			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "user is not found")
+		user, ok := currentUser(ctx)
+		if !ok {
 			return
 		}
 
@@ -106,6 +189,7 @@ func EditColumn() gin.HandlerFunc {
 			return
 		}
 
+		before := columnSnapshot(col)
 		col.Name = req.Name
 
 		if err := database.DB.Save(&col).Error; err != nil {
@@ -113,38 +197,66 @@ func EditColumn() gin.HandlerFunc {
 			return
 		}
 
-		jsonBytes, err := helpers.CreateJsonBytes(col)
-		if err != nil {
-			panic(err)
-		}
+		cols := []model.Column{col}
+		setWIP(cols)
+		BroadcastBoardEvent(col.BoardID, "column_update", cols[0])
 
-		BroadcastEventWithType("column_update", string(jsonBytes))
+		recordActivity(col.BoardID, user, "column", col.ID, "updated", before, columnSnapshot(col))
 
 		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success update")
 	}
 }
 
-func DeleteColumn() gin.HandlerFunc {
+// UpdateWIPLimit sets the WIP limit of a column. Cards already past a new
+// limit stay, the limit only applies to cards coming in.
+func UpdateWIPLimit() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		tokenHeader := ctx.Request.Header.Get("Authorization")
-		if tokenHeader == "" {
-			helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, "token is not found")
+		var req model.UpdateWIPLimit
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
 			return
 		}
 
-		token := tokenHeader[len("Bearer "):]
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
 
-		claim, err := helpers.ParseAndValidateToken(token)
-		if err != nil {
-			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+		column, ok := adminColumn(ctx, user)
+		if !ok {
 			return
 		}
 
-		userID := claim["sub"]
+		before := map[string]any{"wip_limit": column.WIPLimit, "wip_mode": column.WIPMode}
 
-		var user model.User
-		if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
-			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "user is not found")
+		column.WIPLimit = *req.WIPLimit
+		if req.WIPMode != "" {
+			column.WIPMode = req.WIPMode
+		}
+
+		if err := database.DB.Model(&column).Select("WIPLimit", "WIPMode").Updates(&column).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to update wip limit")
+			return
+		}
+
+		recordActivity(column.BoardID, user, "column", column.ID, "wip_limit_changed", before, map[string]any{"wip_limit": column.WIPLimit, "wip_mode": column.WIPMode})
+
+		if err := broadcastBoard(column.BoardID); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board is not found")
+			return
+		}
+
+		cols := []model.Column{column}
+		setWIP(cols)
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, cols[0], "success update wip limit")
+	}
+}
+
+func DeleteColumn() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
 			return
 		}
 
@@ -162,40 +274,73 @@ func DeleteColumn() gin.HandlerFunc {
 
 		// delete all cards
 		var cards []model.Card
-		if err := database.DB.Model(&column).Association("Cards").Find(&cards); err != nil {
+		if err := database.DB.Preload("Members").Preload("Watchers").Preload("Labels").Where("column_id = ?", column.ID).Find(&cards).Error; err != nil {
 			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "failed get cards")
 			return
 		}
 
 		for _, card := range cards {
+			before := cardSnapshot(card)
+
 			if err := database.DB.Model(&card).Association("Members").Clear(); err != nil {
 				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "failed to delete members card")
 				return
 			}
 
+			if err := database.DB.Model(&card).Association("Watchers").Clear(); err != nil {
+				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "failed to delete watchers card")
+				return
+			}
+
+			if err := database.DB.Model(&card).Association("Labels").Clear(); err != nil {
+				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "failed to delete labels card")
+				return
+			}
+
+			if err := database.DB.Where("card_id = ?", card.ID).Delete(&model.Comment{}).Error; err != nil {
+				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "failed to delete comments card")
+				return
+			}
+
+			if err := database.DB.Where("card_id = ?", card.ID).Delete(&model.CardCommit{}).Error; err != nil {
+				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "failed to delete commits card")
+				return
+			}
+
 			if err := database.DB.Unscoped().Delete(&card).Error; err != nil {
 				helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to delete cards")
 				return
 			}
+
+			recordActivity(column.BoardID, user, "card", card.ID, "deleted", before, nil)
 		}
 
-		if err := database.DB.Delete(&column, "id = ?", columnid).Error; err != nil {
-			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "error delete column")
+		if err := database.DB.Where("webhook_id IN (?)", database.DB.Model(&model.InboundWebhook{}).Select("id").Where("column_id = ?", column.ID)).Delete(&model.InboundCard{}).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to delete webhooks of column")
 			return
 		}
 
-		var board model.Board
-		if err := database.DB.Preload("Members").Preload("Columns").Preload("Columns.Cards").Preload("Columns.Cards.Members").First(&board, "id = ?", column.BoardID).Error; err != nil {
-			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board is not found")
+		if err := database.DB.Where("column_id = ?", column.ID).Delete(&model.InboundWebhook{}).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to delete webhooks of column")
 			return
 		}
 
-		jsonBytes, err := helpers.CreateJsonBytes(board)
-		if err != nil {
-			panic(err)
+		if err := database.DB.Model(&model.GitIntegration{}).Where("done_column_id = ?", column.ID).Update("done_column_id", nil).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to unlink git integrations of column")
+			return
+		}
+
+		if err := database.DB.Delete(&column, "id = ?", columnid).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "error delete column")
+			return
 		}
 
-		BroadcastEventWithType("board_update", string(jsonBytes))
+		recordActivity(column.BoardID, user, "column", column.ID, "deleted", columnSnapshot(column), nil)
+
+		if err := broadcastBoard(column.BoardID); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board is not found")
+			return
+		}
 
 		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success delete a column")
 
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"fmt"
+	"kerjainaja/database"
+	"kerjainaja/helpers"
+	"kerjainaja/model"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func GetComments() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		card, column, ok := findCard(ctx)
+		if !ok {
+			return
+		}
+
+		if !isBoardMember(column.BoardID, user.ID) {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this board")
+			return
+		}
+
+		comments := []model.Comment{}
+		if err := database.DB.Where("card_id = ?", card.ID).Order("created_at").Find(&comments).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get comments")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, comments, "success get comments")
+	}
+}
+
+func CreateComment() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.NewComment
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		card, column, ok := findCard(ctx)
+		if !ok {
+			return
+		}
+
+		if !isBoardMember(column.BoardID, user.ID) {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this board")
+			return
+		}
+
+		body := strings.TrimSpace(req.Body)
+		if body == "" {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "comment can not be empty")
+			return
+		}
+
+		comment := model.Comment{
+			CardID:         card.ID,
+			AuthorID:       &user.ID,
+			AuthorUsername: user.Username,
+			Body:           body,
+		}
+
+		if err := database.DB.Create(&comment).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to create comment")
+			return
+		}
+
+		recordActivity(column.BoardID, user, "card", card.ID, "commented", nil, map[string]any{"comment": comment.Body})
+
+		notifyWatchers(card, fmt.Sprintf("%s commented on %s", user.Username, card.Title))
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, comment, "success create comment")
+	}
+}
+
+func DeleteComment() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		id, err := uuid.Parse(ctx.Param("id"))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "comment id is not valid")
+			return
+		}
+
+		var comment model.Comment
+		if err := database.DB.First(&comment, "id = ?", id).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "comment is not found")
+			return
+		}
+
+		if comment.AuthorID == nil || *comment.AuthorID != user.ID {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you can only delete your own comments")
+			return
+		}
+
+		if err := database.DB.Delete(&comment).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to delete comment")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success delete comment")
+	}
+}
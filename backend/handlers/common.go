@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"kerjainaja/config"
+	"kerjainaja/database"
+	"kerjainaja/helpers"
+	"kerjainaja/model"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// currentUser reads the bearer token from the request and loads the user it
+// belongs to. Both session JWTs and personal access tokens are accepted.
+// When it returns false the error response is already written.
+func currentUser(ctx *gin.Context) (model.User, bool) {
+	var user model.User
+
+	tokenHeader := ctx.Request.Header.Get("Authorization")
+	if len(tokenHeader) <= len("Bearer ") {
+		helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, "token is empty")
+		return user, false
+	}
+
+	token := tokenHeader[len("Bearer "):]
+
+	if strings.HasPrefix(token, model.AccessTokenPrefix) {
+		return accessTokenUser(ctx, token)
+	}
+
+	session, err := validateSessionToken(token)
+	if err != nil {
+		helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, err.Error())
+		return user, false
+	}
+
+	if err := database.DB.First(&user, "id = ?", session.UserID).Error; err != nil {
+		helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, "user is not found")
+		return user, false
+	}
+
+	if user.DisabledAt != nil {
+		helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "account is disabled")
+		return user, false
+	}
+
+	ctx.Set("session", session)
+
+	return user, true
+}
+
+// requireSession rejects requests made with a personal access token, for
+// account changes that need a real login.
+func requireSession(ctx *gin.Context) bool {
+	if _, isToken := ctx.Get("access_token"); isToken {
+		helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "this action needs a login session, not an access token")
+		return false
+	}
+
+	return true
+}
+
+// canWrite reports whether the request may change data. Login sessions
+// always can, access tokens only with the write scope, even on a GET.
+func canWrite(ctx *gin.Context) bool {
+	value, isToken := ctx.Get("access_token")
+	if !isToken {
+		return true
+	}
+
+	accessToken, ok := value.(model.PersonalAccessToken)
+	return ok && accessToken.HasScope(model.ScopeWrite)
+}
+
+// validateSessionToken checks an access token and the session it belongs to,
+// so tokens stop working as soon as their session is revoked.
+func validateSessionToken(token string) (model.Session, error) {
+	var session model.Session
+
+	claims, err := helpers.ParseAndValidateToken(token)
+	if err != nil {
+		return session, err
+	}
+
+	if claims["typ"] != "access" {
+		return session, errors.New("token is invalid")
+	}
+
+	if err := database.DB.First(&session, "id = ? AND user_id = ?", claims["sid"], claims["sub"]).Error; err != nil {
+		return session, errors.New("session is not found")
+	}
+
+	if session.RevokedAt != nil || session.ExpiresAt.Before(time.Now()) {
+		return session, errors.New("session is revoked")
+	}
+
+	return session, nil
+}
+
+func accessTokenUser(ctx *gin.Context, token string) (model.User, bool) {
+	var user model.User
+
+	var accessToken model.PersonalAccessToken
+	if err := database.DB.Where("token_hash = ? AND revoked_at IS NULL", helpers.HashSecret(token)).First(&accessToken).Error; err != nil {
+		helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, "token is invalid")
+		return user, false
+	}
+
+	now := time.Now()
+	if accessToken.ExpiresAt != nil && accessToken.ExpiresAt.Before(now) {
+		helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, "token is expired")
+		return user, false
+	}
+
+	scope := model.ScopeWrite
+	if ctx.Request.Method == http.MethodGet || ctx.Request.Method == http.MethodHead {
+		scope = model.ScopeRead
+	}
+
+	if !accessToken.HasScope(scope) {
+		helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "token is missing the "+scope+" scope")
+		return user, false
+	}
+
+	if err := database.DB.First(&user, "id = ?", accessToken.UserID).Error; err != nil {
+		helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, "user is not found")
+		return user, false
+	}
+
+	if user.DisabledAt != nil {
+		helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "account is disabled")
+		return user, false
+	}
+
+	// only touch the row once a minute so busy scripts don't write on every call
+	if accessToken.LastUsedAt == nil || now.Sub(*accessToken.LastUsedAt) > time.Minute {
+		database.DB.Model(&accessToken).Update("last_used_at", now)
+	}
+
+	ctx.Set("access_token", accessToken)
+
+	return user, true
+}
+
+// loadBoard loads a board with everything the client needs to render it.
+// cardScopes narrow down which cards are included.
+func loadBoard(boardID uuid.UUID, cardScopes ...func(*gorm.DB) *gorm.DB) (model.Board, error) {
+	cardConditions := make([]any, 0, len(cardScopes))
+	for _, scope := range cardScopes {
+		cardConditions = append(cardConditions, scope)
+	}
+
+	var board model.Board
+	err := database.DB.
+		Preload("Members").
+		Preload("Columns").
+		Preload("Columns.Cards", cardConditions...).
+		Preload("Columns.Cards.Members").
+		Preload("Columns.Cards.Watchers").
+		Preload("Columns.Cards.Labels").
+		First(&board, "id = ?", boardID).Error
+	if err != nil {
+		return board, err
+	}
+
+	setWIP(board.Columns)
+	return board, nil
+}
+
+// broadcastBoard sends the latest snapshot of a board to every SSE client
+// and the webhooks of the board.
+func broadcastBoard(boardID uuid.UUID) error {
+	board, err := loadBoard(boardID)
+	if err != nil {
+		return err
+	}
+
+	BroadcastBoardEvent(board.ID, "board_update", board)
+	return nil
+}
+
+func isBoardMember(boardID uuid.UUID, userID uuid.UUID) bool {
+	var count int64
+	database.DB.Table("board_members").
+		Where("board_id = ? AND user_id = ?", boardID, userID).
+		Count(&count)
+
+	return count > 0
+}
+
+func containsUser(users []model.User, userID uuid.UUID) bool {
+	return slices.ContainsFunc(users, func(u model.User) bool {
+		return u.ID == userID
+	})
+}
+
+// boardMembersByID resolves user ids to users, failing when any of them is
+// not a member of the board.
+func boardMembersByID(boardID uuid.UUID, ids []string) ([]model.User, error) {
+	parsedIDs := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		parsedID, err := uuid.Parse(id)
+		if err != nil {
+			return nil, fmt.Errorf("user id %s is not valid", id)
+		}
+
+		if !slices.Contains(parsedIDs, parsedID) {
+			parsedIDs = append(parsedIDs, parsedID)
+		}
+	}
+
+	var users []model.User
+	if err := database.DB.
+		Joins("JOIN board_members ON board_members.user_id = users.id").
+		Where("board_members.board_id = ? AND users.id IN ?", boardID, parsedIDs).
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	for _, id := range parsedIDs {
+		if !containsUser(users, id) {
+			return nil, fmt.Errorf("user %s is not a member of this board", id)
+		}
+	}
+
+	return users, nil
+}
+
+// isBoardAdmin reports whether a user may manage a board's settings: its
+// owner and the owners and admins of its workspace.
+func isBoardAdmin(board model.Board, userID uuid.UUID) bool {
+	if board.OwnerID != nil && *board.OwnerID == userID {
+		return true
+	}
+
+	if board.WorkspaceID == nil {
+		return false
+	}
+
+	member, ok := workspaceMembership(*board.WorkspaceID, userID)
+	return ok && member.CanManage()
+}
+
+// apiBaseURL is the public address of this server for links that are used
+// outside the app. It is API_URL, or the request host when that isn't set.
+func apiBaseURL(ctx *gin.Context) string {
+	base := strings.TrimSuffix(config.Env("API_URL"), "/")
+	if base != "" {
+		return base
+	}
+
+	scheme := "http"
+	if ctx.Request.TLS != nil || ctx.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	return scheme + "://" + ctx.Request.Host
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"kerjainaja/database"
+	"kerjainaja/helpers"
+	"kerjainaja/model"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// sharedBoardUsers matches users who are on at least one board with the
+// given user, the caller included.
+const sharedBoardUsers = "users.id IN (SELECT others.user_id FROM board_members mine JOIN board_members others ON others.board_id = mine.board_id WHERE mine.user_id = ?)"
+
+// sharedWorkspaceUsers matches users who are in at least one workspace with
+// the given user, the caller included.
+const sharedWorkspaceUsers = "users.id IN (SELECT others.user_id FROM workspace_members mine JOIN workspace_members others ON others.workspace_id = mine.workspace_id WHERE mine.user_id = ?)"
+
+// knownUsers matches the users someone already shares a board or a
+// workspace with.
+func knownUsers(user model.User) *gorm.DB {
+	return database.DB.Where(sharedBoardUsers, user.ID).Or(sharedWorkspaceUsers, user.ID)
+}
+
+func listPublicUsers(ctx *gin.Context, query *gorm.DB, msg string) {
+	page, limit := helpers.Pagination(ctx)
+
+	// the query runs twice, a session keeps the count from leaking into the find
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get users")
+		return
+	}
+
+	users := []model.PublicUser{}
+	if err := query.
+		Select("users.id, users.name, users.username, users.avatar_url").
+		Order("users.username").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&users).Error; err != nil {
+		helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get users")
+		return
+	}
+
+	data := helpers.Page{
+		Items: users,
+		Page:  page,
+		Limit: limit,
+		Total: total,
+	}
+
+	helpers.ResponseJson(ctx, http.StatusOK, true, data, msg)
+}
+
+// SearchUsers finds people to invite. Username, name and email prefixes only
+// match people the caller already shares a board with, anyone else is only
+// found by typing their exact email address.
+func SearchUsers() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		q := strings.ToLower(strings.TrimSpace(ctx.Query("q")))
+		if q == "" {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "q is required")
+			return
+		}
+
+		prefix := helpers.EscapeLike(q) + "%"
+
+		query := database.DB.Model(&model.User{}).
+			Where("users.disabled_at IS NULL").
+			Where(database.DB.
+				Where(sharedBoardUsers+" AND (LOWER(users.username) LIKE ? OR LOWER(users.name) LIKE ? OR LOWER(users.name) LIKE ? OR LOWER(users.email) LIKE ?)",
+					user.ID, prefix, prefix, "% "+prefix, prefix).
+				Or("LOWER(users.email) = ?", q))
+
+		// leaves out people already on the board they are being invited to
+		if boardID := ctx.Query("exclude_board"); boardID != "" {
+			parsedID, err := uuid.Parse(boardID)
+			if err != nil {
+				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board id is not valid")
+				return
+			}
+
+			query = query.Where("users.id NOT IN (SELECT user_id FROM board_members WHERE board_id = ?)", parsedID)
+		}
+
+		listPublicUsers(ctx, query, "success search users")
+	}
+}
+
+// GetPeople lists everyone on the caller's boards, or on one board with
+// ?board_id=, for autocomplete when assigning and mentioning.
+func GetPeople() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		query := database.DB.Model(&model.User{}).Where("users.disabled_at IS NULL")
+
+		if boardID := ctx.Query("board_id"); boardID != "" {
+			parsedID, err := uuid.Parse(boardID)
+			if err != nil {
+				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board id is not valid")
+				return
+			}
+
+			if !isBoardMember(parsedID, user.ID) {
+				helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this board")
+				return
+			}
+
+			query = query.Where("users.id IN (SELECT user_id FROM board_members WHERE board_id = ?)", parsedID)
+		} else {
+			query = query.Where(sharedBoardUsers, user.ID)
+		}
+
+		if q := strings.ToLower(strings.TrimSpace(ctx.Query("q"))); q != "" {
+			prefix := helpers.EscapeLike(q) + "%"
+			query = query.Where("LOWER(users.username) LIKE ? OR LOWER(users.name) LIKE ? OR LOWER(users.name) LIKE ?", prefix, prefix, "% "+prefix)
+		}
+
+		listPublicUsers(ctx, query, "success get people")
+	}
+}
@@ -0,0 +1,441 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"kerjainaja/database"
+	"kerjainaja/export"
+	"kerjainaja/helpers"
+	"kerjainaja/model"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// exportDocument loads everything of a board that goes into an export.
+func exportDocument(boardID uuid.UUID) (model.Board, export.Document, error) {
+	board, err := loadBoard(boardID)
+	if err != nil {
+		return board, export.Document{}, err
+	}
+
+	var labels []model.Label
+	if err := database.DB.Where("board_id = ?", boardID).Order("name").Find(&labels).Error; err != nil {
+		return board, export.Document{}, err
+	}
+
+	var cardIDs []uuid.UUID
+	for _, col := range board.Columns {
+		for _, card := range col.Cards {
+			cardIDs = append(cardIDs, card.ID)
+		}
+	}
+
+	// comments are not part of a normal board load, so they are attached here
+	if len(cardIDs) > 0 {
+		var comments []model.Comment
+		if err := database.DB.Where("card_id IN ?", cardIDs).Order("created_at").Find(&comments).Error; err != nil {
+			return board, export.Document{}, err
+		}
+
+		for i := range board.Columns {
+			for j := range board.Columns[i].Cards {
+				card := &board.Columns[i].Cards[j]
+				for _, c := range comments {
+					if c.CardID == card.ID {
+						card.Comments = append(card.Comments, c)
+					}
+				}
+			}
+		}
+	}
+
+	return board, export.Build(board, labels, time.Now()), nil
+}
+
+func ExportBoard() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		boardID, err := uuid.Parse(ctx.Param("id"))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board id is not valid")
+			return
+		}
+
+		if !isBoardMember(boardID, user.ID) {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this board")
+			return
+		}
+
+		board, doc, err := exportDocument(boardID)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "board is not found")
+			return
+		}
+
+		ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="board-%s.json"`, board.ID))
+		ctx.JSON(http.StatusOK, doc)
+	}
+}
+
+func ImportBoard() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.ImportBoard
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		var doc export.Document
+		if err := json.Unmarshal(req.Document, &doc); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "document is not valid json: "+err.Error())
+			return
+		}
+
+		if err := doc.Validate(); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+			return
+		}
+
+		workspace, ok := newBoardWorkspace(ctx, user, req.WorkspaceID)
+		if !ok {
+			return
+		}
+
+		var workspaceID *uuid.UUID
+		if workspace != nil {
+			workspaceID = &workspace.ID
+		}
+
+		users, err := mapUsernames(doc.Usernames(), req.MemberMap)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to map members")
+			return
+		}
+
+		if err := keepKnownUsers(user, users); err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to map members")
+			return
+		}
+
+		var board model.Board
+		var report export.Report
+		err = database.DB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			board, report, err = export.Import(tx, doc, user, users, workspaceID)
+			return err
+		})
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to import board")
+			return
+		}
+
+		recordActivity(board.ID, user, "board", board.ID, "imported", nil, boardSnapshot(board))
+
+		data := map[string]any{
+			"board":  board,
+			"report": report,
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, data, "success import board")
+	}
+}
+
+// mapUsernames resolves usernames of an imported document to active local
+// users, renamed through memberMap first. Names without a match are left out.
+func mapUsernames(names []string, memberMap map[string]string) (map[string]model.User, error) {
+	lookup := make([]string, 0, len(names))
+	for _, name := range names {
+		if mapped, ok := memberMap[name]; ok {
+			name = mapped
+		}
+		lookup = append(lookup, name)
+	}
+
+	users := make(map[string]model.User, len(names))
+	if len(lookup) == 0 {
+		return users, nil
+	}
+
+	var found []model.User
+	if err := database.DB.Where("username IN ? AND disabled_at IS NULL", lookup).Find(&found).Error; err != nil {
+		return nil, err
+	}
+
+	for i, name := range names {
+		for _, u := range found {
+			if u.Username == lookup[i] {
+				users[name] = u
+			}
+		}
+	}
+
+	return users, nil
+}
+
+// keepKnownUsers drops the users an import would add to the new board
+// without the importer knowing them. A document or a member map can name
+// anyone, so only people the importer already shares a board or workspace
+// with are kept. The rest are reported unmapped like names with no account,
+// so the report doesn't tell which exist.
+func keepKnownUsers(importer model.User, users map[string]model.User) error {
+	ids := make([]uuid.UUID, 0, len(users))
+	for _, u := range users {
+		ids = append(ids, u.ID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var known []uuid.UUID
+	if err := database.DB.Model(&model.User{}).Where("users.id IN ?", ids).Where(knownUsers(importer)).Pluck("users.id", &known).Error; err != nil {
+		return err
+	}
+
+	for name, u := range users {
+		if u.ID != importer.ID && !slices.Contains(known, u.ID) {
+			delete(users, name)
+		}
+	}
+
+	return nil
+}
+
+// ImportTrello imports a Trello board JSON export. Trello members are matched
+// to local users the importer shares a board or workspace with, by email or
+// through member_map by their Trello username.
+func ImportTrello() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.ImportBoard
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		doc, emails, trelloReport, err := export.FromTrello(req.Document)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+			return
+		}
+
+		if err := doc.Validate(); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+			return
+		}
+
+		workspace, ok := newBoardWorkspace(ctx, user, req.WorkspaceID)
+		if !ok {
+			return
+		}
+
+		var workspaceID *uuid.UUID
+		if workspace != nil {
+			workspaceID = &workspace.ID
+		}
+
+		users, err := mapUsernames(doc.Usernames(), req.MemberMap)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to map members")
+			return
+		}
+
+		// a trello username only counts through the member map, the same
+		// username here may well be someone else
+		for name := range users {
+			if _, mapped := req.MemberMap[name]; !mapped {
+				delete(users, name)
+			}
+		}
+
+		if err := keepKnownUsers(user, users); err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to map members")
+			return
+		}
+
+		if len(emails) > 0 {
+			addresses := make([]string, 0, len(emails))
+			for _, email := range emails {
+				addresses = append(addresses, email)
+			}
+
+			// only people the importer already knows are matched, otherwise
+			// the report and members would tell which emails have accounts
+			var found []model.User
+			if err := database.DB.Where("email IN ? AND disabled_at IS NULL", addresses).Where(knownUsers(user)).Find(&found).Error; err != nil {
+				helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to map members")
+				return
+			}
+
+			for name, email := range emails {
+				for _, u := range found {
+					if strings.EqualFold(u.Email, email) {
+						users[name] = u
+					}
+				}
+			}
+		}
+
+		var board model.Board
+		var report export.Report
+		err = database.DB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			board, report, err = export.Import(tx, doc, user, users, workspaceID)
+			return err
+		})
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to import board")
+			return
+		}
+
+		recordActivity(board.ID, user, "board", board.ID, "imported", nil, boardSnapshot(board))
+
+		data := map[string]any{
+			"board":  board,
+			"report": report,
+			"trello": trelloReport,
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, data, "success import trello board")
+	}
+}
+
+// exportBatchSize is how many cards a streamed export loads at a time.
+const exportBatchSize = 200
+
+// streamBoard writes every column of a board with its cards, a batch at a
+// time in creation order, flushing after each batch.
+func streamBoard(boardID uuid.UUID, archived bool, w export.Writer) error {
+	var columns []model.Column
+	if err := database.DB.Where("board_id = ?", boardID).Order("created_at, id").Find(&columns).Error; err != nil {
+		return err
+	}
+
+	for _, col := range columns {
+		if err := w.Column(col); err != nil {
+			return err
+		}
+
+		var last *model.Card
+		for {
+			query := database.DB.
+				Preload("Members").
+				Preload("Labels").
+				Where("column_id = ?", col.ID).
+				Order("created_at, id").
+				Limit(exportBatchSize)
+			if !archived {
+				query = query.Where("archived = ?", false)
+			}
+			if last != nil {
+				query = query.Where("created_at > ? OR (created_at = ? AND id > ?)", last.CreatedAt, last.CreatedAt, last.ID)
+			}
+
+			var cards []model.Card
+			if err := query.Find(&cards).Error; err != nil {
+				return err
+			}
+
+			for _, card := range cards {
+				if err := w.Card(col, card); err != nil {
+					return err
+				}
+			}
+
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+			if len(cards) < exportBatchSize {
+				break
+			}
+			last = &cards[len(cards)-1]
+		}
+	}
+
+	return w.Flush()
+}
+
+// ExportBoardFlat streams a board as CSV or Markdown, picked by the format
+// in the path. Archived cards are left out unless archived=true is given.
+func ExportBoardFlat(format string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		boardID, err := uuid.Parse(ctx.Param("id"))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board id is not valid")
+			return
+		}
+
+		if !isBoardMember(boardID, user.ID) {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this board")
+			return
+		}
+
+		var board model.Board
+		if err := database.DB.First(&board, "id = ?", boardID).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "board is not found")
+			return
+		}
+
+		var w export.Writer
+		switch format {
+		case "csv":
+			ctx.Header("Content-Type", "text/csv; charset=utf-8")
+			ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="board-%s.csv"`, board.ID))
+			w, err = export.NewCSV(ctx.Writer, user.Location())
+		default:
+			ctx.Header("Content-Type", "text/markdown; charset=utf-8")
+			ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="board-%s.md"`, board.ID))
+			w, err = export.NewMarkdown(ctx.Writer, board, user.Location())
+		}
+
+		// the status is sent with the first bytes, a failure after that can
+		// only cut the download short
+		if err == nil {
+			err = streamBoard(board.ID, ctx.Query("archived") == "true", flushWriter{w, ctx.Writer})
+		}
+		if err != nil {
+			log.Printf("export: failed to stream board %s: %v", board.ID, err)
+		}
+	}
+}
+
+// flushWriter flushes the response along with the export writer, so every
+// batch reaches the client right away.
+type flushWriter struct {
+	export.Writer
+	http.Flusher
+}
+
+func (f flushWriter) Flush() error {
+	if err := f.Writer.Flush(); err != nil {
+		return err
+	}
+
+	f.Flusher.Flush()
+	return nil
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"kerjainaja/database"
+	"kerjainaja/export"
+	"kerjainaja/model"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestImportMapsKnownUsersOnly(t *testing.T) {
+	s := newTestServer(t)
+	s.router.POST("/boards/import", ImportBoard())
+
+	importer, token := s.user("importer")
+	teammate, _ := s.user("teammate")
+	s.user("stranger")
+
+	shared := model.Board{Name: "Shared", OwnerID: &importer.ID, Members: []model.User{importer, teammate}}
+	if err := database.DB.Create(&shared).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	document, _ := json.Marshal(export.Document{
+		Version: export.Version,
+		Board: export.Board{
+			Name:    "Imported",
+			Members: []export.Member{{Username: "alice"}, {Username: "bob"}},
+		},
+	})
+
+	res := s.request(http.MethodPost, "/boards/import", token, model.ImportBoard{
+		Document:  document,
+		MemberMap: map[string]string{"alice": "teammate", "bob": "stranger"},
+	})
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", res.Code, res.Msg)
+	}
+
+	var data struct {
+		Board  model.Board   `json:"board"`
+		Report export.Report `json:"report"`
+	}
+	if err := json.Unmarshal(res.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+
+	var members []string
+	database.DB.Table("board_members").
+		Joins("JOIN users ON users.id = board_members.user_id").
+		Where("board_members.board_id = ?", data.Board.ID).
+		Pluck("users.username", &members)
+
+	if !slices.Contains(members, "teammate") || slices.Contains(members, "stranger") {
+		t.Errorf("expected only the known user to be added, got %v", members)
+	}
+
+	if !slices.Contains(data.Report.UnmappedUsers, "bob") {
+		t.Errorf("expected the user mapped to a stranger to be reported unmapped, got %v", data.Report.UnmappedUsers)
+	}
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"kerjainaja/database"
+	"kerjainaja/filter"
+	"kerjainaja/helpers"
+	"kerjainaja/model"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// requestFilter reads the card filter of a board view, either given inline
+// with ?filter= or by id of a saved filter with ?saved_filter=.
+func requestFilter(ctx *gin.Context, user model.User, boardID uuid.UUID) (filter.Filter, error) {
+	query := ctx.Query("filter")
+
+	if savedID := ctx.Query("saved_filter"); savedID != "" {
+		parsedID, err := uuid.Parse(savedID)
+		if err != nil {
+			return filter.Filter{}, errors.New("saved filter id is not valid")
+		}
+
+		var saved model.SavedFilter
+		if err := database.DB.
+			Where("id = ? AND user_id = ? AND (board_id IS NULL OR board_id = ?)", parsedID, user.ID, boardID).
+			First(&saved).Error; err != nil {
+			return filter.Filter{}, errors.New("saved filter is not found")
+		}
+
+		query = saved.Query
+	}
+
+	return filter.Parse(query)
+}
+
+func GetSavedFilters() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		query := database.DB.Where("user_id = ?", user.ID)
+		if boardID := ctx.Query("board_id"); boardID != "" {
+			parsedID, err := uuid.Parse(boardID)
+			if err != nil {
+				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board id is not valid")
+				return
+			}
+			query = query.Where("board_id IS NULL OR board_id = ?", parsedID)
+		}
+
+		var filters []model.SavedFilter
+		if err := query.Order("name").Find(&filters).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get filters")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, filters, "success get filters")
+	}
+}
+
+func CreateSavedFilter() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.NewSavedFilter
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		parsed, err := filter.Parse(req.Query)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+			return
+		}
+
+		saved := model.SavedFilter{
+			UserID: user.ID,
+			Name:   req.Name,
+			Query:  parsed.String(),
+		}
+
+		if req.BoardID != "" {
+			boardID, err := uuid.Parse(req.BoardID)
+			if err != nil {
+				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board id is not valid")
+				return
+			}
+
+			if !isBoardMember(boardID, user.ID) {
+				helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this board")
+				return
+			}
+
+			saved.BoardID = &boardID
+		}
+
+		if err := database.DB.Create(&saved).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to save filter")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, saved, "success save filter")
+	}
+}
+
+func DeleteSavedFilter() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		id, err := uuid.Parse(ctx.Param("id"))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "filter id is not valid")
+			return
+		}
+
+		result := database.DB.Where("id = ? AND user_id = ?", id, user.ID).Delete(&model.SavedFilter{})
+		if result.Error != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to delete filter")
+			return
+		}
+
+		if result.RowsAffected == 0 {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "filter is not found")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success delete filter")
+	}
+}
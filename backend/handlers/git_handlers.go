@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"kerjainaja/database"
+	"kerjainaja/gitpush"
+	"kerjainaja/helpers"
+	"kerjainaja/model"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// gitPushURL is the address repositories send their pushes to.
+func gitPushURL(ctx *gin.Context, secret string) string {
+	return apiBaseURL(ctx) + "/api/git/" + secret
+}
+
+// doneColumn checks that the column closing keywords move cards to is on
+// the board. An empty id means closing keywords only link the commit.
+func doneColumn(boardID uuid.UUID, raw string) (*uuid.UUID, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return nil, errors.New("done column id is not valid")
+	}
+
+	var column model.Column
+	if err := database.DB.First(&column, "id = ? AND board_id = ?", id, boardID).Error; err != nil {
+		return nil, errors.New("done column is not found on this board")
+	}
+
+	return &column.ID, nil
+}
+
+func GetGitIntegrations() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		board, ok := adminBoard(ctx, user)
+		if !ok {
+			return
+		}
+
+		var integrations []model.GitIntegration
+		if err := database.DB.Where("board_id = ? AND revoked_at IS NULL", board.ID).Order("created_at").Find(&integrations).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get git integrations")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, integrations, "success get git integrations")
+	}
+}
+
+func CreateGitIntegration() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.NewGitIntegration
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok || !requireSession(ctx) {
+			return
+		}
+
+		board, ok := adminBoard(ctx, user)
+		if !ok {
+			return
+		}
+
+		doneColumnID, err := doneColumn(board.ID, req.DoneColumnID)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+			return
+		}
+
+		secret, hash, err := helpers.GenerateSecret(model.GitIntegrationPrefix)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to generate token")
+			return
+		}
+
+		integration := model.GitIntegration{
+			BoardID:      board.ID,
+			Name:         req.Name,
+			DoneColumnID: doneColumnID,
+			Prefix:       secret[:len(model.GitIntegrationPrefix)+6],
+			TokenHash:    hash,
+			CreatedByID:  user.ID,
+		}
+
+		if err := database.DB.Create(&integration).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to create git integration")
+			return
+		}
+
+		recordActivity(board.ID, user, "git_integration", integration.ID, "created", nil, map[string]any{"name": integration.Name})
+
+		data := map[string]any{
+			"url":         gitPushURL(ctx, secret),
+			"integration": integration,
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, data, "success create git integration, copy the link now because it will not be shown again")
+	}
+}
+
+func RevokeGitIntegration() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		id, err := uuid.Parse(ctx.Param("id"))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "git integration id is not valid")
+			return
+		}
+
+		var integration model.GitIntegration
+		if err := database.DB.First(&integration, "id = ? AND revoked_at IS NULL", id).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "git integration is not found")
+			return
+		}
+
+		var board model.Board
+		if err := database.DB.First(&board, "id = ?", integration.BoardID).Error; err != nil || !isBoardAdmin(board, user.ID) {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "git integration is not found")
+			return
+		}
+
+		if err := database.DB.Model(&integration).Update("revoked_at", time.Now()).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to revoke git integration")
+			return
+		}
+
+		recordActivity(board.ID, user, "git_integration", integration.ID, "revoked", map[string]any{"name": integration.Name}, nil)
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success revoke git integration")
+	}
+}
+
+// ReceiveGitPush links the commits of a push to the cards their messages
+// mention. The token in the path is the only credential. GitHub can send
+// the payload form encoded, so that is read too.
+func ReceiveGitPush() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var integration model.GitIntegration
+		if err := database.DB.First(&integration, "token_hash = ? AND revoked_at IS NULL", helpers.HashSecret(ctx.Param("token"))).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "git integration is not found")
+			return
+		}
+
+		var body []byte
+		if strings.HasPrefix(ctx.ContentType(), "application/x-www-form-urlencoded") {
+			body = []byte(ctx.PostForm("payload"))
+		} else {
+			raw, err := ctx.GetRawData()
+			if err != nil {
+				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "failed to read payload")
+				return
+			}
+			body = raw
+		}
+
+		push, err := gitpush.Parse(body)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+			return
+		}
+
+		var actor model.User
+		if err := database.DB.First(&actor, "id = ? AND disabled_at IS NULL", integration.CreatedByID).Error; err != nil || !isBoardMember(integration.BoardID, actor.ID) {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "the creator of this integration no longer has access to the board")
+			return
+		}
+
+		var board model.Board
+		if err := database.DB.First(&board, "id = ?", integration.BoardID).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "board is not found")
+			return
+		}
+
+		database.DB.Model(&integration).Update("last_used_at", time.Now())
+
+		linked := []string{}
+		moved := []string{}
+		blocked := []string{}
+
+		for _, commit := range push.Commits {
+			for _, ref := range gitpush.Refs(commit.Message, board.KeyPrefix) {
+				card, ok, err := linkCommit(actor, integration, board, push, commit, ref)
+				if err != nil {
+					helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to link commit "+commit.ID)
+					return
+				}
+				if !ok {
+					continue
+				}
+
+				linked = append(linked, card.Key)
+
+				if ref.Close && integration.DoneColumnID != nil && card.ColumnID != *integration.DoneColumnID {
+					err := closeCard(actor, board, card, *integration.DoneColumnID, commit)
+					if errors.Is(err, errWIPLimit) {
+						blocked = append(blocked, card.Key)
+						continue
+					}
+					if err != nil {
+						helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to move card "+card.Key)
+						return
+					}
+					moved = append(moved, card.Key)
+				}
+			}
+		}
+
+		if len(linked) > 0 {
+			if err := broadcastBoard(board.ID); err != nil {
+				helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "board is not found")
+				return
+			}
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, map[string]any{"linked": linked, "moved": moved, "blocked": blocked}, "success receive push")
+	}
+}
+
+// linkCommit records a commit on the card a key names. It reports false
+// when the board has no such card or the commit was linked by an earlier
+// push, so pushing a commit to another branch doesn't close the card again.
+func linkCommit(actor model.User, integration model.GitIntegration, board model.Board, push gitpush.Push, commit gitpush.Commit, ref gitpush.Ref) (model.Card, bool, error) {
+	var card model.Card
+	err := database.DB.Preload("Watchers").
+		Joins("JOIN columns ON columns.id = cards.column_id").
+		Where("columns.board_id = ? AND cards.number = ?", board.ID, ref.Number).
+		First(&card).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return card, false, nil
+	}
+	if err != nil {
+		return card, false, err
+	}
+
+	link := model.CardCommit{
+		CardID:        card.ID,
+		SHA:           commit.ID,
+		IntegrationID: integration.ID,
+		Repository:    push.RepositoryName(),
+		Branch:        push.Branch(),
+		Message:       commit.Message,
+		URL:           commit.URL,
+		AuthorName:    commit.Author.Name,
+		AuthorEmail:   commit.Author.Email,
+		Closed:        ref.Close,
+	}
+	if !commit.Timestamp.IsZero() {
+		link.CommittedAt = &commit.Timestamp
+	}
+
+	result := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&link)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return card, false, result.Error
+	}
+
+	after := map[string]any{
+		"sha":        link.SHA,
+		"repository": link.Repository,
+		"branch":     link.Branch,
+		"message":    link.Message,
+		"author":     link.AuthorName,
+	}
+	recordActivity(board.ID, actor, "card", card.ID, "commit_linked", nil, after)
+
+	return card, true, nil
+}
+
+// closeCard moves a card to the done column of an integration. It returns
+// errWIPLimit when the column's hard WIP limit keeps the card out.
+func closeCard(actor model.User, board model.Board, card model.Card, columnID uuid.UUID, commit gitpush.Commit) error {
+	before := cardSnapshot(card)
+
+	var column model.Column
+	var wip *model.WIPStatus
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// an archived card doesn't count against the limit
+		if !card.Archived {
+			var err error
+			if column, wip, err = admitCard(tx, columnID); err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&card).Update("column_id", columnID).Error
+	})
+	if err != nil {
+		return err
+	}
+	card.ColumnID = columnID
+
+	after := cardSnapshot(card)
+	after["commit"] = commit.ID
+	recordActivity(board.ID, actor, "card", card.ID, "moved", before, after)
+
+	notifyWatchers(card, fmt.Sprintf("%s closed %s in a commit", actor.Username, card.Title))
+	warnWIP(column, wip)
+
+	return nil
+}
+
+func GetCardCommits() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		card, column, ok := findCard(ctx)
+		if !ok {
+			return
+		}
+
+		if !isBoardMember(column.BoardID, user.ID) {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this board")
+			return
+		}
+
+		var commits []model.CardCommit
+		if err := database.DB.Where("card_id = ?", card.ID).Order("committed_at DESC, created_at DESC").Find(&commits).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get commits")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, commits, "success get commits")
+	}
+}
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"errors"
+	"kerjainaja/database"
+	"kerjainaja/helpers"
+	"kerjainaja/model"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// inboundURL is the address senders post cards to.
+func inboundURL(ctx *gin.Context, secret string) string {
+	return apiBaseURL(ctx) + "/api/inbound/" + secret
+}
+
+// adminColumn loads the column named by the :id parameter for an admin of
+// its board.
+func adminColumn(ctx *gin.Context, user model.User) (model.Column, bool) {
+	var column model.Column
+
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "column id is not valid")
+		return column, false
+	}
+
+	if err := database.DB.First(&column, "id = ?", id).Error; err != nil {
+		helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "column is not found")
+		return column, false
+	}
+
+	var board model.Board
+	if err := database.DB.First(&board, "id = ?", column.BoardID).Error; err != nil {
+		helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "board is not found")
+		return column, false
+	}
+
+	if !isBoardAdmin(board, user.ID) {
+		helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "only board admins can change board settings")
+		return column, false
+	}
+
+	return column, true
+}
+
+func GetInboundWebhooks() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		column, ok := adminColumn(ctx, user)
+		if !ok {
+			return
+		}
+
+		var hooks []model.InboundWebhook
+		if err := database.DB.Where("column_id = ? AND revoked_at IS NULL", column.ID).Order("created_at").Find(&hooks).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get webhooks")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, hooks, "success get webhooks")
+	}
+}
+
+func CreateInboundWebhook() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.NewInboundWebhook
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok || !requireSession(ctx) {
+			return
+		}
+
+		column, ok := adminColumn(ctx, user)
+		if !ok {
+			return
+		}
+
+		secret, hash, err := helpers.GenerateSecret(model.InboundWebhookPrefix)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to generate token")
+			return
+		}
+
+		hook := model.InboundWebhook{
+			BoardID:     column.BoardID,
+			ColumnID:    column.ID,
+			Name:        req.Name,
+			Prefix:      secret[:len(model.InboundWebhookPrefix)+6],
+			TokenHash:   hash,
+			CreatedByID: user.ID,
+		}
+
+		if err := database.DB.Create(&hook).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to create webhook")
+			return
+		}
+
+		recordActivity(column.BoardID, user, "webhook", hook.ID, "created", nil, map[string]any{"name": hook.Name, "column": column.Name})
+
+		data := map[string]any{
+			"url":     inboundURL(ctx, secret),
+			"webhook": hook,
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, data, "success create webhook, copy the link now because it will not be shown again")
+	}
+}
+
+func RevokeInboundWebhook() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		id, err := uuid.Parse(ctx.Param("id"))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "webhook id is not valid")
+			return
+		}
+
+		var hook model.InboundWebhook
+		if err := database.DB.First(&hook, "id = ? AND revoked_at IS NULL", id).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "webhook is not found")
+			return
+		}
+
+		var board model.Board
+		if err := database.DB.First(&board, "id = ?", hook.BoardID).Error; err != nil || !isBoardAdmin(board, user.ID) {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "webhook is not found")
+			return
+		}
+
+		if err := database.DB.Model(&hook).Update("revoked_at", time.Now()).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to revoke webhook")
+			return
+		}
+
+		recordActivity(hook.BoardID, user, "webhook", hook.ID, "revoked", map[string]any{"name": hook.Name}, nil)
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success revoke webhook")
+	}
+}
+
+// inboundLabels finds the labels of a board by name, creating the ones the
+// board doesn't have.
+func inboundLabels(tx *gorm.DB, boardID uuid.UUID, names []string) ([]model.Label, error) {
+	var existing []model.Label
+	if err := tx.Where("board_id = ?", boardID).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+
+	labels := []model.Label{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		var found *model.Label
+		for i := range existing {
+			if strings.EqualFold(existing[i].Name, name) {
+				found = &existing[i]
+			}
+		}
+
+		if found == nil {
+			label := model.Label{BoardID: boardID, Name: name}
+			if err := tx.Create(&label).Error; err != nil {
+				return nil, err
+			}
+			existing = append(existing, label)
+			found = &existing[len(existing)-1]
+		}
+
+		if !slices.ContainsFunc(labels, func(l model.Label) bool { return l.ID == found.ID }) {
+			labels = append(labels, *found)
+		}
+	}
+
+	return labels, nil
+}
+
+// ReceiveInboundWebhook creates a card from a posted JSON body. The token in
+// the path is the only credential. A repeated external_id answers with the
+// card made the first time instead of a new one.
+func ReceiveInboundWebhook() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var hook model.InboundWebhook
+		if err := database.DB.First(&hook, "token_hash = ? AND revoked_at IS NULL", helpers.HashSecret(ctx.Param("token"))).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "webhook is not found")
+			return
+		}
+
+		var req model.InboundCardRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request: "+err.Error())
+			return
+		}
+
+		dueDate, err := helpers.ParseDate(req.DueDate)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+			return
+		}
+
+		var creator model.User
+		if err := database.DB.First(&creator, "id = ? AND disabled_at IS NULL", hook.CreatedByID).Error; err != nil || !isBoardMember(hook.BoardID, creator.ID) {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "the creator of this webhook no longer has access to the board")
+			return
+		}
+
+		var column model.Column
+		if err := database.DB.First(&column, "id = ?", hook.ColumnID).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "column is not found")
+			return
+		}
+
+		database.DB.Model(&hook).Update("last_used_at", time.Now())
+
+		if req.ExternalID != "" {
+			if card, ok := inboundDuplicate(hook, req.ExternalID); ok {
+				helpers.ResponseJson(ctx, http.StatusOK, true, map[string]any{"card": card, "duplicate": true}, "card already exists")
+				return
+			}
+		}
+
+		card := model.Card{
+			Title:       req.Title,
+			Description: req.Description,
+			DueDate:     dueDate,
+			ColumnID:    column.ID,
+		}
+
+		var wip *model.WIPStatus
+		err = database.DB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if column, wip, err = admitCard(tx, column.ID); err != nil {
+				return err
+			}
+
+			labels, err := inboundLabels(tx, column.BoardID, req.Labels)
+			if err != nil {
+				return err
+			}
+			card.Labels = labels
+
+			if err := tx.Omit("Labels.*").Create(&card).Error; err != nil {
+				return err
+			}
+
+			if req.ExternalID == "" {
+				return nil
+			}
+
+			return tx.Create(&model.InboundCard{WebhookID: hook.ID, ExternalID: req.ExternalID, CardID: card.ID}).Error
+		})
+		if wipResponse(ctx, err) {
+			return
+		}
+		if err != nil {
+			// a concurrent request with the same external id got there first
+			if req.ExternalID != "" {
+				if existing, ok := inboundDuplicate(hook, req.ExternalID); ok {
+					helpers.ResponseJson(ctx, http.StatusOK, true, map[string]any{"card": existing, "duplicate": true}, "card already exists")
+					return
+				}
+			}
+
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to create card")
+			return
+		}
+
+		after := cardSnapshot(card)
+		after["webhook"] = hook.Name
+		recordActivity(column.BoardID, creator, "card", card.ID, "created", nil, after)
+		note := warnWIP(column, wip)
+
+		if err := broadcastBoard(column.BoardID); err != nil {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "board is not found")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, map[string]any{"card": card, "duplicate": false}, "success create card"+note)
+	}
+}
+
+// inboundDuplicate finds the card an external ID already became. A
+// reference to a card that was deleted since is dropped, so the ID can be
+// used again.
+func inboundDuplicate(hook model.InboundWebhook, externalID string) (model.Card, bool) {
+	var card model.Card
+
+	var ref model.InboundCard
+	if err := database.DB.First(&ref, "webhook_id = ? AND external_id = ?", hook.ID, externalID).Error; err != nil {
+		return card, false
+	}
+
+	err := database.DB.Preload("Labels").First(&card, "id = ?", ref.CardID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		database.DB.Delete(&ref)
+		return card, false
+	}
+
+	return card, err == nil
+}
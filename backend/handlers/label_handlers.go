@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"fmt"
+	"kerjainaja/database"
+	"kerjainaja/helpers"
+	"kerjainaja/model"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func GetLabels() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		boardID, err := uuid.Parse(ctx.Param("id"))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board id is not valid")
+			return
+		}
+
+		if !isBoardMember(boardID, user.ID) {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this board")
+			return
+		}
+
+		var labels []model.Label
+		if err := database.DB.Where("board_id = ?", boardID).Order("name").Find(&labels).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get labels")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, labels, "success get labels")
+	}
+}
+
+func CreateLabel() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.NewLabel
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		boardID, err := uuid.Parse(ctx.Param("id"))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board id is not valid")
+			return
+		}
+
+		if !isBoardMember(boardID, user.ID) {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this board")
+			return
+		}
+
+		var count int64
+		database.DB.Model(&model.Label{}).Where("board_id = ? AND name = ?", boardID, req.Name).Count(&count)
+		if count > 0 {
+			helpers.ResponseJson(ctx, http.StatusConflict, false, nil, "label already exists")
+			return
+		}
+
+		label := model.Label{
+			BoardID: boardID,
+			Name:    req.Name,
+			Color:   req.Color,
+		}
+
+		if err := database.DB.Create(&label).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to create label")
+			return
+		}
+
+		recordActivity(boardID, user, "label", label.ID, "created", nil, map[string]any{"name": label.Name, "color": label.Color})
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, label, "success create label")
+	}
+}
+
+func AddCardLabel() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.CardLabelRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		card, column, ok := findCard(ctx)
+		if !ok {
+			return
+		}
+
+		if !isBoardMember(column.BoardID, user.ID) {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this board")
+			return
+		}
+
+		var label model.Label
+		if err := database.DB.First(&label, "id = ? AND board_id = ?", req.LabelID, column.BoardID).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "label is not found on this board")
+			return
+		}
+
+		if slices.ContainsFunc(card.Labels, func(l model.Label) bool { return l.ID == label.ID }) {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "label already added")
+			return
+		}
+
+		before := cardSnapshot(card)
+		if err := database.DB.Model(&card).Association("Labels").Append(&label); err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to add label")
+			return
+		}
+
+		recordActivity(column.BoardID, user, "card", card.ID, "label_added", before, cardSnapshot(card))
+
+		notifyWatchers(card, fmt.Sprintf("%s added label %s to %s", user.Username, label.Name, card.Title))
+
+		if err := broadcastBoard(column.BoardID); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board is not found")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success add label")
+	}
+}
+
+func RemoveCardLabel() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		card, column, ok := findCard(ctx)
+		if !ok {
+			return
+		}
+
+		if !isBoardMember(column.BoardID, user.ID) {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this board")
+			return
+		}
+
+		labelID, err := uuid.Parse(ctx.Param("labelId"))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "label id is not valid")
+			return
+		}
+
+		if !slices.ContainsFunc(card.Labels, func(l model.Label) bool { return l.ID == labelID }) {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "label is not on this card")
+			return
+		}
+
+		before := cardSnapshot(card)
+		if err := database.DB.Model(&card).Association("Labels").Delete(&model.Label{ID: labelID}); err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to remove label")
+			return
+		}
+
+		recordActivity(column.BoardID, user, "card", card.ID, "label_removed", before, cardSnapshot(card))
+
+		if err := broadcastBoard(column.BoardID); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board is not found")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success remove label")
+	}
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"fmt"
+	"kerjainaja/database"
+	"kerjainaja/helpers"
+	"kerjainaja/model"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Failed logins are tracked per account and per IP. Past the threshold every
+// further failure doubles the lockout, starting at a minute and capped at an
+// hour. Failures older than failureWindow are forgotten.
+const (
+	accountFailureThreshold = 5
+	ipFailureThreshold      = 20
+	maxLockout              = time.Hour
+	failureWindow           = time.Hour
+)
+
+func accountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(email)
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// loginLockedFor returns how long a login with these keys has to wait.
+func loginLockedFor(keys ...string) time.Duration {
+	var throttles []model.LoginThrottle
+	if err := database.DB.Where("throttle_key IN ?", keys).Find(&throttles).Error; err != nil {
+		log.Printf("throttle: failed to read login throttle: %v", err)
+		return 0
+	}
+
+	var wait time.Duration
+	now := time.Now()
+	for _, t := range throttles {
+		if t.LockedUntil != nil && t.LockedUntil.After(now) {
+			wait = max(wait, t.LockedUntil.Sub(now))
+		}
+	}
+
+	return wait
+}
+
+// checkLoginLock answers 429 when the account or the client IP is locked.
+func checkLoginLock(ctx *gin.Context, email string) bool {
+	wait := loginLockedFor(accountThrottleKey(email), ipThrottleKey(ctx.ClientIP()))
+	if wait <= 0 {
+		return true
+	}
+
+	seconds := int(math.Ceil(wait.Seconds()))
+	ctx.Header("Retry-After", fmt.Sprint(seconds))
+	helpers.ResponseJson(ctx, http.StatusTooManyRequests, false, nil, fmt.Sprintf("terlalu banyak percobaan login, coba lagi dalam %d detik", seconds))
+	return false
+}
+
+// recordLoginFailure counts a failed login and locks the keys that went over
+// their threshold, writing an audit record for every new lockout.
+func recordLoginFailure(ctx *gin.Context, email string, userID *uuid.UUID) {
+	thresholds := map[string]int{
+		accountThrottleKey(email):     accountFailureThreshold,
+		ipThrottleKey(ctx.ClientIP()): ipFailureThreshold,
+	}
+
+	for key, threshold := range thresholds {
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			now := time.Now()
+
+			var throttle model.LoginThrottle
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("throttle_key = ?", key).First(&throttle).Error
+			if err != nil {
+				throttle = model.LoginThrottle{Key: key}
+			}
+
+			if now.Sub(throttle.LastFailedAt) > failureWindow {
+				throttle.Failures = 0
+			}
+
+			throttle.Failures++
+			throttle.LastFailedAt = now
+
+			locked := false
+			if throttle.Failures >= threshold {
+				lockout := min(time.Minute<<min(throttle.Failures-threshold, 6), maxLockout)
+				lockedUntil := now.Add(lockout)
+				throttle.LockedUntil = &lockedUntil
+				locked = true
+			}
+
+			if err := tx.Save(&throttle).Error; err != nil {
+				return err
+			}
+
+			if locked {
+				return tx.Create(&model.AuditLog{
+					UserID: userID,
+					Action: "login_locked",
+					IP:     ctx.ClientIP(),
+					Detail: fmt.Sprintf("%s locked until %s after %d failed attempts", key, throttle.LockedUntil.Format(time.RFC3339), throttle.Failures),
+				}).Error
+			}
+
+			return nil
+		})
+		if err != nil {
+			log.Printf("throttle: failed to record login failure for %s: %v", key, err)
+		}
+	}
+}
+
+// clearLoginFailures forgets the failures of an account after it logged in.
+// The IP counter is kept since one IP may be guessing many accounts.
+func clearLoginFailures(email string) {
+	database.DB.Where("throttle_key = ?", accountThrottleKey(email)).Delete(&model.LoginThrottle{})
+}
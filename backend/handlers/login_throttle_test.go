@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestLoginLockoutIgnoresForwardedFor(t *testing.T) {
+	s := newTestServer(t)
+	s.router.POST("/login", Login())
+
+	for i := range ipFailureThreshold {
+		login := map[string]string{"email": fmt.Sprintf("guess%d@example.com", i), "password": "wrong"}
+		res := s.request(http.MethodPost, "/login", "", login, "X-Forwarded-For", fmt.Sprintf("198.51.100.%d", i))
+		if res.Code != http.StatusNotAcceptable {
+			t.Fatalf("attempt %d: expected 406, got %d: %s", i+1, res.Code, res.Msg)
+		}
+	}
+
+	login := map[string]string{"email": "another@example.com", "password": "wrong"}
+	res := s.request(http.MethodPost, "/login", "", login, "X-Forwarded-For", "198.51.100.250")
+	if res.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the IP to stay locked with a new X-Forwarded-For, got %d: %s", res.Code, res.Msg)
+	}
+}
@@ -0,0 +1,403 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"kerjainaja/config"
+	"kerjainaja/crypto"
+	"kerjainaja/database"
+	"kerjainaja/helpers"
+	"kerjainaja/model"
+	"kerjainaja/sso"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const maxAvatarSize = 2 << 20
+
+var (
+	localePattern   = regexp.MustCompile(`^[a-z]{2}(-[A-Z]{2})?$`)
+	avatarExtension = map[string]string{
+		"image/png":  ".png",
+		"image/jpeg": ".jpg",
+		"image/gif":  ".gif",
+		"image/webp": ".webp",
+	}
+)
+
+// UploadDir is where uploaded files are stored and served from /uploads.
+func UploadDir() string {
+	if dir := config.Env("UPLOAD_DIR"); dir != "" {
+		return dir
+	}
+
+	return "uploads"
+}
+
+func UpdateProfile() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.UpdateProfile
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok || !requireSession(ctx) {
+			return
+		}
+
+		updates := map[string]any{}
+		newEmail := ""
+
+		if req.Name != nil {
+			name := strings.TrimSpace(*req.Name)
+			if name == "" {
+				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "name can not be empty")
+				return
+			}
+			updates["name"] = name
+		}
+
+		if req.Username != nil && *req.Username != user.Username {
+			username := strings.TrimSpace(*req.Username)
+			if username == "" {
+				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "username can not be empty")
+				return
+			}
+
+			var count int64
+			database.DB.Model(&model.User{}).Where("username = ? AND id <> ?", username, user.ID).Count(&count)
+			if count > 0 {
+				helpers.ResponseJson(ctx, http.StatusConflict, false, nil, "username sudah terdaftar")
+				return
+			}
+			updates["username"] = username
+		}
+
+		if req.Email != nil && *req.Email != user.Email {
+			var count int64
+			database.DB.Model(&model.User{}).Where("email = ? AND id <> ?", *req.Email, user.ID).Count(&count)
+			if count > 0 {
+				helpers.ResponseJson(ctx, http.StatusConflict, false, nil, "email sudah terdaftar")
+				return
+			}
+
+			// the current address stays until the new one is confirmed
+			updates["pending_email"] = *req.Email
+			newEmail = *req.Email
+		}
+
+		if req.Timezone != nil {
+			if _, err := time.LoadLocation(*req.Timezone); err != nil || *req.Timezone == "" {
+				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "timezone is not valid")
+				return
+			}
+			updates["timezone"] = *req.Timezone
+		}
+
+		if req.Locale != nil {
+			if !localePattern.MatchString(*req.Locale) {
+				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "locale is not valid")
+				return
+			}
+			updates["locale"] = *req.Locale
+		}
+
+		if len(updates) > 0 {
+			if err := database.DB.Model(&user).Updates(updates).Error; err != nil {
+				helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to update profile")
+				return
+			}
+		}
+
+		if err := database.DB.First(&user, "id = ?", user.ID).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "user is not found")
+			return
+		}
+
+		message := "success update profile"
+		if newEmail != "" {
+			if err := sendEmailChangeEmail(user, newEmail); err != nil {
+				log.Printf("mailer: failed to send email change confirmation to %s: %v", newEmail, err)
+			}
+			message = "success update profile, confirm the new email with the link sent to it"
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, user, message)
+	}
+}
+
+func ChangePassword() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.ChangePassword
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok || !requireSession(ctx) {
+			return
+		}
+
+		if err := crypto.ValidatePassword(user.Password, req.CurrentPassword); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "current password is wrong")
+			return
+		}
+
+		if err := database.DB.Model(&user).Update("password", crypto.HashPassword(req.NewPassword)).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to change password")
+			return
+		}
+
+		// other devices have to log in with the new password, this one stays
+		current, _ := ctx.Get("session")
+		if err := revokeSessions("user_id = ? AND id <> ?", user.ID, current.(model.Session).ID); err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to revoke sessions")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success change password")
+	}
+}
+
+func UploadAvatar() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxAvatarSize+1<<10)
+
+		fileHeader, err := ctx.FormFile("avatar")
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "avatar file is required")
+			return
+		}
+
+		if fileHeader.Size > maxAvatarSize {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "avatar can not be bigger than 2MB")
+			return
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "failed to read avatar")
+			return
+		}
+		defer file.Close()
+
+		content, err := io.ReadAll(file)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "failed to read avatar")
+			return
+		}
+
+		// the file name sent by the client is not trusted, only the content
+		extension, ok := avatarExtension[http.DetectContentType(content)]
+		if !ok {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "avatar must be a png, jpeg, gif or webp image")
+			return
+		}
+
+		dir := filepath.Join(UploadDir(), "avatars")
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to save avatar")
+			return
+		}
+
+		name := fmt.Sprintf("%s-%d%s", user.ID, time.Now().Unix(), extension)
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o644); err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to save avatar")
+			return
+		}
+
+		previous := user.AvatarURL
+		if err := database.DB.Model(&user).Update("avatar_url", "/uploads/avatars/"+name).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to save avatar")
+			return
+		}
+
+		removeAvatar(previous)
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, user, "success upload avatar")
+	}
+}
+
+func removeAvatar(avatarURL string) {
+	if !strings.HasPrefix(avatarURL, "/uploads/avatars/") {
+		return
+	}
+
+	path := filepath.Join(UploadDir(), "avatars", filepath.Base(avatarURL))
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("avatar: failed to remove %s: %v", path, err)
+	}
+}
+
+// DeleteAccount removes the caller's account. Boards only the caller is a
+// member of are deleted with it. Boards and workspaces the caller owns that
+// are shared with others block the deletion until they are handed over, so no
+// team loses them by surprise. Card assignments and watches are removed,
+// comments and the activity log keep the username they were written with.
+func DeleteAccount() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.DeleteAccount
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok || !requireSession(ctx) {
+			return
+		}
+
+		if req.Confirm != user.Username {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "type your username to confirm")
+			return
+		}
+
+		if passwordLoginAllowedFor(user) {
+			if err := crypto.ValidatePassword(user.Password, req.Password); err != nil {
+				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "password is wrong")
+				return
+			}
+		}
+
+		var boards []model.Board
+		if err := database.DB.Preload("Members").Where("owner_id = ?", user.ID).Find(&boards).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get boards")
+			return
+		}
+
+		var shared []map[string]any
+		var solo []uuid.UUID
+		for _, board := range boards {
+			if len(board.Members) > 1 || (len(board.Members) == 1 && board.Members[0].ID != user.ID) {
+				shared = append(shared, map[string]any{"id": board.ID, "name": board.Name})
+				continue
+			}
+			solo = append(solo, board.ID)
+		}
+
+		if len(shared) > 0 {
+			helpers.ResponseJson(ctx, http.StatusConflict, false, shared, "transfer the boards you own to another member first")
+			return
+		}
+
+		// workspaces follow the same rule as boards
+		var workspaces []model.Workspace
+		if err := database.DB.Preload("Members").Where("owner_id = ?", user.ID).Find(&workspaces).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get workspaces")
+			return
+		}
+
+		var soloWorkspaces []uuid.UUID
+		for _, workspace := range workspaces {
+			if len(workspace.Members) > 1 {
+				shared = append(shared, map[string]any{"id": workspace.ID, "name": workspace.Name})
+				continue
+			}
+			soloWorkspaces = append(soloWorkspaces, workspace.ID)
+		}
+
+		if len(shared) > 0 {
+			helpers.ResponseJson(ctx, http.StatusConflict, false, shared, "transfer the workspaces you own to another member first")
+			return
+		}
+
+		// boards without an owner that nobody else is left on
+		var orphaned []uuid.UUID
+		if err := database.DB.Table("board_members").
+			Select("board_id").
+			Where("board_id IN (?)", database.DB.Table("board_members").Select("board_id").Where("user_id = ?", user.ID)).
+			Group("board_id").
+			Having("COUNT(*) = 1").
+			Pluck("board_id", &orphaned).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get boards")
+			return
+		}
+
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			for _, boardID := range append(solo, orphaned...) {
+				if err := deleteBoard(tx, boardID); err != nil {
+					return err
+				}
+			}
+
+			if len(soloWorkspaces) > 0 {
+				if err := tx.Model(&model.Board{}).
+					Where("workspace_id IN ?", soloWorkspaces).
+					Updates(map[string]any{"workspace_id": nil, "visibility": model.VisibilityPrivate}).Error; err != nil {
+					return err
+				}
+
+				if err := tx.Where("id IN ?", soloWorkspaces).Delete(&model.Workspace{}).Error; err != nil {
+					return err
+				}
+			}
+
+			if err := tx.Model(&model.Comment{}).Where("author_id = ?", user.ID).Update("author_id", nil).Error; err != nil {
+				return err
+			}
+
+			for _, table := range []string{"board_members", "card_members", "card_watchers"} {
+				if err := tx.Exec("DELETE FROM "+table+" WHERE user_id = ?", user.ID).Error; err != nil {
+					return err
+				}
+			}
+
+			for _, owned := range []any{
+				&model.Session{},
+				&model.PersonalAccessToken{},
+				&model.UserToken{},
+				&model.RecoveryCode{},
+				&model.UserIdentity{},
+				&model.SavedFilter{},
+				&model.WorkspaceMember{},
+				&model.CalendarFeed{},
+			} {
+				if err := tx.Where("user_id = ?", user.ID).Delete(owned).Error; err != nil {
+					return err
+				}
+			}
+
+			return tx.Delete(&user).Error
+		})
+		if err != nil {
+			log.Printf("account: failed to delete %s: %v", user.ID, err)
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to delete account")
+			return
+		}
+
+		removeAvatar(user.AvatarURL)
+		clearSessionCookie(ctx)
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "account is deleted")
+	}
+}
+
+// passwordLoginAllowedFor reports whether a user is expected to know their
+// password. Accounts created through SSO on an SSO only deployment don't.
+func passwordLoginAllowedFor(user model.User) bool {
+	if !sso.PasswordLoginDisabled() {
+		return true
+	}
+
+	var count int64
+	database.DB.Model(&model.UserIdentity{}).Where("user_id = ?", user.ID).Count(&count)
+	return count == 0
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"kerjainaja/database"
+	"kerjainaja/mailer"
+	"kerjainaja/model"
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+)
+
+type sentMail struct {
+	to   string
+	body string
+}
+
+type testMailer struct {
+	sent []sentMail
+}
+
+func (m *testMailer) Send(to string, subject string, body string) error {
+	m.sent = append(m.sent, sentMail{to: to, body: body})
+	return nil
+}
+
+var mailToken = regexp.MustCompile(`token=(\S+)`)
+
+func TestEmailChangeWaitsForConfirmation(t *testing.T) {
+	s := newTestServer(t)
+	s.router.PUT("/users/me", UpdateProfile())
+	s.router.POST("/email/change", ConfirmEmailChange())
+
+	mails := &testMailer{}
+	previous := mailer.Default
+	mailer.Default = mails
+	t.Cleanup(func() { mailer.Default = previous })
+
+	user, token := s.user("alice")
+
+	if res := s.request(http.MethodPut, "/users/me", token, map[string]string{"email": "new@example.com"}); res.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", res.Code, res.Msg)
+	}
+
+	database.DB.First(&user, "id = ?", user.ID)
+	if user.Email != "alice@example.com" || user.EmailVerifiedAt == nil || user.PendingEmail != "new@example.com" {
+		t.Fatalf("expected the email to stay until it is confirmed, got %q pending %q", user.Email, user.PendingEmail)
+	}
+
+	if len(mails.sent) != 1 || mails.sent[0].to != "new@example.com" {
+		t.Fatalf("expected a confirmation at the new address, got %v", mails.sent)
+	}
+
+	match := mailToken.FindStringSubmatch(mails.sent[0].body)
+	if match == nil {
+		t.Fatalf("expected a link with a token, got %q", mails.sent[0].body)
+	}
+	secret, _ := url.QueryUnescape(match[1])
+
+	if res := s.request(http.MethodPost, "/email/change", "", model.VerifyEmail{Token: secret}); res.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", res.Code, res.Msg)
+	}
+
+	database.DB.First(&user, "id = ?", user.ID)
+	if user.Email != "new@example.com" || user.PendingEmail != "" || user.EmailVerifiedAt == nil {
+		t.Errorf("expected the confirmed email to be swapped in, got %q pending %q", user.Email, user.PendingEmail)
+	}
+
+	if res := s.request(http.MethodPost, "/email/change", "", model.VerifyEmail{Token: secret}); res.Code != http.StatusBadRequest {
+		t.Errorf("expected the token to work once, got %d", res.Code)
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"kerjainaja/database"
+	"kerjainaja/helpers"
+	"kerjainaja/search"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SearchCards() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		text := strings.TrimSpace(ctx.Query("q"))
+		if text == "" {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "query is empty")
+			return
+		}
+
+		page, limit := helpers.Pagination(ctx)
+
+		result, err := database.Search.SearchCards(database.DB, search.Query{
+			Text:   text,
+			UserID: user.ID,
+			Limit:  limit,
+			Offset: (page - 1) * limit,
+		})
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to search")
+			return
+		}
+
+		data := helpers.Page{
+			Items: result.Hits,
+			Page:  page,
+			Limit: limit,
+			Total: result.Total,
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, data, "success search")
+	}
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"kerjainaja/config"
+	"kerjainaja/database"
+	"kerjainaja/helpers"
+	"kerjainaja/model"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// issueSession starts a new session for the user and returns the access and
+// refresh tokens for it.
+func issueSession(ctx *gin.Context, user model.User) (map[string]any, error) {
+	refreshToken, refreshHash, err := helpers.GenerateSecret("")
+	if err != nil {
+		return nil, err
+	}
+
+	userAgent := ctx.Request.UserAgent()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	now := time.Now()
+	session := model.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: refreshHash,
+		UserAgent:        userAgent,
+		IP:               ctx.ClientIP(),
+		ExpiresAt:        now.Add(config.Duration("REFRESH_TOKEN_TTL", 30*24*time.Hour)),
+		LastUsedAt:       now,
+	}
+
+	if err := database.DB.Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	return sessionTokens(user, session, refreshToken)
+}
+
+func sessionTokens(user model.User, session model.Session, refreshToken string) (map[string]any, error) {
+	// access tokens are short lived, the client trades the refresh token for
+	// a new one before they expire
+	expiresAt := time.Now().Add(config.Duration("ACCESS_TOKEN_TTL", 15*time.Minute))
+
+	token, err := helpers.CreateTokenSession(user.ID, session.ID, user.Username, user.Role, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"token":         token,
+		"refresh_token": refreshToken,
+		"expires_at":    expiresAt,
+	}, nil
+}
+
+func revokeSessions(query any, args ...any) error {
+	return database.DB.Model(&model.Session{}).
+		Where(query, args...).
+		Where("revoked_at IS NULL").
+		Update("revoked_at", time.Now()).Error
+}
+
+func RefreshSession() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.RefreshRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		hash := helpers.HashSecret(req.RefreshToken)
+
+		var session model.Session
+		if err := database.DB.First(&session, "refresh_token_hash = ?", hash).Error; err != nil {
+			// an already rotated token is being replayed, assume it was stolen
+			if database.DB.First(&session, "previous_token_hash = ?", hash).Error == nil {
+				revokeSessions("id = ?", session.ID)
+				helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, "refresh token was already used, session is revoked")
+				return
+			}
+
+			helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, "refresh token is invalid")
+			return
+		}
+
+		now := time.Now()
+		if session.RevokedAt != nil || session.ExpiresAt.Before(now) {
+			helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, "session is revoked")
+			return
+		}
+
+		var user model.User
+		if err := database.DB.First(&user, "id = ?", session.UserID).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, "user is not found")
+			return
+		}
+
+		if user.DisabledAt != nil {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "account is disabled")
+			return
+		}
+
+		refreshToken, refreshHash, err := helpers.GenerateSecret("")
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to create token")
+			return
+		}
+
+		// the hash condition makes two concurrent refreshes with the same token
+		// end up with only one winner
+		result := database.DB.Model(&session).
+			Where("refresh_token_hash = ?", hash).
+			Updates(map[string]any{
+				"previous_token_hash": hash,
+				"refresh_token_hash":  refreshHash,
+				"last_used_at":        now,
+				"expires_at":          now.Add(config.Duration("REFRESH_TOKEN_TTL", 30*24*time.Hour)),
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, "refresh token is invalid")
+			return
+		}
+
+		data, err := sessionTokens(user, session, refreshToken)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to create token")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, data, "success refresh")
+	}
+}
+
+func GetSessions() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		var sessions []model.Session
+		if err := database.DB.
+			Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", user.ID, time.Now()).
+			Order("last_used_at DESC").
+			Find(&sessions).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get sessions")
+			return
+		}
+
+		var currentID uuid.UUID
+		if current, exists := ctx.Get("session"); exists {
+			currentID = current.(model.Session).ID
+		}
+
+		data := make([]map[string]any, 0, len(sessions))
+		for _, session := range sessions {
+			data = append(data, map[string]any{
+				"id":           session.ID,
+				"user_agent":   session.UserAgent,
+				"ip":           session.IP,
+				"created_at":   session.CreatedAt,
+				"last_used_at": session.LastUsedAt,
+				"expires_at":   session.ExpiresAt,
+				"current":      session.ID == currentID,
+			})
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, data, "success get sessions")
+	}
+}
+
+func RevokeSession() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		id, err := uuid.Parse(ctx.Param("id"))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "session id is not valid")
+			return
+		}
+
+		var session model.Session
+		if err := database.DB.First(&session, "id = ? AND user_id = ? AND revoked_at IS NULL", id, user.ID).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "session is not found")
+			return
+		}
+
+		if err := revokeSessions("id = ?", session.ID); err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to revoke session")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success revoke session")
+	}
+}
+
+func LogoutAll() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		if err := revokeSessions("user_id = ?", user.ID); err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to logout")
+			return
+		}
+
+		clearSessionCookie(ctx)
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success logout from all devices")
+	}
+}
+
+func clearSessionCookie(ctx *gin.Context) {
+	ctx.SetCookie("kerjainaja_session", "", -1, "/", config.Env("COOKIE_DOMAIN"), config.Env("APP_ENV") == "production", true)
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"kerjainaja/crypto"
+	"kerjainaja/database"
+	"kerjainaja/middleware"
+	"kerjainaja/model"
+	"kerjainaja/search"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type testServer struct {
+	t      *testing.T
+	router *gin.Engine
+}
+
+type testResponse struct {
+	Code   int
+	Status bool            `json:"status"`
+	Data   json.RawMessage `json:"data"`
+	Msg    string          `json:"msg"`
+}
+
+// newTestServer points the handlers at an empty in-memory database. Routes are
+// registered by the test itself on s.router.
+func newTestServer(t *testing.T) *testServer {
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv("TRUSTED_PROXIES", "")
+
+	db, err := gorm.Open(sqlite.Open("file::memory:?_pragma=foreign_keys(0)"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	// sqlite has no FULLTEXT indexes, the search engine builds its own
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&model.Card{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range stmt.Schema.Fields {
+		if strings.Contains(field.TagSettings["INDEX"], "FULLTEXT") {
+			delete(field.TagSettings, "INDEX")
+		}
+	}
+
+	if err := db.AutoMigrate(database.Models...); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := search.SQLite{}
+	if err := engine.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	previousDB, previousSearch := database.DB, database.Search
+	database.DB, database.Search = db, engine
+	t.Cleanup(func() { database.DB, database.Search = previousDB, previousSearch })
+
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	if err := middleware.TrustProxies(router); err != nil {
+		t.Fatal(err)
+	}
+
+	return &testServer{t: t, router: router}
+}
+
+func (s *testServer) request(method string, path string, token string, body any, headers ...string) testResponse {
+	s.t.Helper()
+
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest(method, path, &buf)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	res := testResponse{Code: w.Code}
+	if w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			s.t.Fatalf("%s %s: %v in %s", method, path, err, w.Body.String())
+		}
+	}
+
+	return res
+}
+
+// user creates a verified account with the password "password1" and logs it
+// in.
+func (s *testServer) user(name string) (model.User, string) {
+	s.t.Helper()
+
+	now := time.Now()
+	user := model.User{
+		Name:            name,
+		Username:        name,
+		Email:           name + "@example.com",
+		Password:        crypto.HashPassword("password1"),
+		EmailVerifiedAt: &now,
+	}
+	if err := database.DB.Create(&user).Error; err != nil {
+		s.t.Fatal(err)
+	}
+
+	return user, s.login(user)
+}
+
+func (s *testServer) login(user model.User) string {
+	s.t.Helper()
+
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/login", nil)
+
+	tokens, err := issueSession(ctx, user)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+
+	return tokens["token"].(string)
+}
+
+// events collects what is broadcast to SSE clients from now on.
+func (s *testServer) events() func() []string {
+	client := &SSEClient{ch: make(chan string, 64), done: make(chan struct{})}
+
+	sseMutex.Lock()
+	sseClients[client] = struct{}{}
+	sseMutex.Unlock()
+
+	s.t.Cleanup(func() {
+		sseMutex.Lock()
+		delete(sseClients, client)
+		sseMutex.Unlock()
+	})
+
+	return func() []string {
+		var received []string
+		for {
+			select {
+			case msg := <-client.ch:
+				received = append(received, msg)
+			default:
+				return received
+			}
+		}
+	}
+}
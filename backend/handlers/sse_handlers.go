@@ -1,10 +1,17 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"kerjainaja/helpers"
+	"kerjainaja/model"
+	"kerjainaja/webhooks"
+	"log"
 	"sync"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type SSEClient struct {
@@ -68,3 +75,77 @@ func BroadcastEventWithType(eventType, jsonData string) {
 		}
 	}
 }
+
+// BroadcastBoardEvent sends an event that belongs to a board to every SSE
+// client and to the webhooks of the board.
+func BroadcastBoardEvent(boardID uuid.UUID, eventType string, data any) {
+	jsonBytes, err := helpers.CreateJsonBytes(data)
+	if err != nil {
+		panic(err)
+	}
+
+	BroadcastEventWithType(eventType, string(jsonBytes))
+
+	payload, err := webhookPayload(jsonBytes)
+	if err != nil {
+		log.Printf("webhooks: failed to prepare %s for board %s: %v", eventType, boardID, err)
+		return
+	}
+	webhooks.Publish(boardID, eventType, payload)
+}
+
+// webhookPayload is the body of an event as it leaves for a webhook. The
+// receiver is a third party, so the members and watchers in it only keep
+// what the people directory shows of a user.
+func webhookPayload(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var payload any
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	if err := publicMembers(payload); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(payload)
+}
+
+func publicMembers(value any) error {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, field := range value {
+			users, isList := field.([]any)
+			if (key == "members" || key == "watchers") && isList {
+				for i, u := range users {
+					raw, err := json.Marshal(u)
+					if err != nil {
+						return err
+					}
+
+					var public model.PublicUser
+					if err := json.Unmarshal(raw, &public); err != nil {
+						return err
+					}
+					users[i] = public
+				}
+				continue
+			}
+
+			if err := publicMembers(field); err != nil {
+				return err
+			}
+		}
+
+	case []any:
+		for _, item := range value {
+			if err := publicMembers(item); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"kerjainaja/config"
+	"kerjainaja/crypto"
+	"kerjainaja/database"
+	"kerjainaja/helpers"
+	"kerjainaja/model"
+	"kerjainaja/sso"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const ssoStateCookie = "kerjainaja_sso"
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-z0-9_.-]+`)
+
+var errSSOLinkRequired = errors.New("an account with this email already exists, sign in with your password and link SSO from your profile")
+
+// passwordLoginAllowed rejects password based endpoints on deployments that
+// only log in through SSO.
+func passwordLoginAllowed(ctx *gin.Context) bool {
+	if sso.PasswordLoginDisabled() {
+		helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "password login is disabled, use single sign-on")
+		return false
+	}
+
+	return true
+}
+
+// ssoRedirect sends the browser back to the frontend. Tokens and errors go in
+// the fragment so they never reach server logs.
+func ssoRedirect(ctx *gin.Context, fragment url.Values) {
+	ctx.Redirect(http.StatusFound, config.Env("APP_URL")+"/login/sso#"+fragment.Encode())
+}
+
+func ssoError(ctx *gin.Context, msg string) {
+	ssoRedirect(ctx, url.Values{"error": {msg}})
+}
+
+func GetAuthProviders() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		data := map[string]any{
+			"providers":      sso.Names(),
+			"password_login": !sso.PasswordLoginDisabled(),
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, data, "success get providers")
+	}
+}
+
+func SSOLogin() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		name := ctx.Param("provider")
+
+		provider, err := sso.Get(ctx.Request.Context(), name)
+		if err != nil {
+			log.Printf("sso: %v", err)
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "provider is not available")
+			return
+		}
+
+		state, _, err := helpers.GenerateSecret("")
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to start login")
+			return
+		}
+
+		nonce, _, err := helpers.GenerateSecret("")
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to start login")
+			return
+		}
+
+		// a logged in user linking an identity comes with a token from LinkSSO
+		link := ""
+		if linkToken := ctx.Query("link"); linkToken != "" {
+			claims, err := helpers.ParseAndValidateToken(linkToken)
+			if err != nil || claims["typ"] != "sso_link" {
+				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "link request is invalid or expired")
+				return
+			}
+			link, _ = claims["sub"].(string)
+		}
+
+		verifier := sso.GenerateVerifier()
+
+		stateToken, err := helpers.CreateSSOStateToken(name, state, nonce, verifier, link, time.Now().Add(10*time.Minute))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to start login")
+			return
+		}
+
+		ctx.SetSameSite(http.SameSiteLaxMode)
+		ctx.SetCookie(ssoStateCookie, stateToken, int((10 * time.Minute).Seconds()), "/", config.Env("COOKIE_DOMAIN"), config.Env("APP_ENV") == "production", true)
+
+		ctx.Redirect(http.StatusFound, provider.AuthCodeURL(state, nonce, verifier))
+	}
+}
+
+func SSOCallback() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		name := ctx.Param("provider")
+
+		stateToken, err := ctx.Cookie(ssoStateCookie)
+		ctx.SetCookie(ssoStateCookie, "", -1, "/", config.Env("COOKIE_DOMAIN"), config.Env("APP_ENV") == "production", true)
+		if err != nil {
+			ssoError(ctx, "login session is expired")
+			return
+		}
+
+		claims, err := helpers.ParseAndValidateToken(stateToken)
+		if err != nil || claims["typ"] != "sso_state" || claims["provider"] != name {
+			ssoError(ctx, "login session is invalid")
+			return
+		}
+
+		if claims["state"] != ctx.Query("state") {
+			ssoError(ctx, "login state does not match")
+			return
+		}
+
+		if providerErr := ctx.Query("error"); providerErr != "" {
+			ssoError(ctx, providerErr)
+			return
+		}
+
+		provider, err := sso.Get(ctx.Request.Context(), name)
+		if err != nil {
+			log.Printf("sso: %v", err)
+			ssoError(ctx, "provider is not available")
+			return
+		}
+
+		verifier, _ := claims["verifier"].(string)
+		nonce, _ := claims["nonce"].(string)
+
+		idClaims, err := provider.Exchange(ctx.Request.Context(), ctx.Query("code"), verifier, nonce)
+		if err != nil {
+			log.Printf("sso: %v", err)
+			ssoError(ctx, "login failed")
+			return
+		}
+
+		if link, _ := claims["link"].(string); link != "" {
+			linkSSOIdentity(ctx, name, link, idClaims)
+			return
+		}
+
+		finishSSOLogin(ctx, name, idClaims)
+	}
+}
+
+// finishSSOLogin logs in the user of a verified identity. Users with two factor
+// authentication get the same challenge as a password login.
+func finishSSOLogin(ctx *gin.Context, provider string, claims sso.Claims) {
+	user, err := ssoUser(provider, claims)
+	if err != nil {
+		ssoError(ctx, err.Error())
+		return
+	}
+
+	if user.DisabledAt != nil {
+		ssoError(ctx, "account is disabled")
+		return
+	}
+
+	if user.TOTPEnabled {
+		data, err := twoFactorChallenge(user)
+		if err != nil {
+			ssoError(ctx, "failed to create session")
+			return
+		}
+
+		ssoRedirect(ctx, url.Values{
+			"two_factor_required": {"true"},
+			"challenge_token":     {fmt.Sprint(data["challenge_token"])},
+		})
+		return
+	}
+
+	data, err := issueSession(ctx, user)
+	if err != nil {
+		ssoError(ctx, "failed to create session")
+		return
+	}
+
+	ssoRedirect(ctx, url.Values{
+		"token":         {fmt.Sprint(data["token"])},
+		"refresh_token": {fmt.Sprint(data["refresh_token"])},
+	})
+}
+
+// LinkSSO starts linking an identity provider to the logged in account. The
+// browser is sent to the returned url, the login there ends in the callback
+// linking the identity.
+func LinkSSO() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok || !requireSession(ctx) {
+			return
+		}
+
+		name := ctx.Param("provider")
+		if _, err := sso.Get(ctx.Request.Context(), name); err != nil {
+			log.Printf("sso: %v", err)
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "provider is not available")
+			return
+		}
+
+		token, err := helpers.CreateSSOLinkToken(user.ID, time.Now().Add(5*time.Minute))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to start linking")
+			return
+		}
+
+		data := map[string]any{
+			"url": apiBaseURL(ctx) + "/api/oidc/" + url.PathEscape(name) + "/login?link=" + url.QueryEscape(token),
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, data, "open the url to link the provider")
+	}
+}
+
+// linkSSOIdentity adds the identity to the user that started linking it. An
+// identity already linked to another user stays where it is.
+func linkSSOIdentity(ctx *gin.Context, provider string, userID string, claims sso.Claims) {
+	var user model.User
+	if err := database.DB.First(&user, "id = ?", userID).Error; err != nil || user.DisabledAt != nil {
+		ssoError(ctx, "user is not found")
+		return
+	}
+
+	var identity model.UserIdentity
+	err := database.DB.Where("provider = ? AND subject = ?", provider, claims.Subject).First(&identity).Error
+	if err == nil {
+		if identity.UserID != user.ID {
+			ssoError(ctx, "this identity is already linked to another account")
+			return
+		}
+
+		ssoRedirect(ctx, url.Values{"linked": {provider}})
+		return
+	}
+
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		ssoError(ctx, "linking failed")
+		return
+	}
+
+	if err := database.DB.Create(&model.UserIdentity{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}).Error; err != nil {
+		log.Printf("sso: failed to link %s identity %s: %v", provider, claims.Subject, err)
+		ssoError(ctx, "linking failed")
+		return
+	}
+
+	ssoRedirect(ctx, url.Values{"linked": {provider}})
+}
+
+// ssoUser finds the user an identity belongs to. Unknown identities are
+// linked to the user with the same email when both the provider and this app
+// have verified it, an unverified account has to link the identity itself.
+// Without a user with the email a new one is created.
+func ssoUser(provider string, claims sso.Claims) (model.User, error) {
+	var user model.User
+
+	var identity model.UserIdentity
+	err := database.DB.Where("provider = ? AND subject = ?", provider, claims.Subject).First(&identity).Error
+	if err == nil {
+		if err := database.DB.First(&user, "id = ?", identity.UserID).Error; err != nil {
+			return user, errors.New("user is not found")
+		}
+
+		return user, nil
+	}
+
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return user, errors.New("login failed")
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return user, errors.New("the provider did not return a verified email")
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("email = ?", claims.Email).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			user, err = createSSOUser(tx, claims)
+		} else if err == nil && user.EmailVerifiedAt == nil {
+			return errSSOLinkRequired
+		}
+		if err != nil {
+			return err
+		}
+
+		if user.EmailVerifiedAt == nil {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+			if err := tx.Model(&user).Update("email_verified_at", now).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&model.UserIdentity{
+			UserID:   user.ID,
+			Provider: provider,
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+		}).Error
+	})
+	if errors.Is(err, errSSOLinkRequired) {
+		return user, err
+	}
+	if err != nil {
+		log.Printf("sso: failed to link %s identity %s: %v", provider, claims.Subject, err)
+		return user, errors.New("login failed")
+	}
+
+	return user, nil
+}
+
+func createSSOUser(tx *gorm.DB, claims sso.Claims) (model.User, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	base = usernameInvalidChars.ReplaceAllString(strings.ToLower(base), "")
+	if base == "" {
+		base = "user"
+	}
+
+	username := base
+	for i := 2; ; i++ {
+		var count int64
+		if err := tx.Model(&model.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+			return model.User{}, err
+		}
+		if count == 0 {
+			break
+		}
+		username = fmt.Sprintf("%s%d", base, i)
+	}
+
+	name := claims.Name
+	if name == "" {
+		name = username
+	}
+
+	// the account can only log in through its identity provider until a
+	// password is set with the reset flow
+	password, _, err := helpers.GenerateSecret("")
+	if err != nil {
+		return model.User{}, err
+	}
+
+	user := model.User{
+		Name:     name,
+		Username: username,
+		Email:    claims.Email,
+		Password: crypto.HashPassword(password),
+	}
+
+	return user, tx.Create(&user).Error
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"kerjainaja/database"
+	"kerjainaja/model"
+	"kerjainaja/sso"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ssoResult runs the part of the callback after the provider returned its
+// claims and reads the fragment of the redirect to the frontend.
+func ssoResult(t *testing.T, finish func(ctx *gin.Context)) url.Values {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/api/oidc/company/callback", nil)
+
+	finish(ctx)
+
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected a redirect, got %d", w.Code)
+	}
+
+	_, fragment, _ := strings.Cut(w.Header().Get("Location"), "#")
+	values, err := url.ParseQuery(fragment)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return values
+}
+
+func identityCount(t *testing.T, user model.User) int64 {
+	t.Helper()
+
+	var count int64
+	if err := database.DB.Model(&model.UserIdentity{}).Where("user_id = ?", user.ID).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return count
+}
+
+func TestSSOLoginLinksVerifiedAccountsOnly(t *testing.T) {
+	server := newTestServer(t)
+	t.Setenv("APP_URL", "http://app.test")
+
+	unverified := model.User{Name: "Mallory", Username: "mallory", Email: "ceo@example.com", Password: "x"}
+	if err := database.DB.Create(&unverified).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	claims := sso.Claims{Subject: "ceo", Email: "ceo@example.com", EmailVerified: true}
+	result := ssoResult(t, func(ctx *gin.Context) { finishSSOLogin(ctx, "company", claims) })
+	if result.Get("token") != "" || !strings.Contains(result.Get("error"), "link SSO from your profile") {
+		t.Errorf("expected the unverified account to be refused, got %v", result)
+	}
+	if identityCount(t, unverified) != 0 {
+		t.Error("expected no identity to be linked to the unverified account")
+	}
+
+	verified, _ := server.user("alice")
+	claims = sso.Claims{Subject: "alice", Email: verified.Email, EmailVerified: true}
+	result = ssoResult(t, func(ctx *gin.Context) { finishSSOLogin(ctx, "company", claims) })
+	if result.Get("token") == "" {
+		t.Errorf("expected the verified account to log in, got %v", result)
+	}
+	if identityCount(t, verified) != 1 {
+		t.Error("expected the identity to be linked to the verified account")
+	}
+}
+
+func TestSSOLoginAsksForSecondFactor(t *testing.T) {
+	server := newTestServer(t)
+	t.Setenv("APP_URL", "http://app.test")
+
+	user, _ := server.user("alice")
+	database.DB.Model(&user).Update("totp_enabled", true)
+	database.DB.Create(&model.UserIdentity{UserID: user.ID, Provider: "company", Subject: "alice"})
+
+	var sessionsBefore int64
+	database.DB.Model(&model.Session{}).Where("user_id = ?", user.ID).Count(&sessionsBefore)
+
+	claims := sso.Claims{Subject: "alice", Email: user.Email, EmailVerified: true}
+	result := ssoResult(t, func(ctx *gin.Context) { finishSSOLogin(ctx, "company", claims) })
+	if result.Get("token") != "" || result.Get("challenge_token") == "" {
+		t.Fatalf("expected a two factor challenge instead of a session, got %v", result)
+	}
+
+	var sessionsAfter int64
+	database.DB.Model(&model.Session{}).Where("user_id = ?", user.ID).Count(&sessionsAfter)
+	if sessionsAfter != sessionsBefore {
+		t.Error("expected no session before the second factor")
+	}
+}
+
+func TestLinkSSOIdentity(t *testing.T) {
+	server := newTestServer(t)
+	t.Setenv("APP_URL", "http://app.test")
+
+	alice, _ := server.user("alice")
+	bob, _ := server.user("bob")
+
+	claims := sso.Claims{Subject: "alice-at-company", Email: "alice@company.test"}
+	result := ssoResult(t, func(ctx *gin.Context) { linkSSOIdentity(ctx, "company", alice.ID.String(), claims) })
+	if result.Get("linked") != "company" || identityCount(t, alice) != 1 {
+		t.Fatalf("expected the identity to be linked, got %v", result)
+	}
+
+	result = ssoResult(t, func(ctx *gin.Context) { linkSSOIdentity(ctx, "company", bob.ID.String(), claims) })
+	if result.Get("error") == "" || identityCount(t, bob) != 0 {
+		t.Errorf("expected an identity of another account to stay where it is, got %v", result)
+	}
+}
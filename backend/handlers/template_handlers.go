@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"kerjainaja/database"
+	"kerjainaja/helpers"
+	"kerjainaja/model"
+	"kerjainaja/templates"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// templatesOf matches the saved templates a user can use, their own and the
+// ones shared with their workspaces.
+func templatesOf(userID uuid.UUID) *gorm.DB {
+	return database.DB.Where("owner_id = ? OR workspace_id IN (SELECT workspace_id FROM workspace_members WHERE user_id = ?)", userID, userID)
+}
+
+// templateContent resolves a built-in template name or a saved template id.
+func templateContent(user model.User, id string) (templates.Content, error) {
+	if builtin, ok := templates.Builtin[id]; ok {
+		return builtin.Content, nil
+	}
+
+	var content templates.Content
+
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return content, errors.New("template is not found")
+	}
+
+	var saved model.BoardTemplate
+	if err := templatesOf(user.ID).First(&saved, "id = ?", parsedID).Error; err != nil {
+		return content, errors.New("template is not found")
+	}
+
+	if err := json.Unmarshal(saved.Content, &content); err != nil {
+		return content, errors.New("template is broken")
+	}
+
+	return content, nil
+}
+
+// boardStructure captures a board the way templates store it.
+func boardStructure(boardID uuid.UUID, opts templates.Options) (model.Board, templates.Content, error) {
+	board, err := loadBoard(boardID)
+	if err != nil {
+		return board, templates.Content{}, err
+	}
+
+	var labels []model.Label
+	if err := database.DB.Where("board_id = ?", boardID).Order("name").Find(&labels).Error; err != nil {
+		return board, templates.Content{}, err
+	}
+
+	return board, templates.FromBoard(board, labels, opts), nil
+}
+
+func GetTemplates() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		var saved []model.BoardTemplate
+		if err := templatesOf(user.ID).Order("name").Find(&saved).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get templates")
+			return
+		}
+
+		builtin := make([]map[string]any, 0, len(templates.Builtin))
+		for id, t := range templates.Builtin {
+			builtin = append(builtin, map[string]any{
+				"id":          id,
+				"name":        t.Name,
+				"description": t.Description,
+				"content":     t.Content,
+			})
+		}
+		sort.Slice(builtin, func(i, j int) bool { return builtin[i]["name"].(string) < builtin[j]["name"].(string) })
+
+		data := map[string]any{
+			"builtin": builtin,
+			"saved":   saved,
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, data, "success get templates")
+	}
+}
+
+func CreateTemplate() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.NewTemplate
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		boardID, err := uuid.Parse(ctx.Param("id"))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board id is not valid")
+			return
+		}
+
+		if !isBoardMember(boardID, user.ID) {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this board")
+			return
+		}
+
+		template := model.BoardTemplate{
+			Name:        req.Name,
+			Description: req.Description,
+			OwnerID:     user.ID,
+		}
+
+		if req.WorkspaceID != "" {
+			workspaceID := uuid.MustParse(req.WorkspaceID)
+			if _, ok := workspaceMembership(workspaceID, user.ID); !ok {
+				helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this workspace")
+				return
+			}
+			template.WorkspaceID = &workspaceID
+		}
+
+		_, content, err := boardStructure(boardID, templates.Options{Cards: req.IncludeCards})
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "board is not found")
+			return
+		}
+
+		template.Content, err = json.Marshal(content)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to save template")
+			return
+		}
+
+		if err := database.DB.Create(&template).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to save template")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, template, "success save template")
+	}
+}
+
+func DeleteTemplate() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		id, err := uuid.Parse(ctx.Param("id"))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "template id is not valid")
+			return
+		}
+
+		result := database.DB.Where("id = ? AND owner_id = ?", id, user.ID).Delete(&model.BoardTemplate{})
+		if result.Error != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to delete template")
+			return
+		}
+
+		if result.RowsAffected == 0 {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "template is not found")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success delete template")
+	}
+}
+
+// DuplicateBoard deep copies a board with its members, columns, labels and,
+// unless include_cards is false, its cards. The caller owns the copy.
+// Watchers and activity are not copied.
+func DuplicateBoard() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.DuplicateBoard
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		boardID, err := uuid.Parse(ctx.Param("id"))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board id is not valid")
+			return
+		}
+
+		if !isBoardMember(boardID, user.ID) {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this board")
+			return
+		}
+
+		includeCards := req.IncludeCards == nil || *req.IncludeCards
+
+		source, content, err := boardStructure(boardID, templates.Options{Cards: includeCards, Details: true})
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "board is not found")
+			return
+		}
+
+		board := model.Board{
+			Name:       req.Name,
+			OwnerID:    &user.ID,
+			Visibility: model.VisibilityPrivate,
+		}
+
+		// the copy stays in the workspace only if the caller is part of it
+		workspaceID := ""
+		if source.WorkspaceID != nil {
+			if _, ok := workspaceMembership(*source.WorkspaceID, user.ID); ok {
+				workspaceID = source.WorkspaceID.String()
+			}
+		}
+
+		workspace, ok := newBoardWorkspace(ctx, user, workspaceID)
+		if !ok {
+			return
+		}
+
+		if workspace != nil {
+			board.WorkspaceID = &workspace.ID
+			board.Visibility = source.Visibility
+		}
+
+		err = database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&board).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Model(&board).Omit("Members.*").Association("Members").Append(source.Members); err != nil {
+				return err
+			}
+
+			return templates.Apply(tx, board.ID, content)
+		})
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to duplicate board")
+			return
+		}
+
+		recordActivity(board.ID, user, "board", board.ID, "created", nil, map[string]any{"name": board.Name, "duplicated_from": source.ID})
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, board, "success duplicate board")
+	}
+}
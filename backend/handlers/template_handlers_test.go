@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"kerjainaja/database"
+	"kerjainaja/model"
+	"net/http"
+	"testing"
+)
+
+func TestDuplicateBoardChecksBoardCreation(t *testing.T) {
+	s := newTestServer(t)
+	s.router.POST("/boards/:id/duplicate", DuplicateBoard())
+
+	owner, _ := s.user("owner")
+	member, token := s.user("member")
+
+	workspace := model.Workspace{Name: "Team", OwnerID: owner.ID}
+	database.DB.Create(&workspace)
+	database.DB.Model(&workspace).Update("members_can_create", false)
+	database.DB.Create(&model.WorkspaceMember{WorkspaceID: workspace.ID, UserID: owner.ID, Role: model.WorkspaceRoleOwner})
+	database.DB.Create(&model.WorkspaceMember{WorkspaceID: workspace.ID, UserID: member.ID, Role: model.WorkspaceRoleMember})
+
+	board := model.Board{Name: "Roadmap", OwnerID: &owner.ID, WorkspaceID: &workspace.ID, Visibility: model.VisibilityWorkspace, Members: []model.User{owner, member}}
+	if err := database.DB.Create(&board).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	path := "/boards/" + board.ID.String() + "/duplicate"
+
+	res := s.request(http.MethodPost, path, token, model.DuplicateBoard{Name: "Copy"})
+	if res.Code != http.StatusForbidden {
+		t.Errorf("expected a member to be kept from adding boards to the workspace, got %d: %s", res.Code, res.Msg)
+	}
+
+	database.DB.Model(&workspace).Update("members_can_create", true)
+	t.Setenv("REQUIRE_VERIFIED_EMAIL", "true")
+	database.DB.Model(&member).Update("email_verified_at", nil)
+
+	res = s.request(http.MethodPost, path, token, model.DuplicateBoard{Name: "Copy"})
+	if res.Code != http.StatusForbidden {
+		t.Errorf("expected an unverified user to be kept from duplicating, got %d: %s", res.Code, res.Msg)
+	}
+
+	var count int64
+	database.DB.Model(&model.Board{}).Count(&count)
+	if count != 1 {
+		t.Errorf("expected no copy to be made, got %d boards", count)
+	}
+}
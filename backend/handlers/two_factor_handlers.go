@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"errors"
+	"kerjainaja/crypto"
+	"kerjainaja/database"
+	"kerjainaja/helpers"
+	"kerjainaja/model"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const recoveryCodeCount = 10
+
+// verifySecondFactor accepts either a TOTP code, which can't be used twice, or
+// an unused recovery code.
+func verifySecondFactor(user model.User, code string, recoveryCode string) error {
+	if code != "" {
+		step, ok := crypto.ValidateTOTP(user.TOTPSecret, code, time.Now())
+		if !ok {
+			return errors.New("code is not valid")
+		}
+
+		result := database.DB.Model(&model.User{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			Update("totp_last_step", step)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return errors.New("code is already used")
+		}
+
+		return nil
+	}
+
+	if recoveryCode == "" {
+		return errors.New("code is required")
+	}
+
+	var codes []model.RecoveryCode
+	if err := database.DB.Where("user_id = ? AND used_at IS NULL", user.ID).Find(&codes).Error; err != nil {
+		return err
+	}
+
+	recoveryCode = strings.ToLower(strings.TrimSpace(recoveryCode))
+	for _, c := range codes {
+		if crypto.ValidatePassword(c.CodeHash, recoveryCode) != nil {
+			continue
+		}
+
+		result := database.DB.Model(&c).Where("used_at IS NULL").Update("used_at", time.Now())
+		if result.Error != nil || result.RowsAffected == 0 {
+			return errors.New("recovery code is already used")
+		}
+
+		return nil
+	}
+
+	return errors.New("recovery code is not valid")
+}
+
+// replaceRecoveryCodes drops the old recovery codes of the user and returns a
+// fresh set in plain text, the only time they are ever visible.
+func replaceRecoveryCodes(user model.User) ([]string, error) {
+	codes, err := crypto.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := database.DB.Where("user_id = ?", user.ID).Delete(&model.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	rows := make([]model.RecoveryCode, 0, len(codes))
+	for _, code := range codes {
+		rows = append(rows, model.RecoveryCode{UserID: user.ID, CodeHash: crypto.HashPassword(code)})
+	}
+
+	if err := database.DB.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func EnrollTwoFactor() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok || !requireSession(ctx) {
+			return
+		}
+
+		if user.TOTPEnabled {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "two factor authentication is already enabled")
+			return
+		}
+
+		secret, err := crypto.GenerateTOTPSecret()
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to generate secret")
+			return
+		}
+
+		if err := database.DB.Model(&user).Updates(map[string]any{"totp_secret": secret, "totp_last_step": 0}).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to save secret")
+			return
+		}
+
+		data := map[string]any{
+			"secret":      secret,
+			"otpauth_uri": crypto.TOTPURI("kerjainaja", user.Email, secret),
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, data, "scan the code and confirm it with a code from the app")
+	}
+}
+
+func EnableTwoFactor() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.TwoFactorCode
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok || !requireSession(ctx) {
+			return
+		}
+
+		if user.TOTPEnabled {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "two factor authentication is already enabled")
+			return
+		}
+
+		if user.TOTPSecret == "" {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "start the enrollment first")
+			return
+		}
+
+		if err := verifySecondFactor(user, req.Code, ""); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+			return
+		}
+
+		codes, err := replaceRecoveryCodes(user)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to create recovery codes")
+			return
+		}
+
+		if err := database.DB.Model(&user).Update("totp_enabled", true).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to enable two factor authentication")
+			return
+		}
+
+		data := map[string]any{
+			"recovery_codes": codes,
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, data, "two factor authentication is enabled, store the recovery codes somewhere safe")
+	}
+}
+
+func DisableTwoFactor() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.DisableTwoFactor
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok || !requireSession(ctx) {
+			return
+		}
+
+		if !user.TOTPEnabled {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "two factor authentication is not enabled")
+			return
+		}
+
+		if err := crypto.ValidatePassword(user.Password, req.Password); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "password is wrong")
+			return
+		}
+
+		if err := verifySecondFactor(user, req.Code, req.RecoveryCode); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+			return
+		}
+
+		if err := database.DB.Model(&user).Updates(map[string]any{"totp_enabled": false, "totp_secret": ""}).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to disable two factor authentication")
+			return
+		}
+
+		database.DB.Where("user_id = ?", user.ID).Delete(&model.RecoveryCode{})
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "two factor authentication is disabled")
+	}
+}
+
+func RegenerateRecoveryCodes() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.TwoFactorCode
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok || !requireSession(ctx) {
+			return
+		}
+
+		if !user.TOTPEnabled {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "two factor authentication is not enabled")
+			return
+		}
+
+		if err := verifySecondFactor(user, req.Code, ""); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+			return
+		}
+
+		codes, err := replaceRecoveryCodes(user)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to create recovery codes")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, map[string]any{"recovery_codes": codes}, "success regenerate recovery codes")
+	}
+}
+
+// twoFactorChallenge is what a login of a user with two factor authentication
+// gets instead of a session, the session is issued by LoginTwoFactor.
+func twoFactorChallenge(user model.User) (map[string]any, error) {
+	challenge, err := helpers.CreateChallengeToken(user.ID, time.Now().Add(5*time.Minute))
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"two_factor_required": true,
+		"challenge_token":     challenge,
+	}, nil
+}
+
+func LoginTwoFactor() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.TwoFactorLogin
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		claims, err := helpers.ParseAndValidateToken(req.ChallengeToken)
+		if err != nil || claims["typ"] != "2fa_challenge" {
+			helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, "challenge is invalid or expired, login again")
+			return
+		}
+
+		var user model.User
+		if err := database.DB.First(&user, "id = ?", claims["sub"]).Error; err != nil || !user.TOTPEnabled || user.DisabledAt != nil {
+			helpers.ResponseJson(ctx, http.StatusUnauthorized, false, nil, "challenge is invalid or expired, login again")
+			return
+		}
+
+		if !checkLoginLock(ctx, user.Email) {
+			return
+		}
+
+		if err := verifySecondFactor(user, req.Code, req.RecoveryCode); err != nil {
+			recordLoginFailure(ctx, user.Email, &user.ID)
+			helpers.ResponseJson(ctx, http.StatusNotAcceptable, false, nil, err.Error())
+			return
+		}
+
+		clearLoginFailures(user.Email)
+
+		data, err := issueSession(ctx, user)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "Gagal membuat token jwt : "+err.Error())
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, data, "Sukses login!")
+	}
+}
@@ -1,9 +1,7 @@
 package handlers
 
 import (
-	"kerjainaja/database"
 	"kerjainaja/helpers"
-	"kerjainaja/model"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -11,27 +9,8 @@ import (
 
 func GetUsers() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		token := ctx.Request.Header.Get("Authorization")
-
-		if token == "" {
-			helpers.ResponseJson(ctx, http.StatusNotAcceptable, false, nil, "token is empty")
-			return
-		}
-
-		token = token[len("Bearer "):]
-
-		claims, err := helpers.ParseAndValidateToken(token)
-		if err != nil {
-			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, err.Error())
-			return
-		}
-
-		userid := claims["sub"]
-		username := claims["username"]
-
-		var user model.User
-		if err := database.DB.Where("id = ? AND username = ?", userid, username).First(&user).Error; err != nil {
-			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "user tidak di temukan")
+		user, ok := currentUser(ctx)
+		if !ok {
 			return
 		}
 
@@ -41,10 +20,11 @@ func GetUsers() gin.HandlerFunc {
 
 func Logout() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		token, err := ctx.Cookie("kerjainaja_session")
-		if err != nil {
-			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "no token was found")
-			return
+		token := ""
+		if tokenHeader := ctx.Request.Header.Get("Authorization"); len(tokenHeader) > len("Bearer ") {
+			token = tokenHeader[len("Bearer "):]
+		} else if cookie, err := ctx.Cookie("kerjainaja_session"); err == nil {
+			token = cookie
 		}
 
 		if token == "" {
@@ -52,7 +32,14 @@ func Logout() gin.HandlerFunc {
 			return
 		}
 
-		ctx.SetCookie("kerjainaja_session", "", -1, "/", "localhost", false, true)
+		if session, err := validateSessionToken(token); err == nil {
+			if err := revokeSessions("id = ?", session.ID); err != nil {
+				helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to logout")
+				return
+			}
+		}
+
+		clearSessionCookie(ctx)
 
 		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success logout")
 	}
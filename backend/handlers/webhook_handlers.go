@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"kerjainaja/database"
+	"kerjainaja/helpers"
+	"kerjainaja/model"
+	"kerjainaja/webhooks"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// adminBoard loads the board named by the :id parameter for one of its
+// admins.
+func adminBoard(ctx *gin.Context, user model.User) (model.Board, bool) {
+	var board model.Board
+
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board id is not valid")
+		return board, false
+	}
+
+	if err := database.DB.First(&board, "id = ?", id).Error; err != nil {
+		helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "board is not found")
+		return board, false
+	}
+
+	if !isBoardAdmin(board, user.ID) {
+		helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "only board admins can change board settings")
+		return board, false
+	}
+
+	return board, true
+}
+
+// findWebhook loads the webhook named by the :id parameter for an admin of
+// its board.
+func findWebhook(ctx *gin.Context, user model.User) (model.Webhook, bool) {
+	var hook model.Webhook
+
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "webhook id is not valid")
+		return hook, false
+	}
+
+	if err := database.DB.First(&hook, "id = ?", id).Error; err != nil {
+		helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "webhook is not found")
+		return hook, false
+	}
+
+	var board model.Board
+	if err := database.DB.First(&board, "id = ?", hook.BoardID).Error; err != nil || !isBoardAdmin(board, user.ID) {
+		helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "webhook is not found")
+		return hook, false
+	}
+
+	return hook, true
+}
+
+func webhookEvents(events []string) string {
+	unique := []string{}
+	for _, event := range events {
+		if !slices.Contains(unique, event) {
+			unique = append(unique, event)
+		}
+	}
+
+	return strings.Join(unique, ",")
+}
+
+func GetWebhooks() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		board, ok := adminBoard(ctx, user)
+		if !ok {
+			return
+		}
+
+		var hooks []model.Webhook
+		if err := database.DB.Where("board_id = ?", board.ID).Order("created_at").Find(&hooks).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get webhooks")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, hooks, "success get webhooks")
+	}
+}
+
+func CreateWebhook() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.NewWebhook
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		board, ok := adminBoard(ctx, user)
+		if !ok {
+			return
+		}
+
+		if err := webhooks.ValidateURL(req.URL); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+			return
+		}
+
+		secret := req.Secret
+		if secret == "" {
+			var err error
+			if secret, _, err = helpers.GenerateSecret("whsec_"); err != nil {
+				helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to generate secret")
+				return
+			}
+		}
+
+		hook := model.Webhook{
+			BoardID:     board.ID,
+			URL:         req.URL,
+			Secret:      secret,
+			Events:      webhookEvents(req.Events),
+			Active:      true,
+			CreatedByID: user.ID,
+		}
+
+		if err := database.DB.Create(&hook).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to create webhook")
+			return
+		}
+
+		// urls often carry a token, so board members only see the filter
+		recordActivity(board.ID, user, "webhook", hook.ID, "created", nil, map[string]any{"events": hook.Events})
+
+		data := map[string]any{
+			"secret":  secret,
+			"webhook": hook,
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, data, "success create webhook, copy the secret now because it will not be shown again")
+	}
+}
+
+func UpdateWebhook() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.UpdateWebhook
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		hook, ok := findWebhook(ctx, user)
+		if !ok {
+			return
+		}
+
+		before := map[string]any{"events": hook.Events, "active": hook.Active}
+
+		if req.URL != nil {
+			if err := webhooks.ValidateURL(*req.URL); err != nil {
+				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, err.Error())
+				return
+			}
+			hook.URL = *req.URL
+		}
+		if req.Events != nil {
+			hook.Events = webhookEvents(*req.Events)
+		}
+		if req.Active != nil {
+			hook.Active = *req.Active
+		}
+
+		if err := database.DB.Model(&hook).Select("url", "events", "active").Updates(&hook).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to update webhook")
+			return
+		}
+
+		recordActivity(hook.BoardID, user, "webhook", hook.ID, "updated", before, map[string]any{"events": hook.Events, "active": hook.Active})
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, hook, "success update webhook")
+	}
+}
+
+func DeleteWebhook() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		hook, ok := findWebhook(ctx, user)
+		if !ok {
+			return
+		}
+
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("webhook_id = ?", hook.ID).Delete(&model.WebhookDelivery{}).Error; err != nil {
+				return err
+			}
+
+			return tx.Delete(&hook).Error
+		})
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to delete webhook")
+			return
+		}
+
+		recordActivity(hook.BoardID, user, "webhook", hook.ID, "deleted", map[string]any{"events": hook.Events}, nil)
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success delete webhook")
+	}
+}
+
+func GetWebhookDeliveries() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		hook, ok := findWebhook(ctx, user)
+		if !ok {
+			return
+		}
+
+		page, limit := helpers.Pagination(ctx)
+
+		query := database.DB.Model(&model.WebhookDelivery{}).Where("webhook_id = ?", hook.ID)
+		if status := ctx.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		query = query.Session(&gorm.Session{})
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get deliveries")
+			return
+		}
+
+		deliveries := []model.WebhookDelivery{}
+		if err := query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&deliveries).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get deliveries")
+			return
+		}
+
+		data := helpers.Page{Items: deliveries, Page: page, Limit: limit, Total: total}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, data, "success get deliveries")
+	}
+}
+
+// RedeliverWebhook sends the payload of an earlier delivery again and
+// answers with the outcome of that attempt.
+func RedeliverWebhook() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		hook, ok := findWebhook(ctx, user)
+		if !ok {
+			return
+		}
+
+		deliveryID, err := uuid.Parse(ctx.Param("deliveryId"))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "delivery id is not valid")
+			return
+		}
+
+		var previous model.WebhookDelivery
+		if err := database.DB.First(&previous, "id = ? AND webhook_id = ?", deliveryID, hook.ID).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "delivery is not found")
+			return
+		}
+
+		if webhooks.Default == nil {
+			helpers.ResponseJson(ctx, http.StatusServiceUnavailable, false, nil, "webhooks are not enabled")
+			return
+		}
+
+		delivery, err := webhooks.Default.Redeliver(previous)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to redeliver")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, delivery, "success redeliver")
+	}
+}
+
+// PingWebhook sends a ping event so a receiver can be tried out.
+func PingWebhook() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		hook, ok := findWebhook(ctx, user)
+		if !ok {
+			return
+		}
+
+		if webhooks.Default == nil {
+			helpers.ResponseJson(ctx, http.StatusServiceUnavailable, false, nil, "webhooks are not enabled")
+			return
+		}
+
+		delivery, err := webhooks.Default.Ping(hook)
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to ping webhook")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, delivery, "success ping webhook")
+	}
+}
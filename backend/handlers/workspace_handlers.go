@@ -0,0 +1,567 @@
+package handlers
+
+import (
+	"kerjainaja/database"
+	"kerjainaja/helpers"
+	"kerjainaja/model"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func workspaceMembership(workspaceID uuid.UUID, userID uuid.UUID) (model.WorkspaceMember, bool) {
+	var member model.WorkspaceMember
+	err := database.DB.First(&member, "workspace_id = ? AND user_id = ?", workspaceID, userID).Error
+	return member, err == nil
+}
+
+// findWorkspace loads the workspace named by the :id parameter along with the
+// caller's membership. With manage set only owners and admins get through.
+func findWorkspace(ctx *gin.Context, user model.User, manage bool) (model.Workspace, model.WorkspaceMember, bool) {
+	var workspace model.Workspace
+	var member model.WorkspaceMember
+
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "workspace id is not valid")
+		return workspace, member, false
+	}
+
+	if err := database.DB.First(&workspace, "id = ?", id).Error; err != nil {
+		helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "workspace is not found")
+		return workspace, member, false
+	}
+
+	member, ok := workspaceMembership(workspace.ID, user.ID)
+	if !ok {
+		helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this workspace")
+		return workspace, member, false
+	}
+
+	if manage && !member.CanManage() {
+		helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "only workspace admins can do this")
+		return workspace, member, false
+	}
+
+	return workspace, member, true
+}
+
+func GetWorkspaces() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		workspaces := []model.Workspace{}
+		if err := database.DB.
+			Where("id IN (SELECT workspace_id FROM workspace_members WHERE user_id = ?)", user.ID).
+			Order("name").
+			Find(&workspaces).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get workspaces")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, workspaces, "success get workspaces")
+	}
+}
+
+func CreateWorkspace() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.NewWorkspace
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		workspace := model.Workspace{
+			Name:              strings.TrimSpace(req.Name),
+			OwnerID:           user.ID,
+			DefaultVisibility: model.VisibilityWorkspace,
+			MembersCanCreate:  true,
+		}
+
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&workspace).Error; err != nil {
+				return err
+			}
+
+			return tx.Create(&model.WorkspaceMember{
+				WorkspaceID: workspace.ID,
+				UserID:      user.ID,
+				Role:        model.WorkspaceRoleOwner,
+			}).Error
+		})
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to create workspace")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, workspace, "success create workspace")
+	}
+}
+
+func GetWorkspace() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		workspace, _, ok := findWorkspace(ctx, user, false)
+		if !ok {
+			return
+		}
+
+		var members []model.WorkspaceMember
+		if err := database.DB.Preload("User").
+			Where("workspace_id = ?", workspace.ID).
+			Order("created_at").
+			Find(&members).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get members")
+			return
+		}
+
+		// members see each other as in the people directory
+		public := make([]model.PublicWorkspaceMember, 0, len(members))
+		for _, member := range members {
+			public = append(public, model.PublicWorkspaceMember{
+				WorkspaceID: member.WorkspaceID,
+				UserID:      member.UserID,
+				Role:        member.Role,
+				User: model.PublicUser{
+					ID:        member.User.ID,
+					Name:      member.User.Name,
+					Username:  member.User.Username,
+					AvatarURL: member.User.AvatarURL,
+				},
+				CreatedAt: member.CreatedAt,
+			})
+		}
+
+		data := struct {
+			model.Workspace
+			Members []model.PublicWorkspaceMember `json:"members"`
+		}{workspace, public}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, data, "success get workspace")
+	}
+}
+
+func UpdateWorkspace() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.UpdateWorkspace
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		workspace, _, ok := findWorkspace(ctx, user, true)
+		if !ok {
+			return
+		}
+
+		if req.Name != nil {
+			if strings.TrimSpace(*req.Name) == "" {
+				helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "name can not be empty")
+				return
+			}
+			workspace.Name = strings.TrimSpace(*req.Name)
+		}
+
+		if req.DefaultVisibility != nil {
+			workspace.DefaultVisibility = *req.DefaultVisibility
+		}
+
+		if req.MembersCanCreate != nil {
+			workspace.MembersCanCreate = *req.MembersCanCreate
+		}
+
+		if err := database.DB.Select("Name", "DefaultVisibility", "MembersCanCreate").Updates(&workspace).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to update workspace")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, workspace, "success update workspace")
+	}
+}
+
+// DeleteWorkspace removes the workspace but keeps its boards, they go back to
+// being standalone private boards of their members.
+func DeleteWorkspace() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		workspace, member, ok := findWorkspace(ctx, user, true)
+		if !ok {
+			return
+		}
+
+		if member.Role != model.WorkspaceRoleOwner {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "only the workspace owner can delete it")
+			return
+		}
+
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&model.Board{}).
+				Where("workspace_id = ?", workspace.ID).
+				Updates(map[string]any{"workspace_id": nil, "visibility": model.VisibilityPrivate}).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Where("workspace_id = ?", workspace.ID).Delete(&model.WorkspaceMember{}).Error; err != nil {
+				return err
+			}
+
+			return tx.Delete(&workspace).Error
+		})
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to delete workspace")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success delete workspace")
+	}
+}
+
+func AddWorkspaceMember() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.AddWorkspaceMember
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		workspace, _, ok := findWorkspace(ctx, user, true)
+		if !ok {
+			return
+		}
+
+		var invited model.User
+		if err := database.DB.First(&invited, "id = ? AND disabled_at IS NULL", req.UserID).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "user is not found")
+			return
+		}
+
+		if _, exists := workspaceMembership(workspace.ID, invited.ID); exists {
+			helpers.ResponseJson(ctx, http.StatusConflict, false, nil, "user is already a member")
+			return
+		}
+
+		role := req.Role
+		if role == "" {
+			role = model.WorkspaceRoleMember
+		}
+
+		member := model.WorkspaceMember{
+			WorkspaceID: workspace.ID,
+			UserID:      invited.ID,
+			Role:        role,
+		}
+
+		if err := database.DB.Create(&member).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to add member")
+			return
+		}
+
+		member.User = invited
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, member, "success add member")
+	}
+}
+
+func UpdateWorkspaceMember() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.WorkspaceMemberRole
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		workspace, _, ok := findWorkspace(ctx, user, true)
+		if !ok {
+			return
+		}
+
+		userID, err := uuid.Parse(ctx.Param("userId"))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "user id is not valid")
+			return
+		}
+
+		member, exists := workspaceMembership(workspace.ID, userID)
+		if !exists {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "member is not found")
+			return
+		}
+
+		if member.Role == model.WorkspaceRoleOwner {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "the owner role can not be changed")
+			return
+		}
+
+		if err := database.DB.Model(&member).Update("role", req.Role).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to update member")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, member, "success update member")
+	}
+}
+
+// RemoveWorkspaceMember takes someone out of the workspace, admins can remove
+// anyone but the owner and everyone can remove themselves. Board memberships
+// are kept, boards have their own member list.
+func RemoveWorkspaceMember() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		workspace, caller, ok := findWorkspace(ctx, user, false)
+		if !ok {
+			return
+		}
+
+		userID, err := uuid.Parse(ctx.Param("userId"))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "user id is not valid")
+			return
+		}
+
+		if userID != user.ID && !caller.CanManage() {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "only workspace admins can do this")
+			return
+		}
+
+		member, exists := workspaceMembership(workspace.ID, userID)
+		if !exists {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "member is not found")
+			return
+		}
+
+		if member.Role == model.WorkspaceRoleOwner {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "the owner can not leave the workspace")
+			return
+		}
+
+		if err := database.DB.Delete(&member).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to remove member")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, nil, "success remove member")
+	}
+}
+
+// TransferWorkspace hands the workspace to another member, the previous owner
+// stays on as admin.
+func TransferWorkspace() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.TransferBoard
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		workspace, caller, ok := findWorkspace(ctx, user, true)
+		if !ok {
+			return
+		}
+
+		if caller.Role != model.WorkspaceRoleOwner {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "only the workspace owner can transfer it")
+			return
+		}
+
+		newOwnerID, _ := uuid.Parse(req.UserID)
+		member, exists := workspaceMembership(workspace.ID, newOwnerID)
+		if !exists {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "user is not a member of this workspace")
+			return
+		}
+
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&caller).Update("role", model.WorkspaceRoleAdmin).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Model(&member).Update("role", model.WorkspaceRoleOwner).Error; err != nil {
+				return err
+			}
+
+			return tx.Model(&workspace).Update("owner_id", member.UserID).Error
+		})
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to transfer workspace")
+			return
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, workspace, "success transfer workspace")
+	}
+}
+
+// GetWorkspaceBoards lists the boards a workspace member can discover, the
+// ones shared with the workspace and the private ones they are already on.
+// Opening a board joins it.
+func GetWorkspaceBoards() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		workspace, _, ok := findWorkspace(ctx, user, false)
+		if !ok {
+			return
+		}
+
+		boards := []model.Board{}
+		if err := database.DB.
+			Where("workspace_id = ?", workspace.ID).
+			Where("visibility = ? OR id IN (SELECT board_id FROM board_members WHERE user_id = ?)", model.VisibilityWorkspace, user.ID).
+			Order("name").
+			Find(&boards).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get boards")
+			return
+		}
+
+		var joined []uuid.UUID
+		if err := database.DB.Table("board_members").
+			Where("user_id = ?", user.ID).
+			Pluck("board_id", &joined).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to get boards")
+			return
+		}
+
+		data := make([]map[string]any, 0, len(boards))
+		for _, board := range boards {
+			data = append(data, map[string]any{
+				"id":         board.ID,
+				"name":       board.Name,
+				"owner_id":   board.OwnerID,
+				"visibility": board.Visibility,
+				"joined":     slices.Contains(joined, board.ID),
+			})
+		}
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, data, "success get boards")
+	}
+}
+
+// MoveBoard puts a board the caller owns into one of their workspaces, or
+// takes it out again.
+func MoveBoard() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req model.MoveBoard
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "bad request")
+			return
+		}
+
+		user, ok := currentUser(ctx)
+		if !ok {
+			return
+		}
+
+		boardID, err := uuid.Parse(ctx.Param("id"))
+		if err != nil {
+			helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "board id is not valid")
+			return
+		}
+
+		var board model.Board
+		if err := database.DB.First(&board, "id = ?", boardID).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusNotFound, false, nil, "board is not found")
+			return
+		}
+
+		if board.OwnerID == nil || *board.OwnerID != user.ID {
+			helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "only the board owner can move it")
+			return
+		}
+
+		before := map[string]any{"workspace_id": board.WorkspaceID, "visibility": board.Visibility}
+
+		board.WorkspaceID = nil
+		board.Visibility = model.VisibilityPrivate
+
+		if req.WorkspaceID != "" {
+			workspace, ok := boardWorkspace(ctx, user, req.WorkspaceID)
+			if !ok {
+				return
+			}
+
+			board.WorkspaceID = &workspace.ID
+			board.Visibility = workspace.DefaultVisibility
+			if req.Visibility != "" {
+				board.Visibility = req.Visibility
+			}
+		}
+
+		if err := database.DB.Model(&board).Select("WorkspaceID", "Visibility").Updates(&board).Error; err != nil {
+			helpers.ResponseJson(ctx, http.StatusInternalServerError, false, nil, "failed to move board")
+			return
+		}
+
+		recordActivity(board.ID, user, "board", board.ID, "moved", before, map[string]any{"workspace_id": board.WorkspaceID, "visibility": board.Visibility})
+
+		helpers.ResponseJson(ctx, http.StatusOK, true, board, "success move board")
+	}
+}
+
+// boardWorkspace checks the caller may add boards to a workspace, when the
+// workspace settings keep board creation to admins they need to be one.
+func boardWorkspace(ctx *gin.Context, user model.User, workspaceID string) (model.Workspace, bool) {
+	var workspace model.Workspace
+	if err := database.DB.First(&workspace, "id = ?", workspaceID).Error; err != nil {
+		helpers.ResponseJson(ctx, http.StatusBadRequest, false, nil, "workspace is not found")
+		return workspace, false
+	}
+
+	member, ok := workspaceMembership(workspace.ID, user.ID)
+	if !ok {
+		helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "you are not a member of this workspace")
+		return workspace, false
+	}
+
+	if !workspace.MembersCanCreate && !member.CanManage() {
+		helpers.ResponseJson(ctx, http.StatusForbidden, false, nil, "only workspace admins can add boards")
+		return workspace, false
+	}
+
+	return workspace, true
+}
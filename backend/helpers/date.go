@@ -0,0 +1,24 @@
+package helpers
+
+import (
+	"fmt"
+	"time"
+)
+
+// ParseDate accepts either an RFC 3339 timestamp or a plain date, which is
+// read as midnight local time. An empty string means no date.
+func ParseDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return &t, nil
+	}
+
+	return nil, fmt.Errorf("date %q is not valid, use 2006-01-02 or RFC 3339", value)
+}
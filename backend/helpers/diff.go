@@ -0,0 +1,59 @@
+package helpers
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+type Change struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// Diff compares the JSON form of two values and returns the fields that
+// differ. Either side may be nil, which is how creations and deletions are
+// recorded.
+func Diff(before any, after any) (map[string]Change, error) {
+	beforeMap, err := toMap(before)
+	if err != nil {
+		return nil, err
+	}
+
+	afterMap, err := toMap(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]Change)
+	for key, value := range beforeMap {
+		if !reflect.DeepEqual(value, afterMap[key]) {
+			changes[key] = Change{Before: value, After: afterMap[key]}
+		}
+	}
+
+	for key, value := range afterMap {
+		if _, ok := beforeMap[key]; !ok {
+			changes[key] = Change{Before: nil, After: value}
+		}
+	}
+
+	return changes, nil
+}
+
+func toMap(value any) (map[string]any, error) {
+	result := make(map[string]any)
+	if value == nil {
+		return result, nil
+	}
+
+	jsonBytes, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(jsonBytes, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
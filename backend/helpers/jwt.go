@@ -10,15 +10,32 @@ import (
 	"github.com/google/uuid"
 )
 
+// jwtSecret refuses to sign or verify anything with an empty key.
+func jwtSecret() ([]byte, error) {
+	secret := config.Env("JWT_SECRET")
+	if secret == "" {
+		return nil, fmt.Errorf("JWT_SECRET is not set")
+	}
+
+	return []byte(secret), nil
+}
+
 func createToken(claim jwt.MapClaims) (string, error) {
+	secret, err := jwtSecret()
+	if err != nil {
+		return "", err
+	}
+
 	claims := jwt.NewWithClaims(jwt.SigningMethodHS256, claim)
-	tokenString, err := claims.SignedString([]byte(config.Env("JWT_SECRET")))
+	tokenString, err := claims.SignedString(secret)
 	return tokenString, err
 }
 
-func CreateTokenSession(id uuid.UUID, username string, role string, expired time.Time) (string, error) {
+func CreateTokenSession(id uuid.UUID, sessionID uuid.UUID, username string, role string, expired time.Time) (string, error) {
 	claims := jwt.MapClaims{
 		"sub":      id,
+		"sid":      sessionID,
+		"typ":      "access",
 		"username": username,
 		"role":     role,
 		"exp":      expired.Unix(),
@@ -27,10 +44,22 @@ func CreateTokenSession(id uuid.UUID, username string, role string, expired time
 	return createToken(claims)
 }
 
+// CreateChallengeToken proves the password step of a two-factor login. It is
+// not an access token and is rejected everywhere else.
+func CreateChallengeToken(id uuid.UUID, expired time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"sub": id,
+		"typ": "2fa_challenge",
+		"exp": expired.Unix(),
+	}
+
+	return createToken(claims)
+}
+
 func ParseAndValidateToken(tokenString string) (jwt.MapClaims, error) {
 	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
-		return []byte(config.Env("JWT_SECRET")), nil
-	})
+		return jwtSecret()
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -50,3 +79,32 @@ func ParseAndValidateToken(tokenString string) (jwt.MapClaims, error) {
 
 	return nil, fmt.Errorf("token is invalid")
 }
+
+// CreateSSOStateToken keeps the state of an SSO login between the redirect to
+// the identity provider and the callback. It lives in a cookie only. link is
+// the user the identity is linked to, empty for a login.
+func CreateSSOStateToken(provider string, state string, nonce string, verifier string, link string, expired time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"typ":      "sso_state",
+		"provider": provider,
+		"state":    state,
+		"nonce":    nonce,
+		"verifier": verifier,
+		"link":     link,
+		"exp":      expired.Unix(),
+	}
+
+	return createToken(claims)
+}
+
+// CreateSSOLinkToken lets a logged in user start an SSO login that links the
+// identity to their account instead of logging in.
+func CreateSSOLinkToken(id uuid.UUID, expired time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"sub": id,
+		"typ": "sso_link",
+		"exp": expired.Unix(),
+	}
+
+	return createToken(claims)
+}
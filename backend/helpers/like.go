@@ -0,0 +1,11 @@
+package helpers
+
+import "strings"
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// EscapeLike escapes the wildcards of a LIKE pattern so user input only
+// matches literally.
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -0,0 +1,34 @@
+package helpers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Page struct {
+	Items any   `json:"items"`
+	Page  int   `json:"page"`
+	Limit int   `json:"limit"`
+	Total int64 `json:"total"`
+}
+
+// Pagination reads the page and limit query parameters, falling back to the
+// first page of 20 items and capping the limit at 100.
+func Pagination(ctx *gin.Context) (page int, limit int) {
+	page, err := strconv.Atoi(ctx.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err = strconv.Atoi(ctx.Query("limit"))
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+
+	if limit > 100 {
+		limit = 100
+	}
+
+	return page, limit
+}
@@ -0,0 +1,27 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// GenerateSecret returns a random URL safe token starting with prefix and
+// the hash to store in its place. The plain token is only ever shown once.
+func GenerateSecret(prefix string) (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+
+	secret := prefix + base64.RawURLEncoding.EncodeToString(buf)
+	return secret, HashSecret(secret), nil
+}
+
+// HashSecret hashes a high entropy token for storage and lookup. Tokens are
+// random so a plain SHA-256 is enough, unlike passwords.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,115 @@
+// Package ical writes iCalendar (RFC 5545) feeds that calendar apps can
+// subscribe to.
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Item is a single entry of a feed. UID has to stay the same across edits so
+// subscribers update the entry instead of adding a new one.
+type Item struct {
+	UID         string
+	Summary     string
+	Description string
+	URL         string
+	Categories  []string
+	At          time.Time
+	Modified    time.Time
+	Created     time.Time
+}
+
+// Calendar is a feed of items written either as events or as to-dos.
+type Calendar struct {
+	Name  string
+	Todo  bool
+	Items []Item
+}
+
+const stampFormat = "20060102T150405Z"
+
+// Write writes the calendar, with every time in UTC.
+func (c Calendar) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		bw.WriteString(fold(name + ":" + value))
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//kerjainaja//calendar feed//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	line("X-WR-CALNAME", escape(c.Name))
+
+	component := "VEVENT"
+	if c.Todo {
+		component = "VTODO"
+	}
+
+	for _, item := range c.Items {
+		line("BEGIN", component)
+		line("UID", escape(item.UID))
+		line("DTSTAMP", item.Modified.UTC().Format(stampFormat))
+		line("CREATED", item.Created.UTC().Format(stampFormat))
+		line("LAST-MODIFIED", item.Modified.UTC().Format(stampFormat))
+		line("SUMMARY", escape(item.Summary))
+		if c.Todo {
+			line("DUE", item.At.UTC().Format(stampFormat))
+		} else {
+			line("DTSTART", item.At.UTC().Format(stampFormat))
+			line("DTEND", item.At.UTC().Format(stampFormat))
+		}
+		if item.Description != "" {
+			line("DESCRIPTION", escape(item.Description))
+		}
+		if item.URL != "" {
+			line("URL", item.URL)
+		}
+		if len(item.Categories) > 0 {
+			categories := make([]string, 0, len(item.Categories))
+			for _, c := range item.Categories {
+				categories = append(categories, escape(c))
+			}
+			line("CATEGORIES", strings.Join(categories, ","))
+		}
+		line("END", component)
+	}
+
+	line("END", "VCALENDAR")
+
+	return bw.Flush()
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// escape escapes a TEXT value.
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// fold ends a content line with CRLF, breaking it into lines of at most 75
+// octets without splitting a UTF-8 sequence.
+func fold(s string) string {
+	var b strings.Builder
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		// the leading space of a continuation line counts too
+		limit = 74
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+
+	return b.String()
+}
@@ -0,0 +1,69 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteEvents(t *testing.T) {
+	at := time.Date(2025, 6, 1, 17, 0, 0, 0, time.FixedZone("WIB", 7*3600))
+	cal := Calendar{Name: "alice", Items: []Item{{
+		UID:         "1@kerjainaja",
+		Summary:     "ship it; now, please",
+		Description: "line one\nline two",
+		Categories:  []string{"bug", "a,b"},
+		At:          at,
+		Created:     at,
+		Modified:    at,
+	}}}
+
+	var buf bytes.Buffer
+	if err := cal.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"BEGIN:VEVENT\r\n",
+		"DTSTART:20250601T100000Z\r\n",
+		`SUMMARY:ship it\; now\, please` + "\r\n",
+		`DESCRIPTION:line one\nline two` + "\r\n",
+		`CATEGORIES:bug,a\,b` + "\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("calendar is missing %q:\n%s", want, out)
+		}
+	}
+
+	cal.Todo = true
+	buf.Reset()
+	if err := cal.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), "BEGIN:VTODO\r\nUID") || !strings.Contains(buf.String(), "DUE:20250601T100000Z\r\n") {
+		t.Errorf("todo calendar:\n%s", buf.String())
+	}
+}
+
+func TestFoldKeepsRunesWhole(t *testing.T) {
+	line := "SUMMARY:" + strings.Repeat("é", 60)
+	folded := fold(line)
+
+	for _, part := range strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n") {
+		if len(part) > 75 {
+			t.Errorf("line %q is %d octets", part, len(part))
+		}
+		if !strings.HasPrefix(part, "SUMMARY") && !strings.HasPrefix(part, " ") {
+			t.Errorf("continuation %q doesn't start with a space", part)
+		}
+	}
+
+	if strings.ReplaceAll(folded, "\r\n ", "") != line+"\r\n" {
+		t.Errorf("unfolded line differs: %q", folded)
+	}
+}
@@ -0,0 +1,74 @@
+package mailer
+
+import (
+	"fmt"
+	"kerjainaja/config"
+	"log"
+	"net/mail"
+	"net/smtp"
+	"strings"
+)
+
+type Mailer interface {
+	Send(to string, subject string, body string) error
+}
+
+// Default is the mailer used by the handlers. It logs messages until Init
+// finds an SMTP server in the environment.
+var Default Mailer = Log{}
+
+func Init() {
+	host := config.Env("SMTP_HOST")
+	if host == "" {
+		log.Println("mailer: SMTP_HOST is empty, emails will only be logged")
+		return
+	}
+
+	Default = SMTP{
+		Host:     host,
+		Port:     config.Env("SMTP_PORT"),
+		Username: config.Env("SMTP_USERNAME"),
+		Password: config.Env("SMTP_PASSWORD"),
+		From:     config.Env("SMTP_FROM"),
+	}
+}
+
+type SMTP struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m SMTP) Send(to string, subject string, body string) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	headers := []string{
+		"From: " + m.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+	}
+
+	// the envelope sender must be a bare address while the header may carry a name
+	from := m.From
+	if address, err := mail.ParseAddress(m.From); err == nil {
+		from = address.Address
+	}
+
+	message := strings.Join(headers, "\r\n") + "\r\n\r\n" + body
+	return smtp.SendMail(fmt.Sprintf("%s:%s", m.Host, m.Port), auth, from, []string{to}, []byte(message))
+}
+
+// Log writes emails to the application log, for development without SMTP.
+type Log struct{}
+
+func (Log) Send(to string, subject string, body string) error {
+	log.Printf("mailer: to=%s subject=%q\n%s", to, subject, body)
+	return nil
+}
@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
 	"kerjainaja/config"
 	"kerjainaja/database"
+	"kerjainaja/handlers"
+	"kerjainaja/mailer"
+	"kerjainaja/middleware"
 	"kerjainaja/routes"
+	"kerjainaja/webhooks"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,8 +22,15 @@ func main() {
 	}
 
 	database.InitDB()
+	mailer.Init()
+	webhooks.Init(database.DB)
+	go handlers.RunDueDateAutomations(context.Background(), time.Minute)
 
 	r := gin.Default()
+	if err := middleware.TrustProxies(r); err != nil {
+		panic(err)
+	}
+
 	routes.MapRoutes(r)
 	r.Run(":8080")
 }
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"fmt"
+	"kerjainaja/config"
+	"kerjainaja/helpers"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeyFunc picks what a rate limit is counted by, like the client IP.
+type KeyFunc func(ctx *gin.Context) string
+
+// TrustProxies lets only the proxies in TRUSTED_PROXIES set the client IP
+// through X-Forwarded-For. gin trusts the header from anyone by default, which
+// would let a client pick the IP its limits are counted by.
+func TrustProxies(engine *gin.Engine) error {
+	var proxies []string
+	for _, proxy := range strings.Split(config.Env("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+
+	return engine.SetTrustedProxies(proxies)
+}
+
+func ByIP(ctx *gin.Context) string {
+	return ctx.ClientIP()
+}
+
+// ByParam counts requests by a path parameter, like the token of a webhook.
+func ByParam(name string) KeyFunc {
+	return func(ctx *gin.Context) string {
+		return ctx.Param(name)
+	}
+}
+
+type window struct {
+	start time.Time
+	count int
+}
+
+type limiter struct {
+	mu      sync.Mutex
+	limit   int
+	period  time.Duration
+	windows map[string]*window
+	now     func() time.Time
+}
+
+// RateLimit allows at most limit requests per period for every key and
+// answers 429 with a Retry-After header once the limit is reached. Counters
+// live in memory, so every instance of the server counts on its own.
+func RateLimit(limit int, period time.Duration, key KeyFunc) gin.HandlerFunc {
+	l := &limiter{
+		limit:   limit,
+		period:  period,
+		windows: make(map[string]*window),
+		now:     time.Now,
+	}
+
+	return l.handle(key)
+}
+
+func (l *limiter) handle(key KeyFunc) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		retryAfter, ok := l.allow(key(ctx))
+		if !ok {
+			ctx.Header("Retry-After", fmt.Sprint(int(math.Ceil(retryAfter.Seconds()))))
+			helpers.ResponseJson(ctx, http.StatusTooManyRequests, false, nil, "too many requests, try again later")
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+func (l *limiter) allow(key string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	// drop finished windows now and then so the map doesn't grow forever
+	if len(l.windows) > 10000 {
+		for k, w := range l.windows {
+			if now.Sub(w.start) >= l.period {
+				delete(l.windows, k)
+			}
+		}
+	}
+
+	w, exists := l.windows[key]
+	if !exists || now.Sub(w.start) >= l.period {
+		l.windows[key] = &window{start: now, count: 1}
+		return 0, true
+	}
+
+	if w.count >= l.limit {
+		return w.start.Add(l.period).Sub(now), false
+	}
+
+	w.count++
+	return 0, true
+}
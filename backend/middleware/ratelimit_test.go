@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := &limiter{
+		limit:   2,
+		period:  time.Minute,
+		windows: make(map[string]*window),
+		now:     func() time.Time { return now },
+	}
+
+	r := gin.New()
+	r.POST("/login", l.handle(func(ctx *gin.Context) string { return ctx.GetHeader("X-Client") }), func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	send := func(client string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.Header.Set("X-Client", client)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := range 2 {
+		if code := send("a").Code; code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i+1, code)
+		}
+	}
+
+	blocked := send("a")
+	if blocked.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", blocked.Code)
+	}
+
+	if blocked.Header().Get("Retry-After") != "60" {
+		t.Errorf("expected Retry-After of 60, got %q", blocked.Header().Get("Retry-After"))
+	}
+
+	if code := send("b").Code; code != http.StatusOK {
+		t.Errorf("expected other clients to be unaffected, got %d", code)
+	}
+
+	now = now.Add(time.Minute)
+	if code := send("a").Code; code != http.StatusOK {
+		t.Errorf("expected the limit to reset after the period, got %d", code)
+	}
+}
+
+func TestRateLimitByIPIgnoresForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("TRUSTED_PROXIES", "")
+
+	l := &limiter{
+		limit:   1,
+		period:  time.Minute,
+		windows: make(map[string]*window),
+		now:     time.Now,
+	}
+
+	r := gin.New()
+	if err := TrustProxies(r); err != nil {
+		t.Fatal(err)
+	}
+	r.POST("/login", l.handle(ByIP), func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	send := func(forwardedFor string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = "203.0.113.7:4000"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := send("198.51.100.1"); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+
+	if code := send("198.51.100.2"); code != http.StatusTooManyRequests {
+		t.Errorf("expected a new X-Forwarded-For to still be limited, got %d", code)
+	}
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	AccessTokenPrefix = "kja_"
+
+	// ScopeRead allows GET requests, ScopeWrite allows everything else too.
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// PersonalAccessToken lets scripts call the API without a session. Only the
+// hash of the token is stored, Prefix helps users tell tokens apart.
+type PersonalAccessToken struct {
+	ID         uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID     uuid.UUID  `gorm:"type:char(36);not null;index" json:"user_id"`
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	Prefix     string     `gorm:"size:20;not null" json:"prefix"`
+	TokenHash  string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Scopes     string     `gorm:"size:100;not null" json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (t *PersonalAccessToken) BeforeCreate(tx *gorm.DB) (err error) {
+	t.ID = uuid.New()
+	return
+}
+
+func (t *PersonalAccessToken) HasScope(scope string) bool {
+	scopes := strings.Split(t.Scopes, ",")
+	return slices.Contains(scopes, scope) || (scope == ScopeRead && slices.Contains(scopes, ScopeWrite))
+}
@@ -0,0 +1,7 @@
+package model
+
+type NewAccessToken struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"dive,oneof=read write"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"min=0,max=365"`
+}
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Activity is an append-only record of a change made on a board. The actor
+// username is copied so the entry stays readable after the user is gone.
+type Activity struct {
+	ID            uuid.UUID       `gorm:"type:char(36);primaryKey" json:"id"`
+	BoardID       uuid.UUID       `gorm:"type:char(36);not null;index" json:"board_id"`
+	ActorID       uuid.UUID       `gorm:"type:char(36);not null" json:"actor_id"`
+	ActorUsername string          `gorm:"size:100;not null" json:"actor_username"`
+	EntityType    string          `gorm:"size:20;not null;index:idx_activity_entity" json:"entity_type"`
+	EntityID      uuid.UUID       `gorm:"type:char(36);not null;index:idx_activity_entity" json:"entity_id"`
+	Action        string          `gorm:"size:50;not null" json:"action"`
+	Changes       json.RawMessage `gorm:"type:text" json:"changes"`
+	CreatedAt     time.Time       `gorm:"index" json:"created_at"`
+}
+
+func (a *Activity) BeforeCreate(tx *gorm.DB) (err error) {
+	a.ID = uuid.New()
+	return
+}
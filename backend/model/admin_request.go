@@ -0,0 +1,15 @@
+package model
+
+// AdminResetPassword sets a new password when Password is given, otherwise a
+// reset link is emailed to the user.
+type AdminResetPassword struct {
+	Password string `json:"password" binding:"omitempty,min=8"`
+}
+
+type ChangeRole struct {
+	Role string `json:"role" binding:"required,oneof=user admin"`
+}
+
+type TransferBoard struct {
+	UserID string `json:"user_id" binding:"required,uuid"`
+}
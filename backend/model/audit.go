@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AuditLog records security relevant events that don't belong to a board.
+type AuditLog struct {
+	ID        uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID    *uuid.UUID `gorm:"type:char(36);index" json:"user_id"`
+	Action    string     `gorm:"size:50;not null;index" json:"action"`
+	IP        string     `gorm:"size:45" json:"ip"`
+	Detail    string     `gorm:"size:500" json:"detail"`
+	CreatedAt time.Time  `gorm:"index" json:"created_at"`
+}
+
+func (a *AuditLog) BeforeCreate(tx *gorm.DB) (err error) {
+	a.ID = uuid.New()
+	return
+}
+
+// LoginThrottle counts failed logins for one key, an account email or a
+// client IP, and how long that key is locked out.
+type LoginThrottle struct {
+	Key          string     `gorm:"column:throttle_key;size:150;primaryKey" json:"key"`
+	Failures     int        `gorm:"not null;default:0" json:"failures"`
+	LockedUntil  *time.Time `json:"locked_until"`
+	LastFailedAt time.Time  `json:"last_failed_at"`
+}
@@ -9,5 +9,39 @@ type Register struct {
 	Name     string `json:"name" binding:"required"`
 	Username string `json:"username" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type ForgotPassword struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPassword struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+type VerifyEmail struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type TwoFactorCode struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableTwoFactor struct {
+	Password     string `json:"password" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// TwoFactorLogin finishes a login with either a TOTP code or a recovery code.
+type TwoFactorLogin struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
 }
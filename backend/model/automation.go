@@ -0,0 +1,82 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	AutomationSucceeded = "succeeded"
+	AutomationFailed    = "failed"
+	AutomationSkipped   = "skipped"
+)
+
+// AutomationRule takes its actions on a card when the trigger happens and
+// the card meets the conditions. Actions are taken on behalf of the user
+// who made the rule, so it stops working when they lose access to the
+// board.
+type AutomationRule struct {
+	ID          uuid.UUID            `gorm:"type:char(36);primaryKey" json:"id"`
+	BoardID     uuid.UUID            `gorm:"type:char(36);not null;index" json:"board_id"`
+	Name        string               `gorm:"size:100;not null" json:"name"`
+	Trigger     AutomationTrigger    `gorm:"column:trigger_event;type:text;serializer:json" json:"trigger"`
+	Conditions  AutomationConditions `gorm:"type:text;serializer:json" json:"conditions"`
+	Actions     []AutomationAction   `gorm:"type:text;serializer:json" json:"actions"`
+	Enabled     bool                 `gorm:"not null" json:"enabled"`
+	CreatedByID uuid.UUID            `gorm:"type:char(36);not null" json:"created_by_id"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+}
+
+func (r *AutomationRule) BeforeCreate(tx *gorm.DB) (err error) {
+	r.ID = uuid.New()
+	return
+}
+
+// AutomationTrigger is what starts a rule. ColumnID narrows card_created
+// and card_moved to one column, LabelID narrows label_added to one label.
+type AutomationTrigger struct {
+	Type     string     `json:"type" binding:"required"`
+	ColumnID *uuid.UUID `json:"column_id,omitempty"`
+	LabelID  *uuid.UUID `json:"label_id,omitempty"`
+}
+
+// AutomationConditions must all hold for a rule to run: the card has every
+// label and every member listed.
+type AutomationConditions struct {
+	LabelIDs  []uuid.UUID `json:"label_ids,omitempty"`
+	MemberIDs []uuid.UUID `json:"member_ids,omitempty"`
+}
+
+// AutomationAction is one step of a rule. Which fields are used depends on
+// the type. A set_due_date without DueInDays clears the due date.
+type AutomationAction struct {
+	Type      string     `json:"type" binding:"required"`
+	UserID    *uuid.UUID `json:"user_id,omitempty"`
+	LabelID   *uuid.UUID `json:"label_id,omitempty"`
+	ColumnID  *uuid.UUID `json:"column_id,omitempty"`
+	DueInDays *int       `json:"due_in_days,omitempty"`
+	Comment   string     `json:"comment,omitempty"`
+}
+
+// AutomationRun is the log of a rule running on a card. Depth is how many
+// rules ran before it in the same chain, 0 when a user's change started it.
+type AutomationRun struct {
+	ID        uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
+	RuleID    uuid.UUID `gorm:"type:char(36);not null;index:idx_automation_run_card" json:"rule_id"`
+	BoardID   uuid.UUID `gorm:"type:char(36);not null;index" json:"board_id"`
+	CardID    uuid.UUID `gorm:"type:char(36);not null;index:idx_automation_run_card" json:"card_id"`
+	Event     string    `gorm:"size:50;not null" json:"event"`
+	Depth     int       `gorm:"not null;default:0" json:"depth"`
+	Status    string    `gorm:"size:20;not null" json:"status"`
+	Steps     []string  `gorm:"type:text;serializer:json" json:"steps"`
+	Error     string    `gorm:"size:255" json:"error"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+func (r *AutomationRun) BeforeCreate(tx *gorm.DB) (err error) {
+	r.ID = uuid.New()
+	return
+}
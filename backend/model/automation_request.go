@@ -0,0 +1,9 @@
+package model
+
+type AutomationRuleRequest struct {
+	Name       string               `json:"name" binding:"required,max=100"`
+	Trigger    AutomationTrigger    `json:"trigger" binding:"required"`
+	Conditions AutomationConditions `json:"conditions"`
+	Actions    []AutomationAction   `json:"actions" binding:"required,min=1,max=10,dive"`
+	Enabled    *bool                `json:"enabled"`
+}
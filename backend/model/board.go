@@ -1,7 +1,12 @@
 package model
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -15,45 +20,241 @@ type ResponseBoards struct {
 }
 
 type Board struct {
-	ID        uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
-	Name      string    `gorm:"size:100;not null" json:"name"`
-	Members   []User    `gorm:"many2many:board_members" json:"members"`
-	Columns   []Column  `gorm:"foreignKey:BoardID" json:"columns"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID          uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	Name        string     `gorm:"size:100;not null" json:"name"`
+	OwnerID     *uuid.UUID `gorm:"type:char(36);index" json:"owner_id"`
+	WorkspaceID *uuid.UUID `gorm:"type:char(36);index" json:"workspace_id"`
+	Visibility  string     `gorm:"size:20;not null;default:private" json:"visibility"`
+	KeyPrefix   string     `gorm:"size:10;not null;default:''" json:"key_prefix"`
+	CardCounter int        `gorm:"not null;default:0" json:"-"`
+	Members     []User     `gorm:"many2many:board_members" json:"members"`
+	Columns     []Column   `gorm:"foreignKey:BoardID" json:"columns"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
 }
 
 func (b *Board) BeforeCreate(tx *gorm.DB) (err error) {
 	b.ID = uuid.New()
+	if b.KeyPrefix == "" {
+		b.KeyPrefix = DefaultKeyPrefix(b.Name)
+	}
 	return
 }
 
+// CardKey is the short key of a card on this board, like KA-42.
+func (b Board) CardKey(number int) string {
+	return fmt.Sprintf("%s-%d", b.KeyPrefix, number)
+}
+
+// ParseCardKey reads the number of a card key with the board's prefix. A
+// bare number is accepted too.
+func (b Board) ParseCardKey(key string) (int, bool) {
+	if prefix, number, found := strings.Cut(key, "-"); found {
+		if !strings.EqualFold(prefix, b.KeyPrefix) {
+			return 0, false
+		}
+		key = number
+	}
+
+	number, err := strconv.Atoi(key)
+	if err != nil || number <= 0 || strings.HasPrefix(key, "+") {
+		return 0, false
+	}
+
+	return number, true
+}
+
+// ValidKeyPrefix reports whether a prefix can be used in card keys: 2 to
+// 10 upper-case letters or digits, starting with a letter.
+func ValidKeyPrefix(prefix string) bool {
+	if len(prefix) < 2 || len(prefix) > 10 || prefix[0] < 'A' || prefix[0] > 'Z' {
+		return false
+	}
+
+	for _, r := range prefix {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// DefaultKeyPrefix makes a card key prefix from a board name: the initials
+// of a name with several words, the start of a single word, "KA" when the
+// name has no letters.
+func DefaultKeyPrefix(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var prefix []rune
+	if len(words) > 1 {
+		for _, word := range words {
+			if r := []rune(word)[0]; r < unicode.MaxASCII && unicode.IsLetter(r) {
+				prefix = append(prefix, unicode.ToUpper(r))
+			}
+		}
+	} else if len(words) == 1 {
+		for _, r := range words[0] {
+			if r < unicode.MaxASCII && unicode.IsLetter(r) {
+				prefix = append(prefix, unicode.ToUpper(r))
+			}
+		}
+		prefix = prefix[:min(len(prefix), 3)]
+	}
+
+	if len(prefix) < 2 {
+		return "KA"
+	}
+
+	return string(prefix[:min(len(prefix), 5)])
+}
+
+const (
+	WIPSoft = "soft"
+	WIPHard = "hard"
+
+	MaxWIPLimit = 1000
+)
+
+// Column holds cards. WIPLimit is the most unarchived cards it should hold,
+// 0 means no limit. A hard limit refuses cards past it, a soft one only
+// warns. WIP is filled in when the column is sent to clients.
 type Column struct {
-	ID        uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
-	Name      string    `gorm:"size:100;not null" json:"name"`
-	BoardID   uuid.UUID `gorm:"type:char(36);not null" json:"board_id"`
-	Cards     []Card    `gorm:"foreignKey:ColumnID" json:"cards"`
+	ID        uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	Name      string     `gorm:"size:100;not null" json:"name"`
+	BoardID   uuid.UUID  `gorm:"type:char(36);not null" json:"board_id"`
+	WIPLimit  int        `gorm:"column:wip_limit;not null;default:0" json:"wip_limit"`
+	WIPMode   string     `gorm:"column:wip_mode;size:10;not null;default:soft" json:"wip_mode"`
+	WIP       *WIPStatus `gorm:"-" json:"wip,omitempty"`
+	Cards     []Card     `gorm:"foreignKey:ColumnID" json:"cards"`
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
 
 func (c *Column) BeforeCreate(tx *gorm.DB) (err error) {
 	c.ID = uuid.New()
+	if c.WIPMode == "" {
+		c.WIPMode = WIPSoft
+	}
 	return
 }
 
+// WIPStatus is how full a column with a WIP limit is.
+type WIPStatus struct {
+	Count int    `json:"count"`
+	Limit int    `json:"limit"`
+	Mode  string `json:"mode"`
+	Full  bool   `json:"full"`
+	Over  bool   `json:"over"`
+}
+
+// WIPStatusOf is the status of the column holding count cards, nil when
+// the column has no limit.
+func (c Column) WIPStatusOf(count int) *WIPStatus {
+	if c.WIPLimit <= 0 {
+		return nil
+	}
+
+	return &WIPStatus{
+		Count: count,
+		Limit: c.WIPLimit,
+		Mode:  c.WIPMode,
+		Full:  count >= c.WIPLimit,
+		Over:  count > c.WIPLimit,
+	}
+}
+
+// WIPSettings cleans up a WIP limit and mode read from a template or an
+// imported file, which aren't checked like a request is.
+func WIPSettings(limit int, mode string) (int, string) {
+	limit = max(0, min(limit, MaxWIPLimit))
+	if mode != WIPHard {
+		mode = WIPSoft
+	}
+
+	return limit, mode
+}
+
 type Card struct {
-	ID          uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
-	Title       string    `gorm:"size:255;not null" json:"title"`
-	Description string    `gorm:"type:text" json:"description"`
-	DueDate     string    `json:"due_date"`
-	ColumnID    uuid.UUID `gorm:"type:char(36);not null" json:"column_id"`
-	Members     []User    `gorm:"many2many:card_members" json:"members"`
+	ID          uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	Title       string     `gorm:"size:255;not null;index:idx_cards_search,class:FULLTEXT" json:"title"`
+	Description string     `gorm:"type:text;index:idx_cards_search,class:FULLTEXT" json:"description"`
+	DueDate     *time.Time `gorm:"index" json:"due_date"`
+	Archived    bool       `gorm:"not null;default:false;index" json:"archived"`
+	ColumnID    uuid.UUID  `gorm:"type:char(36);not null" json:"column_id"`
+	Number      int        `gorm:"not null;default:0;index" json:"number"`
+	Key         string     `gorm:"-" json:"key"`
+	Members     []User     `gorm:"many2many:card_members" json:"members"`
+	Watchers    []User     `gorm:"many2many:card_watchers" json:"watchers"`
+	Labels      []Label    `gorm:"many2many:card_labels" json:"labels"`
+	Comments    []Comment  `gorm:"foreignKey:CardID" json:"comments,omitempty"`
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 }
 
 func (c *Card) BeforeCreate(tx *gorm.DB) (err error) {
-	c.ID = uuid.New()
+	// saving a column with its cards loaded runs this hook for them too
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+
+	if c.Number == 0 {
+		err = c.takeNumber(tx)
+	}
+	return
+}
+
+// AfterFind fills in the key of the card. Boards are looked up once per
+// column for every query, not once per card.
+func (c *Card) AfterFind(tx *gorm.DB) (err error) {
+	if c.ColumnID == uuid.Nil || c.Number == 0 {
+		return
+	}
+
+	cache, _ := tx.Statement.Settings.LoadOrStore("kerjainaja:key_prefixes", &sync.Map{})
+	prefixes := cache.(*sync.Map)
+
+	prefix, ok := prefixes.Load(c.ColumnID)
+	if !ok {
+		var board Board
+		err = tx.Session(&gorm.Session{NewDB: true}).
+			Select("boards.key_prefix").
+			Joins("JOIN columns ON columns.board_id = boards.id").
+			Where("columns.id = ?", c.ColumnID).
+			First(&board).Error
+		if err != nil {
+			return
+		}
+		prefix, _ = prefixes.LoadOrStore(c.ColumnID, board.KeyPrefix)
+	}
+
+	c.Key = Board{KeyPrefix: prefix.(string)}.CardKey(c.Number)
 	return
 }
+
+// takeNumber takes the next card number of the board the card's column
+// belongs to. The counter update locks the board row until the transaction
+// creating the card commits, so concurrent cards never get the same number.
+func (c *Card) takeNumber(tx *gorm.DB) error {
+	db := tx.Session(&gorm.Session{NewDB: true})
+
+	var column Column
+	if err := db.Select("board_id").First(&column, "id = ?", c.ColumnID).Error; err != nil {
+		return err
+	}
+
+	if err := db.Model(&Board{}).Where("id = ?", column.BoardID).UpdateColumn("card_counter", gorm.Expr("card_counter + 1")).Error; err != nil {
+		return err
+	}
+
+	var board Board
+	if err := db.Select("key_prefix", "card_counter").First(&board, "id = ?", column.BoardID).Error; err != nil {
+		return err
+	}
+
+	c.Number = board.CardCounter
+	c.Key = board.CardKey(c.Number)
+	return nil
+}
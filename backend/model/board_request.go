@@ -1,10 +1,21 @@
 package model
 
 type CreateBoard struct {
-	Name string `json:"name" binding:"required"`
+	Name        string `json:"name" binding:"required"`
+	WorkspaceID string `json:"workspace_id" binding:"omitempty,uuid"`
+	Visibility  string `json:"visibility" binding:"omitempty,oneof=private workspace"`
+	// KeyPrefix starts the keys of the board's cards, it is made from the
+	// name when empty.
+	KeyPrefix string `json:"key_prefix"`
+	// TemplateID is a built-in template name or the id of a saved template.
+	TemplateID string `json:"template_id"`
 }
 
 type AddBoard struct {
 	Name    string `json:"name"`
 	BoardID string `json:"board_id"`
 }
+
+type UpdateKeyPrefix struct {
+	KeyPrefix string `json:"key_prefix" binding:"required"`
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const CalendarFeedPrefix = "kjc_"
+
+// CalendarFeed is a secret link to an iCalendar feed of card due dates.
+// Without a board it lists the cards the user is assigned to on all their
+// boards. Only the hash of the token is stored.
+type CalendarFeed struct {
+	ID         uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID     uuid.UUID  `gorm:"type:char(36);not null;index" json:"user_id"`
+	BoardID    *uuid.UUID `gorm:"type:char(36);index" json:"board_id"`
+	Prefix     string     `gorm:"size:20;not null" json:"prefix"`
+	TokenHash  string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (f *CalendarFeed) BeforeCreate(tx *gorm.DB) (err error) {
+	f.ID = uuid.New()
+	return
+}
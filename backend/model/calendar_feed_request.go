@@ -0,0 +1,5 @@
+package model
+
+type NewCalendarFeed struct {
+	BoardID string `json:"board_id" binding:"omitempty,uuid"`
+}
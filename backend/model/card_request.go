@@ -1,7 +1,23 @@
 package model
 
 type NewCard struct {
-	Title       string `json:"title" binding:"required"`
-	Description string `json:"description" binding:"required"`
-	ColumnID    string `json:"column_id" binding:"required"`
+	Title       string   `json:"title" binding:"required"`
+	Description string   `json:"description" binding:"required"`
+	ColumnID    string   `json:"column_id" binding:"required"`
+	DueDate     string   `json:"due_date"`
+	MemberIDs   []string `json:"member_ids"`
+}
+
+// UpdateCard only changes the fields that are present in the request. An
+// empty due_date clears the due date.
+type UpdateCard struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	DueDate     *string `json:"due_date"`
+	ColumnID    *string `json:"column_id"`
+	Archived    *bool   `json:"archived"`
+}
+
+type CardMemberRequest struct {
+	UserID string `json:"user_id" binding:"required"`
 }
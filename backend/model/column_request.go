@@ -3,3 +3,10 @@ package model
 type EditColumnRequest struct {
 	Name string `json:"name" binding:"required"`
 }
+
+// UpdateWIPLimit sets the WIP limit of a column, 0 removes it. The mode
+// stays the same when it is left out.
+type UpdateWIPLimit struct {
+	WIPLimit *int   `json:"wip_limit" binding:"required,min=0,max=1000"`
+	WIPMode  string `json:"wip_mode" binding:"omitempty,oneof=soft hard"`
+}
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Comment is a message on a card. Like activity, the author username is
+// copied so comments stay readable after the author's account is deleted.
+type Comment struct {
+	ID             uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	CardID         uuid.UUID  `gorm:"type:char(36);not null;index" json:"card_id"`
+	AuthorID       *uuid.UUID `gorm:"type:char(36);index" json:"author_id"`
+	AuthorUsername string     `gorm:"size:100;not null" json:"author_username"`
+	Body           string     `gorm:"type:text;not null" json:"body"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (c *Comment) BeforeCreate(tx *gorm.DB) (err error) {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+
+	return
+}
@@ -0,0 +1,5 @@
+package model
+
+type NewComment struct {
+	Body string `json:"body" binding:"required"`
+}
@@ -0,0 +1,11 @@
+package model
+
+import "encoding/json"
+
+// ImportBoard carries an exported board document. MemberMap renames users
+// of the document to local usernames when they differ between installations.
+type ImportBoard struct {
+	Document    json.RawMessage   `json:"document" binding:"required"`
+	MemberMap   map[string]string `json:"member_map"`
+	WorkspaceID string            `json:"workspace_id" binding:"omitempty,uuid"`
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SavedFilter is a named card filter expression. Filters without a board can
+// be used on every board of the user.
+type SavedFilter struct {
+	ID        uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:char(36);not null;index" json:"user_id"`
+	BoardID   *uuid.UUID `gorm:"type:char(36)" json:"board_id"`
+	Name      string     `gorm:"size:100;not null" json:"name"`
+	Query     string     `gorm:"size:500;not null" json:"query"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (f *SavedFilter) BeforeCreate(tx *gorm.DB) (err error) {
+	f.ID = uuid.New()
+	return
+}
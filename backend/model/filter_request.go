@@ -0,0 +1,7 @@
+package model
+
+type NewSavedFilter struct {
+	Name    string `json:"name" binding:"required,max=100"`
+	Query   string `json:"query" binding:"required,max=500"`
+	BoardID string `json:"board_id"`
+}
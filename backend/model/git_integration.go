@@ -0,0 +1,55 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const GitIntegrationPrefix = "kjg_"
+
+// GitIntegration is a secret URL a repository posts its pushes to. Commits
+// mentioning a card key are linked to the card, and closing keywords move
+// the card to DoneColumnID when one is set. Changes are made on behalf of
+// the user who made the integration. Only the hash of the token is stored.
+type GitIntegration struct {
+	ID           uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	BoardID      uuid.UUID  `gorm:"type:char(36);not null;index" json:"board_id"`
+	Name         string     `gorm:"size:100;not null" json:"name"`
+	DoneColumnID *uuid.UUID `gorm:"type:char(36)" json:"done_column_id"`
+	Prefix       string     `gorm:"size:20;not null" json:"prefix"`
+	TokenHash    string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	CreatedByID  uuid.UUID  `gorm:"type:char(36);not null" json:"created_by_id"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func (g *GitIntegration) BeforeCreate(tx *gorm.DB) (err error) {
+	g.ID = uuid.New()
+	return
+}
+
+// CardCommit is a commit that mentioned a card. The same commit pushed to
+// another branch is linked once.
+type CardCommit struct {
+	ID            uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	CardID        uuid.UUID  `gorm:"type:char(36);not null;uniqueIndex:idx_card_commit" json:"card_id"`
+	SHA           string     `gorm:"size:64;not null;uniqueIndex:idx_card_commit" json:"sha"`
+	IntegrationID uuid.UUID  `gorm:"type:char(36);not null;index" json:"integration_id"`
+	Repository    string     `gorm:"size:255" json:"repository"`
+	Branch        string     `gorm:"size:255" json:"branch"`
+	Message       string     `gorm:"type:text" json:"message"`
+	URL           string     `gorm:"size:500" json:"url"`
+	AuthorName    string     `gorm:"size:255" json:"author_name"`
+	AuthorEmail   string     `gorm:"size:255" json:"author_email"`
+	Closed        bool       `json:"closed"`
+	CommittedAt   *time.Time `json:"committed_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (c *CardCommit) BeforeCreate(tx *gorm.DB) (err error) {
+	c.ID = uuid.New()
+	return
+}
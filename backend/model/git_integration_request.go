@@ -0,0 +1,6 @@
+package model
+
+type NewGitIntegration struct {
+	Name         string `json:"name" binding:"required,max=100"`
+	DoneColumnID string `json:"done_column_id"`
+}
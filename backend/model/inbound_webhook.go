@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const InboundWebhookPrefix = "kjh_"
+
+// InboundWebhook is a secret URL that turns posted JSON into cards of a
+// column. Cards are created on behalf of the user who made the webhook, so
+// it stops working when they lose access to the board. Only the hash of the
+// token is stored.
+type InboundWebhook struct {
+	ID          uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	BoardID     uuid.UUID  `gorm:"type:char(36);not null;index" json:"board_id"`
+	ColumnID    uuid.UUID  `gorm:"type:char(36);not null;index" json:"column_id"`
+	Name        string     `gorm:"size:100;not null" json:"name"`
+	Prefix      string     `gorm:"size:20;not null" json:"prefix"`
+	TokenHash   string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	CreatedByID uuid.UUID  `gorm:"type:char(36);not null" json:"created_by_id"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (w *InboundWebhook) BeforeCreate(tx *gorm.DB) (err error) {
+	w.ID = uuid.New()
+	return
+}
+
+// InboundCard remembers which card an external ID became, so a sender
+// posting the same alert twice doesn't get two cards.
+type InboundCard struct {
+	WebhookID  uuid.UUID `gorm:"type:char(36);primaryKey"`
+	ExternalID string    `gorm:"size:255;primaryKey"`
+	CardID     uuid.UUID `gorm:"type:char(36);not null;index"`
+	CreatedAt  time.Time
+}
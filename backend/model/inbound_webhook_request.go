@@ -0,0 +1,15 @@
+package model
+
+type NewInboundWebhook struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// InboundCardRequest is what an inbound webhook accepts. Labels are matched
+// by name and created when the board doesn't have them yet.
+type InboundCardRequest struct {
+	Title       string   `json:"title" binding:"required,max=255"`
+	Description string   `json:"description"`
+	DueDate     string   `json:"due_date"`
+	Labels      []string `json:"labels" binding:"max=10,dive,required,max=50"`
+	ExternalID  string   `json:"external_id" binding:"max=255"`
+}
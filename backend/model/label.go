@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type Label struct {
+	ID        uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
+	BoardID   uuid.UUID `gorm:"type:char(36);not null;uniqueIndex:idx_label_board_name" json:"board_id"`
+	Name      string    `gorm:"size:50;not null;uniqueIndex:idx_label_board_name" json:"name"`
+	Color     string    `gorm:"size:20" json:"color"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (l *Label) BeforeCreate(tx *gorm.DB) (err error) {
+	// appending an existing label to a card runs this hook too
+	if l.ID == uuid.Nil {
+		l.ID = uuid.New()
+	}
+
+	return
+}
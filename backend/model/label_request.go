@@ -0,0 +1,10 @@
+package model
+
+type NewLabel struct {
+	Name  string `json:"name" binding:"required,max=50"`
+	Color string `json:"color" binding:"max=20"`
+}
+
+type CardLabelRequest struct {
+	LabelID string `json:"label_id" binding:"required"`
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RecoveryCode is a one-time replacement for a TOTP code, stored bcrypt hashed.
+type RecoveryCode struct {
+	ID        uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:char(36);not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (c *RecoveryCode) BeforeCreate(tx *gorm.DB) (err error) {
+	c.ID = uuid.New()
+	return
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Session is a login on one device. Access tokens carry the session id so a
+// revoked session stops working right away, and the refresh token is rotated
+// on every use. PreviousTokenHash catches a refresh token being replayed.
+type Session struct {
+	ID                uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID            uuid.UUID  `gorm:"type:char(36);not null;index" json:"user_id"`
+	RefreshTokenHash  string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	PreviousTokenHash string     `gorm:"size:64;index" json:"-"`
+	UserAgent         string     `gorm:"size:255" json:"user_agent"`
+	IP                string     `gorm:"size:45" json:"ip"`
+	ExpiresAt         time.Time  `json:"expires_at"`
+	LastUsedAt        time.Time  `json:"last_used_at"`
+	RevokedAt         *time.Time `json:"revoked_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+func (s *Session) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+
+	return
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BoardTemplate is a saved board structure to start new boards from. It is
+// private to its owner unless it is shared with a workspace.
+type BoardTemplate struct {
+	ID          uuid.UUID       `gorm:"type:char(36);primaryKey" json:"id"`
+	Name        string          `gorm:"size:100;not null" json:"name"`
+	Description string          `gorm:"size:255" json:"description"`
+	OwnerID     uuid.UUID       `gorm:"type:char(36);not null;index" json:"owner_id"`
+	WorkspaceID *uuid.UUID      `gorm:"type:char(36);index" json:"workspace_id"`
+	Content     json.RawMessage `gorm:"type:mediumtext" json:"content"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+func (t *BoardTemplate) BeforeCreate(tx *gorm.DB) (err error) {
+	t.ID = uuid.New()
+	return
+}
@@ -0,0 +1,13 @@
+package model
+
+type NewTemplate struct {
+	Name         string `json:"name" binding:"required"`
+	Description  string `json:"description"`
+	IncludeCards bool   `json:"include_cards"`
+	WorkspaceID  string `json:"workspace_id" binding:"omitempty,uuid"`
+}
+
+type DuplicateBoard struct {
+	Name         string `json:"name" binding:"required"`
+	IncludeCards *bool  `json:"include_cards"`
+}
@@ -7,17 +7,31 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
-	ID        uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
-	Name      string    `gorm:"size:100;not null" json:"name"`
-	Username  string    `gorm:"size:100;not null;uniqueIndex" json:"username"`
-	Email     string    `gorm:"size:100;not null;uniqueIndex" json:"email"`
-	Password  string    `gorm:"not null" json:"-"`
-	Role      string    `gorm:"size:20;default:user" json:"role"`
-	Boards    []Board   `gorm:"many2many:board_members" json:"boards,omitempty"`
-	Cards     []Card    `gorm:"many2many:card_members" json:"cards,omitempty"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID              uuid.UUID  `gorm:"type:char(36);primaryKey" json:"id"`
+	Name            string     `gorm:"size:100;not null" json:"name"`
+	Username        string     `gorm:"size:100;not null;uniqueIndex" json:"username"`
+	Email           string     `gorm:"size:100;not null;uniqueIndex" json:"email"`
+	Password        string     `gorm:"not null" json:"-"`
+	Role            string     `gorm:"size:20;default:user" json:"role"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	PendingEmail    string     `gorm:"size:100" json:"pending_email"`
+	TOTPSecret      string     `gorm:"size:64" json:"-"`
+	TOTPEnabled     bool       `gorm:"not null;default:false" json:"totp_enabled"`
+	TOTPLastStep    int64      `json:"-"`
+	DisabledAt      *time.Time `json:"disabled_at"`
+	AvatarURL       string     `gorm:"size:255" json:"avatar_url"`
+	Timezone        string     `gorm:"size:64;not null;default:UTC" json:"timezone"`
+	Locale          string     `gorm:"size:10;not null;default:en" json:"locale"`
+	Boards          []Board    `gorm:"many2many:board_members" json:"boards,omitempty"`
+	Cards           []Card     `gorm:"many2many:card_members" json:"cards,omitempty"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
 }
 
 func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
@@ -27,3 +41,25 @@ func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
 
 	return
 }
+
+func (u User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// Location is the user's time zone, UTC when it isn't set or no longer known.
+func (u User) Location() *time.Location {
+	loc, err := time.LoadLocation(u.Timezone)
+	if err != nil || u.Timezone == "" {
+		return time.UTC
+	}
+
+	return loc
+}
+
+// PublicUser is what other users get to see of an account.
+type PublicUser struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Username  string    `json:"username"`
+	AvatarURL string    `json:"avatar_url"`
+}
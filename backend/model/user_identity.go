@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// UserIdentity links a user to an account at an external identity provider.
+type UserIdentity struct {
+	ID        uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
+	UserID    uuid.UUID `gorm:"type:char(36);not null;index" json:"user_id"`
+	Provider  string    `gorm:"size:50;not null;uniqueIndex:idx_identity_subject" json:"provider"`
+	Subject   string    `gorm:"size:255;not null;uniqueIndex:idx_identity_subject" json:"subject"`
+	Email     string    `gorm:"size:100" json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (i *UserIdentity) BeforeCreate(tx *gorm.DB) (err error) {
+	i.ID = uuid.New()
+	return
+}
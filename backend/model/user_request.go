@@ -0,0 +1,22 @@
+package model
+
+// UpdateProfile only changes the fields that are sent.
+type UpdateProfile struct {
+	Name     *string `json:"name"`
+	Username *string `json:"username"`
+	Email    *string `json:"email" binding:"omitempty,email"`
+	Timezone *string `json:"timezone"`
+	Locale   *string `json:"locale"`
+}
+
+type ChangePassword struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8"`
+}
+
+// DeleteAccount needs the username typed again as confirmation, and the
+// password unless the deployment only uses single sign-on.
+type DeleteAccount struct {
+	Password string `json:"password"`
+	Confirm  string `json:"confirm" binding:"required"`
+}
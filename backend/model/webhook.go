@@ -0,0 +1,64 @@
+package model
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	WebhookPending   = "pending"
+	WebhookSucceeded = "succeeded"
+	WebhookFailed    = "failed"
+)
+
+// Webhook posts the events of a board to an outside URL. Events is a comma
+// separated filter, empty means every event. The secret signs the requests
+// so it has to be kept in the clear.
+type Webhook struct {
+	ID          uuid.UUID `gorm:"type:char(36);primaryKey" json:"id"`
+	BoardID     uuid.UUID `gorm:"type:char(36);not null;index" json:"board_id"`
+	URL         string    `gorm:"size:500;not null" json:"url"`
+	Secret      string    `gorm:"size:100;not null" json:"-"`
+	Events      string    `gorm:"size:255" json:"events"`
+	Active      bool      `gorm:"not null;default:true" json:"active"`
+	CreatedByID uuid.UUID `gorm:"type:char(36)" json:"created_by_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (w *Webhook) BeforeCreate(tx *gorm.DB) (err error) {
+	w.ID = uuid.New()
+	return
+}
+
+func (w Webhook) Subscribed(event string) bool {
+	return w.Events == "" || slices.Contains(strings.Split(w.Events, ","), event)
+}
+
+// WebhookDelivery is one event sent to one webhook, kept as a log of the
+// attempts. Payload is the exact body, so a redelivery sends the same bytes.
+type WebhookDelivery struct {
+	ID            uuid.UUID       `gorm:"type:char(36);primaryKey" json:"id"`
+	WebhookID     uuid.UUID       `gorm:"type:char(36);not null;index" json:"webhook_id"`
+	Event         string          `gorm:"size:50;not null" json:"event"`
+	Payload       json.RawMessage `gorm:"type:mediumtext" json:"payload"`
+	Status        string          `gorm:"size:20;not null;index:idx_webhook_delivery_due" json:"status"`
+	Attempts      int             `gorm:"not null;default:0" json:"attempts"`
+	StatusCode    int             `json:"status_code"`
+	Response      string          `gorm:"type:text" json:"response"`
+	Error         string          `gorm:"size:255" json:"error"`
+	NextAttemptAt *time.Time      `gorm:"index:idx_webhook_delivery_due" json:"next_attempt_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at"`
+	CreatedAt     time.Time       `gorm:"index" json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+func (d *WebhookDelivery) BeforeCreate(tx *gorm.DB) (err error) {
+	d.ID = uuid.New()
+	return
+}
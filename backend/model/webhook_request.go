@@ -0,0 +1,14 @@
+package model
+
+type NewWebhook struct {
+	URL    string   `json:"url" binding:"required,max=500"`
+	Events []string `json:"events" binding:"dive,oneof=board_update column_update card_notification activity"`
+	Secret string   `json:"secret" binding:"omitempty,min=16,max=100"`
+}
+
+// UpdateWebhook only changes the fields that are present in the request.
+type UpdateWebhook struct {
+	URL    *string   `json:"url" binding:"omitempty,max=500"`
+	Events *[]string `json:"events" binding:"omitempty,dive,oneof=board_update column_update card_notification activity"`
+	Active *bool     `json:"active"`
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	WorkspaceRoleOwner  = "owner"
+	WorkspaceRoleAdmin  = "admin"
+	WorkspaceRoleMember = "member"
+
+	// VisibilityPrivate boards are only listed to their members,
+	// VisibilityWorkspace boards to everyone in their workspace.
+	VisibilityPrivate   = "private"
+	VisibilityWorkspace = "workspace"
+)
+
+// Workspace groups the boards and the people of a team. The settings apply
+// to every board in it.
+type Workspace struct {
+	ID                uuid.UUID         `gorm:"type:char(36);primaryKey" json:"id"`
+	Name              string            `gorm:"size:100;not null" json:"name"`
+	OwnerID           uuid.UUID         `gorm:"type:char(36);not null;index" json:"owner_id"`
+	DefaultVisibility string            `gorm:"size:20;not null;default:workspace" json:"default_visibility"`
+	MembersCanCreate  bool              `gorm:"not null;default:true" json:"members_can_create"`
+	Members           []WorkspaceMember `gorm:"foreignKey:WorkspaceID" json:"members,omitempty"`
+	Boards            []Board           `gorm:"foreignKey:WorkspaceID" json:"boards,omitempty"`
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+func (w *Workspace) BeforeCreate(tx *gorm.DB) (err error) {
+	w.ID = uuid.New()
+	return
+}
+
+type WorkspaceMember struct {
+	WorkspaceID uuid.UUID `gorm:"type:char(36);primaryKey" json:"workspace_id"`
+	UserID      uuid.UUID `gorm:"type:char(36);primaryKey;index" json:"user_id"`
+	Role        string    `gorm:"size:20;not null;default:member" json:"role"`
+	User        User      `json:"user"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// PublicWorkspaceMember is a member as the rest of the workspace sees them.
+type PublicWorkspaceMember struct {
+	WorkspaceID uuid.UUID  `json:"workspace_id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	Role        string     `json:"role"`
+	User        PublicUser `json:"user"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// CanManage reports whether the member may change the workspace and its
+// member list.
+func (m WorkspaceMember) CanManage() bool {
+	return m.Role == WorkspaceRoleOwner || m.Role == WorkspaceRoleAdmin
+}
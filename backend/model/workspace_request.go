@@ -0,0 +1,28 @@
+package model
+
+type NewWorkspace struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// UpdateWorkspace only changes the fields that are sent.
+type UpdateWorkspace struct {
+	Name              *string `json:"name"`
+	DefaultVisibility *string `json:"default_visibility" binding:"omitempty,oneof=private workspace"`
+	MembersCanCreate  *bool   `json:"members_can_create"`
+}
+
+type AddWorkspaceMember struct {
+	UserID string `json:"user_id" binding:"required,uuid"`
+	Role   string `json:"role" binding:"omitempty,oneof=admin member"`
+}
+
+type WorkspaceMemberRole struct {
+	Role string `json:"role" binding:"required,oneof=admin member"`
+}
+
+// MoveBoard puts a board in a workspace, or takes it out with an empty
+// workspace id.
+type MoveBoard struct {
+	WorkspaceID string `json:"workspace_id" binding:"omitempty,uuid"`
+	Visibility  string `json:"visibility" binding:"omitempty,oneof=private workspace"`
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"kerjainaja/handlers"
 	"kerjainaja/helpers"
+	"kerjainaja/middleware"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -19,29 +20,143 @@ func MapRoutes(routes *gin.Engine) {
 		MaxAge:           12 * time.Hour,
 	}))
 
+	routes.Static("/uploads", handlers.UploadDir())
+	routes.GET("/calendar/:token", handlers.ServeCalendarFeed())
+
 	{
 		api := routes.Group("/api")
 		api.GET("/", func(ctx *gin.Context) {
 			helpers.ResponseJson(ctx, 200, true, nil, "api is up!")
 		})
-		api.POST("/login", handlers.Login())
-		api.POST("/register", handlers.Register())
+		api.POST("/login", middleware.RateLimit(20, time.Minute, middleware.ByIP), handlers.Login())
+		api.POST("/login/2fa", middleware.RateLimit(20, time.Minute, middleware.ByIP), handlers.LoginTwoFactor())
+		api.POST("/register", middleware.RateLimit(5, time.Minute, middleware.ByIP), handlers.Register())
+		api.GET("/auth/providers", handlers.GetAuthProviders())
+		api.GET("/oidc/:provider/login", handlers.SSOLogin())
+		api.GET("/oidc/:provider/callback", handlers.SSOCallback())
+		api.POST("/oidc/:provider/link", handlers.LinkSSO())
 		api.POST("/logout", handlers.Logout())
+		api.POST("/logout/all", handlers.LogoutAll())
+		api.POST("/refresh", handlers.RefreshSession())
+		api.GET("/sessions", handlers.GetSessions())
+		api.DELETE("/sessions/:id", handlers.RevokeSession())
+		api.POST("/password/forgot", middleware.RateLimit(5, time.Minute, middleware.ByIP), handlers.ForgotPassword())
+		api.POST("/password/reset", handlers.ResetPassword())
+		api.POST("/email/verification", handlers.SendEmailVerification())
+		api.POST("/email/verify", handlers.VerifyEmail())
+		api.POST("/email/change", handlers.ConfirmEmailChange())
 		api.GET("/users", handlers.GetUsers())
+		api.GET("/users/search", handlers.SearchUsers())
+		api.GET("/users/people", handlers.GetPeople())
+		api.PUT("/users/me", handlers.UpdateProfile())
+		api.PUT("/users/me/password", handlers.ChangePassword())
+		api.POST("/users/me/avatar", handlers.UploadAvatar())
+		api.DELETE("/users/me", handlers.DeleteAccount())
+		// two factor authentication
+		api.POST("/2fa/enroll", handlers.EnrollTwoFactor())
+		api.POST("/2fa/enable", handlers.EnableTwoFactor())
+		api.POST("/2fa/disable", handlers.DisableTwoFactor())
+		api.POST("/2fa/recovery-codes", handlers.RegenerateRecoveryCodes())
+		// personal access tokens
+		api.GET("/tokens", handlers.GetAccessTokens())
+		api.POST("/tokens", handlers.CreateAccessToken())
+		api.DELETE("/tokens/:id", handlers.RevokeAccessToken())
+		// calendar feeds
+		api.GET("/calendar-feeds", handlers.GetCalendarFeeds())
+		api.POST("/calendar-feeds", handlers.CreateCalendarFeed())
+		api.DELETE("/calendar-feeds/:id", handlers.RevokeCalendarFeed())
 		// column
 		api.GET("/boards", handlers.GetBoard())
 		api.POST("/board", handlers.CreateBoard())
 		api.GET("/boards/:id", handlers.GetBoards())
 		api.DELETE("/boards/:id/members", handlers.LeaveBoard())
+		api.PUT("/boards/:id/owner", handlers.TransferBoard())
+		api.PUT("/boards/:id/workspace", handlers.MoveBoard())
+		api.POST("/boards/:id/duplicate", handlers.DuplicateBoard())
+		api.GET("/boards/:id/export", handlers.ExportBoard())
+		api.GET("/boards/:id/export/csv", handlers.ExportBoardFlat("csv"))
+		api.GET("/boards/:id/export/markdown", handlers.ExportBoardFlat("markdown"))
+		api.POST("/boards/import", handlers.ImportBoard())
+		api.POST("/boards/import/trello", handlers.ImportTrello())
+		api.POST("/boards/:id/templates", handlers.CreateTemplate())
+		api.GET("/boards/:id/activity", handlers.GetBoardActivity())
+		api.GET("/boards/:id/webhooks", handlers.GetWebhooks())
+		api.POST("/boards/:id/webhooks", handlers.CreateWebhook())
+		api.GET("/boards/:id/automations", handlers.GetAutomationRules())
+		api.POST("/boards/:id/automations", handlers.CreateAutomationRule())
+		api.GET("/boards/:id/automation-runs", handlers.GetAutomationRuns())
+		api.PUT("/automations/:id", handlers.UpdateAutomationRule())
+		api.DELETE("/automations/:id", handlers.DeleteAutomationRule())
+		api.GET("/boards/:id/git-integrations", handlers.GetGitIntegrations())
+		api.POST("/boards/:id/git-integrations", handlers.CreateGitIntegration())
+		api.DELETE("/git-integrations/:id", handlers.RevokeGitIntegration())
+		api.POST("/git/:token", middleware.RateLimit(300, time.Minute, middleware.ByIP), middleware.RateLimit(60, time.Minute, middleware.ByParam("token")), handlers.ReceiveGitPush())
+		api.PUT("/boards/:id/key-prefix", handlers.UpdateKeyPrefix())
+		api.GET("/boards/:id/cards/:key", handlers.GetCardByKey())
+		api.GET("/boards/:id/labels", handlers.GetLabels())
+		api.POST("/boards/:id/labels", handlers.CreateLabel())
 		// column
 		api.POST("/column", handlers.CreateColumn())
 		api.PUT("/column/:id", handlers.EditColumn())
 		api.DELETE("/column/:id", handlers.DeleteColumn())
+		api.PUT("/column/:id/wip-limit", handlers.UpdateWIPLimit())
+		api.GET("/column/:id/inbound-webhooks", handlers.GetInboundWebhooks())
+		api.POST("/column/:id/inbound-webhooks", handlers.CreateInboundWebhook())
+		api.DELETE("/inbound-webhooks/:id", handlers.RevokeInboundWebhook())
+		api.POST("/inbound/:token", middleware.RateLimit(300, time.Minute, middleware.ByIP), middleware.RateLimit(60, time.Minute, middleware.ByParam("token")), handlers.ReceiveInboundWebhook())
 		// cards
 		api.POST("/cards", handlers.CreateNewCard())
+		api.PUT("/cards/:id", handlers.UpdateCard())
 		api.DELETE("/cards/:id", handlers.DeleteCard())
 		api.POST("/cards/:id/members", handlers.JoinCard())
 		api.DELETE("/cards/:id/members", handlers.LeaveCard())
+		api.POST("/cards/:id/assignees", handlers.AssignCard())
+		api.DELETE("/cards/:id/assignees/:userId", handlers.UnassignCard())
+		api.POST("/cards/:id/watchers", handlers.WatchCard())
+		api.DELETE("/cards/:id/watchers", handlers.UnwatchCard())
+		api.GET("/cards/:id/history", handlers.GetCardHistory())
+		api.GET("/cards/:id/commits", handlers.GetCardCommits())
+		api.GET("/cards/:id/comments", handlers.GetComments())
+		api.POST("/cards/:id/comments", handlers.CreateComment())
+		api.DELETE("/comments/:id", handlers.DeleteComment())
+		api.POST("/cards/:id/labels", handlers.AddCardLabel())
+		api.DELETE("/cards/:id/labels/:labelId", handlers.RemoveCardLabel())
+		// workspaces
+		api.GET("/workspaces", handlers.GetWorkspaces())
+		api.POST("/workspaces", handlers.CreateWorkspace())
+		api.GET("/workspaces/:id", handlers.GetWorkspace())
+		api.PUT("/workspaces/:id", handlers.UpdateWorkspace())
+		api.DELETE("/workspaces/:id", handlers.DeleteWorkspace())
+		api.PUT("/workspaces/:id/owner", handlers.TransferWorkspace())
+		api.POST("/workspaces/:id/members", handlers.AddWorkspaceMember())
+		api.PUT("/workspaces/:id/members/:userId", handlers.UpdateWorkspaceMember())
+		api.DELETE("/workspaces/:id/members/:userId", handlers.RemoveWorkspaceMember())
+		api.GET("/workspaces/:id/boards", handlers.GetWorkspaceBoards())
+		// webhooks
+		api.PUT("/webhooks/:id", handlers.UpdateWebhook())
+		api.DELETE("/webhooks/:id", handlers.DeleteWebhook())
+		api.POST("/webhooks/:id/ping", handlers.PingWebhook())
+		api.GET("/webhooks/:id/deliveries", handlers.GetWebhookDeliveries())
+		api.POST("/webhooks/:id/deliveries/:deliveryId/redeliver", handlers.RedeliverWebhook())
+		// templates
+		api.GET("/templates", handlers.GetTemplates())
+		api.DELETE("/templates/:id", handlers.DeleteTemplate())
+		// filters
+		api.GET("/filters", handlers.GetSavedFilters())
+		api.POST("/filters", handlers.CreateSavedFilter())
+		api.DELETE("/filters/:id", handlers.DeleteSavedFilter())
+		// search
+		api.GET("/search", handlers.SearchCards())
+		// admin
+		admin := api.Group("/admin", handlers.RequireAdmin())
+		admin.GET("/users", handlers.AdminGetUsers())
+		admin.POST("/users/:id/disable", handlers.AdminDisableUser())
+		admin.POST("/users/:id/enable", handlers.AdminEnableUser())
+		admin.POST("/users/:id/password", handlers.AdminResetPassword())
+		admin.POST("/users/:id/logout", handlers.AdminLogoutUser())
+		admin.PUT("/users/:id/role", handlers.AdminChangeRole())
+		admin.GET("/boards", handlers.AdminGetBoards())
+		admin.PUT("/boards/:id/owner", handlers.AdminTransferBoard())
 		// event stream
 		api.GET("/event-stream", handlers.HandleEventStream())
 	}
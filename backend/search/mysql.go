@@ -0,0 +1,32 @@
+package search
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// MySQL searches through the FULLTEXT index declared on model.Card.
+type MySQL struct{}
+
+func (MySQL) Migrate(db *gorm.DB) error {
+	return nil
+}
+
+func (MySQL) SearchCards(db *gorm.DB, query Query) (Result, error) {
+	terms := Terms(query.Text)
+	if len(terms) == 0 {
+		return Result{Hits: []CardHit{}}, nil
+	}
+
+	// boolean mode with a trailing * makes every word a required prefix
+	booleanQuery := "+" + strings.Join(terms, "* +") + "*"
+
+	match := "MATCH(cards.title, cards.description) AGAINST (? IN BOOLEAN MODE)"
+	return run(db, query, matcher{
+		match:     match,
+		score:     match,
+		matchArgs: []any{booleanQuery},
+		scoreArgs: []any{booleanQuery},
+	})
+}
@@ -0,0 +1,186 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Engine runs card searches against a specific database backend. MySQL is
+// used in production, SQLite FTS5 lets the same queries run in tests.
+type Engine interface {
+	// Migrate prepares whatever the backend needs besides the regular tables.
+	Migrate(db *gorm.DB) error
+	SearchCards(db *gorm.DB, query Query) (Result, error)
+}
+
+type Query struct {
+	Text   string
+	UserID uuid.UUID
+	Limit  int
+	Offset int
+}
+
+type CardHit struct {
+	ID                   uuid.UUID `json:"id"`
+	Title                string    `json:"title"`
+	Description          string    `json:"description"`
+	ColumnID             uuid.UUID `json:"column_id"`
+	BoardID              uuid.UUID `json:"board_id"`
+	BoardName            string    `json:"board_name"`
+	Score                float64   `json:"score"`
+	TitleHighlight       string    `json:"title_highlight" gorm:"-"`
+	DescriptionHighlight string    `json:"description_highlight" gorm:"-"`
+}
+
+type Result struct {
+	Hits  []CardHit `json:"hits"`
+	Total int64     `json:"total"`
+}
+
+// Terms splits a search text into lowercase words, dropping punctuation so
+// nothing user supplied reaches the backend query syntax.
+func Terms(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if !containsString(terms, f) {
+			terms = append(terms, f)
+		}
+	}
+
+	return terms
+}
+
+// matcher describes how a backend matches and scores cards.
+type matcher struct {
+	join      string
+	match     string
+	score     string
+	matchArgs []any
+	scoreArgs []any
+}
+
+// memberCards selects the cards of every board the user is a member of.
+func memberCards(db *gorm.DB, userID uuid.UUID, m matcher) *gorm.DB {
+	tx := db.Table("cards").
+		Joins("JOIN columns ON columns.id = cards.column_id").
+		Joins("JOIN boards ON boards.id = columns.board_id").
+		Joins("JOIN board_members ON board_members.board_id = boards.id AND board_members.user_id = ?", userID)
+
+	if m.join != "" {
+		tx = tx.Joins(m.join)
+	}
+
+	return tx.Where(m.match, m.matchArgs...)
+}
+
+func run(db *gorm.DB, query Query, m matcher) (Result, error) {
+	var result Result
+
+	if err := memberCards(db, query.UserID, m).Count(&result.Total).Error; err != nil {
+		return result, err
+	}
+
+	err := memberCards(db, query.UserID, m).
+		Select("cards.id, cards.title, cards.description, cards.column_id, columns.board_id, boards.name AS board_name, "+m.score+" AS score", m.scoreArgs...).
+		Order("score DESC").
+		Limit(query.Limit).
+		Offset(query.Offset).
+		Scan(&result.Hits).Error
+	if err != nil {
+		return result, err
+	}
+
+	terms := Terms(query.Text)
+	for i := range result.Hits {
+		result.Hits[i].TitleHighlight = Highlight(result.Hits[i].Title, terms, 0)
+		result.Hits[i].DescriptionHighlight = Highlight(result.Hits[i].Description, terms, 160)
+	}
+
+	return result, nil
+}
+
+// Highlight HTML-escapes text and wraps every word starting with one of the
+// terms in <mark>. When maxLen is positive the text is cut down to a snippet
+// of about maxLen characters around the first match.
+func Highlight(text string, terms []string, maxLen int) string {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+
+	type span struct{ start, end int }
+	var spans []span
+
+	for i := 0; i < len(lower); i++ {
+		if i > 0 && isWordRune(lower[i-1]) {
+			continue
+		}
+
+		for _, term := range terms {
+			t := []rune(term)
+			if i+len(t) <= len(lower) && string(lower[i:i+len(t)]) == term {
+				end := i + len(t)
+				for end < len(lower) && isWordRune(lower[end]) {
+					end++
+				}
+				spans = append(spans, span{i, end})
+				i = end - 1
+				break
+			}
+		}
+	}
+
+	from, to := 0, len(runes)
+	if maxLen > 0 && len(runes) > maxLen {
+		if len(spans) > 0 {
+			from = max(spans[0].start-maxLen/4, 0)
+		}
+		to = min(from+maxLen, len(runes))
+		from = max(to-maxLen, 0)
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+
+	pos := from
+	for _, s := range spans {
+		if s.end <= from || s.start >= to {
+			continue
+		}
+		start, end := max(s.start, from), min(s.end, to)
+		b.WriteString(html.EscapeString(string(runes[pos:start])))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(string(runes[start:end])))
+		b.WriteString("</mark>")
+		pos = end
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:to])))
+
+	if to < len(runes) {
+		b.WriteString("…")
+	}
+
+	return b.String()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,53 @@
+package search
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// SQLite searches through an FTS5 table kept in sync with the cards table by
+// triggers. It is meant for tests and small single-file deployments.
+type SQLite struct{}
+
+func (SQLite) Migrate(db *gorm.DB) error {
+	statements := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS cards_fts USING fts5(title, description, content='cards', content_rowid='rowid')`,
+		`CREATE TRIGGER IF NOT EXISTS cards_fts_ai AFTER INSERT ON cards BEGIN
+			INSERT INTO cards_fts(rowid, title, description) VALUES (new.rowid, new.title, new.description);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS cards_fts_ad AFTER DELETE ON cards BEGIN
+			INSERT INTO cards_fts(cards_fts, rowid, title, description) VALUES ('delete', old.rowid, old.title, old.description);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS cards_fts_au AFTER UPDATE ON cards BEGIN
+			INSERT INTO cards_fts(cards_fts, rowid, title, description) VALUES ('delete', old.rowid, old.title, old.description);
+			INSERT INTO cards_fts(rowid, title, description) VALUES (new.rowid, new.title, new.description);
+		END`,
+		`INSERT INTO cards_fts(cards_fts) VALUES ('rebuild')`,
+	}
+
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (SQLite) SearchCards(db *gorm.DB, query Query) (Result, error) {
+	terms := Terms(query.Text)
+	if len(terms) == 0 {
+		return Result{Hits: []CardHit{}}, nil
+	}
+
+	// every word becomes a quoted prefix query, joined with an implicit AND
+	ftsQuery := `"` + strings.Join(terms, `"* "`) + `"*`
+
+	return run(db, query, matcher{
+		join:      "JOIN cards_fts ON cards_fts.rowid = cards.rowid",
+		match:     "cards_fts MATCH ?",
+		score:     "-bm25(cards_fts)",
+		matchArgs: []any{ftsQuery},
+	})
+}
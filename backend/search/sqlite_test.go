@@ -0,0 +1,110 @@
+package search
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func setupSQLite(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema := []string{
+		`CREATE TABLE boards (id TEXT PRIMARY KEY, name TEXT)`,
+		`CREATE TABLE board_members (board_id TEXT, user_id TEXT)`,
+		`CREATE TABLE columns (id TEXT PRIMARY KEY, name TEXT, board_id TEXT)`,
+		`CREATE TABLE cards (id TEXT PRIMARY KEY, title TEXT, description TEXT, column_id TEXT)`,
+	}
+	for _, statement := range schema {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := (SQLite{}).Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestSQLiteSearchCards(t *testing.T) {
+	db := setupSQLite(t)
+
+	member := uuid.New()
+	stranger := uuid.New()
+	board := uuid.New()
+	otherBoard := uuid.New()
+	column := uuid.New()
+	otherColumn := uuid.New()
+
+	db.Exec(`INSERT INTO boards VALUES (?, 'Sprint'), (?, 'Secret')`, board, otherBoard)
+	db.Exec(`INSERT INTO board_members VALUES (?, ?), (?, ?)`, board, member, otherBoard, stranger)
+	db.Exec(`INSERT INTO columns VALUES (?, 'Todo', ?), (?, 'Todo', ?)`, column, board, otherColumn, otherBoard)
+	db.Exec(`INSERT INTO cards VALUES (?, 'Fix login page', 'The login form breaks on <Safari>', ?)`, uuid.New(), column)
+	db.Exec(`INSERT INTO cards VALUES (?, 'Write docs', 'Explain how logging works', ?)`, uuid.New(), column)
+	db.Exec(`INSERT INTO cards VALUES (?, 'Login audit', 'Hidden from other boards', ?)`, uuid.New(), otherColumn)
+
+	result, err := (SQLite{}).SearchCards(db, Query{Text: "log", UserID: member, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Total != 2 || len(result.Hits) != 2 {
+		t.Fatalf("expected 2 hits, got total %d and %d hits", result.Total, len(result.Hits))
+	}
+
+	for _, hit := range result.Hits {
+		if hit.BoardID != board {
+			t.Errorf("hit %q comes from a board the user is not a member of", hit.Title)
+		}
+	}
+
+	result, err = (SQLite{}).SearchCards(db, Query{Text: "login safari", UserID: member, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Total != 1 {
+		t.Fatalf("expected 1 hit, got %d", result.Total)
+	}
+
+	hit := result.Hits[0]
+	if hit.TitleHighlight != "Fix <mark>login</mark> page" {
+		t.Errorf("unexpected title highlight %q", hit.TitleHighlight)
+	}
+
+	if !strings.Contains(hit.DescriptionHighlight, "&lt;<mark>Safari</mark>&gt;") {
+		t.Errorf("unexpected description highlight %q", hit.DescriptionHighlight)
+	}
+
+	db.Exec(`DELETE FROM cards WHERE title = 'Fix login page'`)
+
+	result, err = (SQLite{}).SearchCards(db, Query{Text: "safari", UserID: member, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Total != 0 {
+		t.Errorf("expected deleted card to disappear from the index, got %d hits", result.Total)
+	}
+}
+
+func TestHighlightSnippet(t *testing.T) {
+	text := strings.Repeat("padding ", 40) + "needle " + strings.Repeat("tail ", 40)
+
+	got := Highlight(text, []string{"needle"}, 60)
+	if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") {
+		t.Errorf("expected snippet to be cut on both sides, got %q", got)
+	}
+
+	if !strings.Contains(got, "<mark>needle</mark>") {
+		t.Errorf("expected snippet to contain the match, got %q", got)
+	}
+}
@@ -0,0 +1,156 @@
+// Package sso implements OpenID Connect login with the authorization code
+// flow and PKCE.
+package sso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kerjainaja/config"
+	"strings"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+}
+
+type Provider struct {
+	Name     string
+	oauth    oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// Claims are the parts of the ID token used to find or create a user.
+type Claims struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// NewProvider runs OIDC discovery against the issuer.
+func NewProvider(ctx context.Context, cfg Config) (*Provider, error) {
+	discovered, err := oidc.NewProvider(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("sso: discovery for %s failed: %w", cfg.Name, err)
+	}
+
+	return &Provider{
+		Name: cfg.Name,
+		oauth: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     discovered.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+		},
+		verifier: discovered.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+	}, nil
+}
+
+// AuthCodeURL is where the browser is sent to log in. state and nonce tie the
+// callback to this attempt, the PKCE verifier is kept by the caller.
+func (p *Provider) AuthCodeURL(state string, nonce string, verifier string) string {
+	return p.oauth.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+}
+
+// Exchange trades the authorization code for tokens and returns the verified
+// ID token claims.
+func (p *Provider) Exchange(ctx context.Context, code string, verifier string, nonce string) (Claims, error) {
+	var claims Claims
+
+	token, err := p.oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return claims, fmt.Errorf("sso: code exchange failed: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return claims, errors.New("sso: token response has no id_token")
+	}
+
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return claims, fmt.Errorf("sso: id token is invalid: %w", err)
+	}
+
+	if idToken.Nonce != nonce {
+		return claims, errors.New("sso: id token nonce does not match")
+	}
+
+	if err := idToken.Claims(&claims); err != nil {
+		return claims, fmt.Errorf("sso: failed to read claims: %w", err)
+	}
+
+	return claims, nil
+}
+
+var (
+	providers   = make(map[string]*Provider)
+	providersMu sync.Mutex
+)
+
+// Names lists the providers configured with OIDC_PROVIDERS.
+func Names() []string {
+	var names []string
+	for _, name := range strings.Split(config.Env("OIDC_PROVIDERS"), ",") {
+		if name = strings.TrimSpace(strings.ToLower(name)); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// Get returns a configured provider, running discovery the first time it is
+// used so an identity provider being down doesn't stop the server starting.
+func Get(ctx context.Context, name string) (*Provider, error) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	if p, ok := providers[name]; ok {
+		return p, nil
+	}
+
+	found := false
+	for _, n := range Names() {
+		found = found || n == name
+	}
+	if !found {
+		return nil, fmt.Errorf("sso: provider %q is not configured", name)
+	}
+
+	prefix := "OIDC_" + strings.ToUpper(name) + "_"
+	p, err := NewProvider(ctx, Config{
+		Name:         name,
+		Issuer:       config.Env(prefix + "ISSUER"),
+		ClientID:     config.Env(prefix + "CLIENT_ID"),
+		ClientSecret: config.Env(prefix + "CLIENT_SECRET"),
+		RedirectURL:  config.Env(prefix + "REDIRECT_URL"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	providers[name] = p
+	return p, nil
+}
+
+// PasswordLoginDisabled reports whether the deployment only allows SSO.
+func PasswordLoginDisabled() bool {
+	return config.Env("PASSWORD_LOGIN_DISABLED") == "true"
+}
+
+// GenerateVerifier returns a new PKCE code verifier.
+func GenerateVerifier() string {
+	return oauth2.GenerateVerifier()
+}
@@ -0,0 +1,149 @@
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockIssuer is a minimal OIDC provider: discovery, JWKS and a token endpoint
+// that checks the PKCE verifier against the challenge of the login request.
+type mockIssuer struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	nonce     string
+	email     string
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mockIssuer{key: key, email: "budi@example.com"}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                m.URL,
+			"authorization_endpoint":                m.URL + "/authorize",
+			"token_endpoint":                        m.URL + "/token",
+			"jwks_uri":                              m.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]any{{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": "test",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if r.Form.Get("code") != "good-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != m.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{"error": "invalid_grant"})
+			return
+		}
+
+		idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":                m.URL,
+			"aud":                "kerjainaja",
+			"sub":                "user-1",
+			"exp":                time.Now().Add(time.Hour).Unix(),
+			"iat":                time.Now().Unix(),
+			"nonce":              m.nonce,
+			"email":              m.email,
+			"email_verified":     true,
+			"name":               "Budi",
+			"preferred_username": "budi",
+		})
+		idToken.Header["kid"] = "test"
+
+		signed, err := idToken.SignedString(key)
+		if err != nil {
+			t.Error(err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     signed,
+		})
+	})
+
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+
+	return m
+}
+
+func login(t *testing.T, m *mockIssuer, p *Provider, verifier string) {
+	authURL, err := url.Parse(p.AuthCodeURL("state", "nonce-1", verifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := authURL.Query()
+	if query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("code_challenge_method = %q, want S256", query.Get("code_challenge_method"))
+	}
+
+	m.challenge = query.Get("code_challenge")
+	m.nonce = query.Get("nonce")
+}
+
+func TestExchange(t *testing.T) {
+	m := newMockIssuer(t)
+	ctx := context.Background()
+
+	p, err := NewProvider(ctx, Config{Name: "mock", Issuer: m.URL, ClientID: "kerjainaja", RedirectURL: "http://localhost/callback"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier := GenerateVerifier()
+	login(t, m, p, verifier)
+
+	claims, err := p.Exchange(ctx, "good-code", verifier, "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.Subject != "user-1" || claims.Email != m.email || !claims.EmailVerified || claims.PreferredUsername != "budi" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+
+	if _, err := p.Exchange(ctx, "good-code", GenerateVerifier(), "nonce-1"); err == nil {
+		t.Error("exchange with a different PKCE verifier succeeded")
+	}
+
+	if _, err := p.Exchange(ctx, "good-code", verifier, "other-nonce"); err == nil {
+		t.Error("exchange with a different nonce succeeded")
+	}
+}
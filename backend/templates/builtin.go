@@ -0,0 +1,35 @@
+package templates
+
+// Builtin are the templates every user can start a board from, keyed by
+// their id.
+var Builtin = map[string]Template{
+	"kanban": {
+		Name:        "Kanban",
+		Description: "A simple flow from backlog to done.",
+		Content: Content{
+			Columns: []Column{{Name: "Backlog"}, {Name: "In Progress"}, {Name: "Review"}, {Name: "Done"}},
+		},
+	},
+	"scrum": {
+		Name:        "Scrum sprint",
+		Description: "Sprint board with story types as labels.",
+		Content: Content{
+			Columns: []Column{{Name: "Sprint Backlog"}, {Name: "To Do"}, {Name: "In Progress"}, {Name: "Testing"}, {Name: "Done"}},
+			Labels:  []Label{{Name: "story", Color: "green"}, {Name: "bug", Color: "red"}, {Name: "chore", Color: "gray"}, {Name: "spike", Color: "purple"}},
+		},
+	},
+	"bug-tracking": {
+		Name:        "Bug tracking",
+		Description: "Triage incoming bugs by severity.",
+		Content: Content{
+			Columns: []Column{{Name: "Reported"}, {Name: "Triaged"}, {Name: "Fixing"}, {Name: "Verifying"}, {Name: "Closed"}},
+			Labels:  []Label{{Name: "critical", Color: "red"}, {Name: "major", Color: "orange"}, {Name: "minor", Color: "yellow"}},
+		},
+	},
+}
+
+type Template struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Content     Content `json:"content"`
+}
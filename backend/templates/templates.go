@@ -0,0 +1,165 @@
+// Package templates copies the structure of boards: saving a board as a
+// template, the built-in templates and deep copies of a board.
+package templates
+
+import (
+	"kerjainaja/model"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Content is the structure of a board as stored in a template.
+type Content struct {
+	Columns []Column `json:"columns"`
+	Labels  []Label  `json:"labels"`
+}
+
+type Column struct {
+	Name     string `json:"name"`
+	WIPLimit int    `json:"wip_limit,omitempty"`
+	WIPMode  string `json:"wip_mode,omitempty"`
+	Cards    []Card `json:"cards,omitempty"`
+}
+
+type Label struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// Card keeps the label names only, templates are reused across boards so
+// assignees and due dates are only filled in for a duplicate.
+type Card struct {
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	Labels      []string    `json:"labels,omitempty"`
+	DueDate     *time.Time  `json:"due_date,omitempty"`
+	Archived    bool        `json:"archived,omitempty"`
+	MemberIDs   []uuid.UUID `json:"member_ids,omitempty"`
+}
+
+type Options struct {
+	// Cards includes the cards of every column.
+	Cards bool
+	// Details keeps due dates, archived state and assignees of the cards.
+	Details bool
+}
+
+// FromBoard captures a board loaded with its columns, cards, card labels,
+// card members and labels. Columns and cards keep the order they were
+// created in.
+func FromBoard(board model.Board, labels []model.Label, opts Options) Content {
+	content := Content{
+		Columns: make([]Column, 0, len(board.Columns)),
+		Labels:  make([]Label, 0, len(labels)),
+	}
+
+	for _, l := range labels {
+		content.Labels = append(content.Labels, Label{Name: l.Name, Color: l.Color})
+	}
+
+	columns := slices.Clone(board.Columns)
+	slices.SortStableFunc(columns, func(a, b model.Column) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	for _, col := range columns {
+		column := Column{Name: col.Name}
+		if col.WIPLimit > 0 {
+			column.WIPLimit = col.WIPLimit
+			column.WIPMode = col.WIPMode
+		}
+
+		if opts.Cards {
+			cards := slices.Clone(col.Cards)
+			slices.SortStableFunc(cards, func(a, b model.Card) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+			for _, c := range cards {
+				card := Card{Title: c.Title, Description: c.Description}
+				for _, l := range c.Labels {
+					card.Labels = append(card.Labels, l.Name)
+				}
+
+				if opts.Details {
+					card.DueDate = c.DueDate
+					card.Archived = c.Archived
+					for _, m := range c.Members {
+						card.MemberIDs = append(card.MemberIDs, m.ID)
+					}
+				}
+
+				column.Cards = append(column.Cards, card)
+			}
+		}
+
+		content.Columns = append(content.Columns, column)
+	}
+
+	return content
+}
+
+// Apply creates the columns, labels and cards of the content on an existing
+// board. Card members that aren't on the board are left out.
+func Apply(tx *gorm.DB, boardID uuid.UUID, content Content) error {
+	labels := make(map[string]model.Label, len(content.Labels))
+	for _, l := range content.Labels {
+		if _, exists := labels[l.Name]; exists {
+			continue
+		}
+
+		label := model.Label{BoardID: boardID, Name: l.Name, Color: l.Color}
+		if err := tx.Create(&label).Error; err != nil {
+			return err
+		}
+		labels[l.Name] = label
+	}
+
+	var memberIDs []uuid.UUID
+	if err := tx.Table("board_members").Where("board_id = ?", boardID).Pluck("user_id", &memberIDs).Error; err != nil {
+		return err
+	}
+
+	// rows are ordered by creation time, so every row gets its own timestamp
+	at := time.Now()
+	next := func() time.Time {
+		at = at.Add(time.Millisecond)
+		return at
+	}
+
+	for _, c := range content.Columns {
+		column := model.Column{BoardID: boardID, Name: c.Name, CreatedAt: next()}
+		column.WIPLimit, column.WIPMode = model.WIPSettings(c.WIPLimit, c.WIPMode)
+		if err := tx.Create(&column).Error; err != nil {
+			return err
+		}
+
+		for _, cc := range c.Cards {
+			card := model.Card{
+				ColumnID:    column.ID,
+				Title:       cc.Title,
+				Description: cc.Description,
+				DueDate:     cc.DueDate,
+				Archived:    cc.Archived,
+				CreatedAt:   next(),
+			}
+
+			for _, name := range cc.Labels {
+				if label, ok := labels[name]; ok {
+					card.Labels = append(card.Labels, label)
+				}
+			}
+
+			for _, id := range cc.MemberIDs {
+				if slices.Contains(memberIDs, id) {
+					card.Members = append(card.Members, model.User{ID: id})
+				}
+			}
+
+			if err := tx.Omit("Labels.*", "Members.*").Create(&card).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,384 @@
+// Package webhooks posts board events to the URLs registered on a board.
+// Every event is written to the delivery log first and sent from there, so
+// failed deliveries are retried with exponential backoff, also across
+// restarts, and can be sent again by hand.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"kerjainaja/config"
+	"kerjainaja/model"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Events are the board events a webhook can subscribe to, the same ones
+// board clients get over SSE. EventPing is only sent by hand.
+var Events = []string{"board_update", "column_update", "card_notification", "activity", "wip_limit"}
+
+const EventPing = "ping"
+
+// Request headers. The signature is a hex HMAC-SHA256 of the timestamp, a
+// dot and the body, keyed with the webhook secret.
+const (
+	HeaderEvent     = "X-Kerjainaja-Event"
+	HeaderDelivery  = "X-Kerjainaja-Delivery"
+	HeaderTimestamp = "X-Kerjainaja-Timestamp"
+	HeaderSignature = "X-Kerjainaja-Signature"
+)
+
+// responseLimit is how much of a receiver's response is kept in the log.
+const responseLimit = 2048
+
+type Dispatcher struct {
+	DB     *gorm.DB
+	Client *http.Client
+	// MaxAttempts is how often a delivery is tried before it is failed.
+	MaxAttempts int
+	// RetryBase is the wait after the first failed attempt, doubled after
+	// every further one and capped at RetryMax.
+	RetryBase time.Duration
+	RetryMax  time.Duration
+}
+
+// Default sends the events of the handlers. Nothing is sent until Init.
+var Default *Dispatcher
+
+// Init sets up Default on db and starts retrying due deliveries in the
+// background. Receivers on private addresses are refused unless
+// WEBHOOK_ALLOW_PRIVATE_URLS is true.
+func Init(db *gorm.DB) {
+	Default = New(db, NewClient(config.Env("WEBHOOK_ALLOW_PRIVATE_URLS") == "true"))
+	go Default.Run(context.Background(), 15*time.Second)
+}
+
+func New(db *gorm.DB, client *http.Client) *Dispatcher {
+	return &Dispatcher{
+		DB:          db,
+		Client:      client,
+		MaxAttempts: 6,
+		RetryBase:   30 * time.Second,
+		RetryMax:    time.Hour,
+	}
+}
+
+// NewClient returns the client deliveries are sent with. Unless allowPrivate
+// is set it refuses to connect to loopback, private and link-local
+// addresses, checked after DNS resolution so a hostname can't point inside.
+func NewClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+				return fmt.Errorf("address %s is not allowed", host)
+			}
+
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   10 * time.Second,
+		// a redirect could lead anywhere, receivers have to answer directly
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// ValidateURL checks a webhook URL before it is saved.
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https url")
+	}
+
+	if u.User != nil {
+		return errors.New("url must not contain credentials")
+	}
+
+	return nil
+}
+
+// Sign returns the signature header value for a body sent at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Payload is the body of every delivery.
+type Payload struct {
+	Event     string          `json:"event"`
+	BoardID   uuid.UUID       `json:"board_id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Publish logs an event for every active webhook of the board that
+// subscribed to it and sends them in the background. It is a no-op before
+// Init.
+func Publish(boardID uuid.UUID, event string, data []byte) {
+	if Default == nil {
+		return
+	}
+
+	deliveries, err := Default.Enqueue(boardID, event, data)
+	if err != nil {
+		log.Printf("webhooks: failed to queue %s for board %s: %v", event, boardID, err)
+		return
+	}
+
+	for _, delivery := range deliveries {
+		go Default.Deliver(delivery.ID)
+	}
+}
+
+// Enqueue writes a pending delivery of the event for every subscribed webhook
+// of the board.
+func (d *Dispatcher) Enqueue(boardID uuid.UUID, event string, data []byte) ([]model.WebhookDelivery, error) {
+	var hooks []model.Webhook
+	if err := d.DB.Where("board_id = ? AND active = ?", boardID, true).Find(&hooks).Error; err != nil {
+		return nil, err
+	}
+
+	var deliveries []model.WebhookDelivery
+	for _, hook := range hooks {
+		if !hook.Subscribed(event) {
+			continue
+		}
+
+		delivery, err := d.enqueue(hook, event, data)
+		if err != nil {
+			return deliveries, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+func (d *Dispatcher) enqueue(hook model.Webhook, event string, data []byte) (model.WebhookDelivery, error) {
+	body, err := json.Marshal(Payload{
+		Event:     event,
+		BoardID:   hook.BoardID,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return model.WebhookDelivery{}, err
+	}
+
+	return d.create(hook.ID, event, body)
+}
+
+func (d *Dispatcher) create(webhookID uuid.UUID, event string, body []byte) (model.WebhookDelivery, error) {
+	// whole seconds survive any column precision, so the delivery is due
+	// for the claim in Deliver right away
+	now := time.Now().Truncate(time.Second)
+	delivery := model.WebhookDelivery{
+		WebhookID:     webhookID,
+		Event:         event,
+		Payload:       body,
+		Status:        model.WebhookPending,
+		NextAttemptAt: &now,
+	}
+
+	err := d.DB.Create(&delivery).Error
+	return delivery, err
+}
+
+// Ping sends a ping event to a webhook right away, active or not.
+func (d *Dispatcher) Ping(hook model.Webhook) (model.WebhookDelivery, error) {
+	delivery, err := d.enqueue(hook, EventPing, []byte(`{"message":"ping"}`))
+	if err != nil {
+		return delivery, err
+	}
+
+	return d.Deliver(delivery.ID)
+}
+
+// Redeliver sends the payload of an earlier delivery again as a new
+// delivery, which is tried once right away and retried like any other.
+func (d *Dispatcher) Redeliver(previous model.WebhookDelivery) (model.WebhookDelivery, error) {
+	delivery, err := d.create(previous.WebhookID, previous.Event, previous.Payload)
+	if err != nil {
+		return delivery, err
+	}
+
+	return d.Deliver(delivery.ID)
+}
+
+// Deliver makes one attempt at a pending delivery that is due and records
+// the outcome. A delivery another worker is already sending is left alone.
+func (d *Dispatcher) Deliver(id uuid.UUID) (model.WebhookDelivery, error) {
+	var delivery model.WebhookDelivery
+
+	// claiming moves the next attempt out of reach of the retry loop while
+	// the request is in flight
+	now := time.Now()
+	lease := now.Add(d.Client.Timeout + time.Minute)
+	claim := d.DB.Model(&model.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", id, model.WebhookPending, now).
+		Update("next_attempt_at", lease)
+	if claim.Error != nil {
+		return delivery, claim.Error
+	}
+
+	if err := d.DB.First(&delivery, "id = ?", id).Error; err != nil {
+		return delivery, err
+	}
+
+	if claim.RowsAffected == 0 {
+		return delivery, nil
+	}
+
+	var hook model.Webhook
+	if err := d.DB.First(&hook, "id = ?", delivery.WebhookID).Error; err != nil {
+		return delivery, d.finish(&delivery, 0, "", "webhook is deleted", true)
+	}
+
+	if !hook.Active && delivery.Event != EventPing {
+		return delivery, d.finish(&delivery, 0, "", "webhook is inactive", true)
+	}
+
+	status, response, err := d.send(hook, delivery)
+
+	errMessage := ""
+	if err != nil {
+		errMessage = err.Error()
+	} else if status < 200 || status > 299 {
+		errMessage = fmt.Sprintf("receiver answered %d", status)
+	}
+
+	return delivery, d.finish(&delivery, status, response, errMessage, false)
+}
+
+func (d *Dispatcher) send(hook model.Webhook, delivery model.WebhookDelivery) (int, string, error) {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "kerjainaja-webhooks")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.ID.String())
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, timestamp, delivery.Payload))
+
+	res, err := d.Client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(res.Body, responseLimit))
+	return res.StatusCode, strings.ToValidUTF8(string(body), ""), nil
+}
+
+// finish records an attempt. A failed attempt is retried later until
+// MaxAttempts is reached, unless giveUp is set.
+func (d *Dispatcher) finish(delivery *model.WebhookDelivery, status int, response, errMessage string, giveUp bool) error {
+	now := time.Now()
+	delivery.Attempts++
+	delivery.StatusCode = status
+	delivery.Response = response
+	delivery.Error = truncate(errMessage, 255)
+	delivery.NextAttemptAt = nil
+
+	switch {
+	case errMessage == "":
+		delivery.Status = model.WebhookSucceeded
+		delivery.DeliveredAt = &now
+	case giveUp || delivery.Attempts >= d.MaxAttempts:
+		delivery.Status = model.WebhookFailed
+	default:
+		next := now.Add(d.Backoff(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+	}
+
+	return d.DB.Model(delivery).Select("attempts", "status_code", "response", "error", "status", "delivered_at", "next_attempt_at").Updates(delivery).Error
+}
+
+// Backoff is the wait after the given number of failed attempts.
+func (d *Dispatcher) Backoff(attempts int) time.Duration {
+	wait := d.RetryBase << min(attempts-1, 20)
+	return min(wait, d.RetryMax)
+}
+
+// RetryDue attempts every pending delivery whose next attempt is due.
+func (d *Dispatcher) RetryDue() error {
+	var ids []uuid.UUID
+	if err := d.DB.Model(&model.WebhookDelivery{}).
+		Where("status = ? AND next_attempt_at <= ?", model.WebhookPending, time.Now()).
+		Order("next_attempt_at").
+		Limit(100).
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if _, err := d.Deliver(id); err != nil {
+			log.Printf("webhooks: failed to deliver %s: %v", id, err)
+		}
+	}
+
+	return nil
+}
+
+// Run retries due deliveries every interval until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.RetryDue(); err != nil {
+				log.Printf("webhooks: failed to retry deliveries: %v", err)
+			}
+		}
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	return strings.ToValidUTF8(s[:n], "")
+}
@@ -0,0 +1,168 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io"
+	"kerjainaja/model"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type receiver struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	status   int
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	w.WriteHeader(r.status)
+	w.Write([]byte("ok"))
+}
+
+func setup(t *testing.T, status int) (*Dispatcher, *receiver, model.Webhook) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&model.Webhook{}, &model.WebhookDelivery{}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &receiver{status: status}
+	server := httptest.NewServer(rec)
+	t.Cleanup(server.Close)
+
+	hook := model.Webhook{BoardID: uuid.New(), URL: server.URL, Secret: "s3cret", Events: "activity", Active: true}
+	if err := db.Create(&hook).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	d := New(db, NewClient(true))
+	d.RetryBase = time.Millisecond
+	d.MaxAttempts = 3
+
+	return d, rec, hook
+}
+
+func TestDeliverSignsPayload(t *testing.T) {
+	d, rec, hook := setup(t, http.StatusOK)
+
+	deliveries, err := d.Enqueue(hook.BoardID, "board_update", []byte(`{}`))
+	if err != nil || len(deliveries) != 0 {
+		t.Fatalf("unsubscribed event was queued: %v %v", deliveries, err)
+	}
+
+	deliveries, err = d.Enqueue(hook.BoardID, "activity", []byte(`{"action":"created"}`))
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("deliveries = %v, %v", deliveries, err)
+	}
+
+	delivery, err := d.Deliver(deliveries[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if delivery.Status != model.WebhookSucceeded || delivery.Attempts != 1 || delivery.StatusCode != 200 {
+		t.Errorf("delivery = %+v", delivery)
+	}
+
+	req, body := rec.requests[0], rec.bodies[0]
+	timestamp, _ := strconv.ParseInt(req.Header.Get(HeaderTimestamp), 10, 64)
+	if req.Header.Get(HeaderSignature) != Sign("s3cret", timestamp, body) {
+		t.Errorf("signature %q does not match the body", req.Header.Get(HeaderSignature))
+	}
+
+	var payload Payload
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Event != "activity" || string(payload.Data) != `{"action":"created"}` {
+		t.Errorf("payload = %s", body)
+	}
+
+	if req.Header.Get(HeaderDelivery) != delivery.ID.String() {
+		t.Errorf("delivery header = %q", req.Header.Get(HeaderDelivery))
+	}
+}
+
+func TestFailedDeliveryIsRetriedWithBackoff(t *testing.T) {
+	d, rec, hook := setup(t, http.StatusInternalServerError)
+
+	deliveries, _ := d.Enqueue(hook.BoardID, "activity", []byte(`{}`))
+	delivery, err := d.Deliver(deliveries[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if delivery.Status != model.WebhookPending || delivery.NextAttemptAt == nil || delivery.Error == "" {
+		t.Fatalf("delivery after a failure = %+v", delivery)
+	}
+
+	// a delivery that isn't due yet is left alone
+	d.DB.Model(&delivery).Update("next_attempt_at", time.Now().Add(time.Hour))
+	if err := d.RetryDue(); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.requests) != 1 {
+		t.Fatalf("delivery was retried early, %d requests", len(rec.requests))
+	}
+
+	d.DB.Model(&delivery).Update("next_attempt_at", time.Now().Add(-time.Second))
+	for range 3 {
+		if err := d.RetryDue(); err != nil {
+			t.Fatal(err)
+		}
+		d.DB.Model(&model.WebhookDelivery{}).Where("status = ?", model.WebhookPending).Update("next_attempt_at", time.Now().Add(-time.Second))
+	}
+
+	d.DB.First(&delivery, "id = ?", delivery.ID)
+	if delivery.Status != model.WebhookFailed || delivery.Attempts != 3 || len(rec.requests) != 3 {
+		t.Errorf("delivery = %+v after %d requests", delivery, len(rec.requests))
+	}
+
+	rec.status = http.StatusOK
+	again, err := d.Redeliver(delivery)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again.ID == delivery.ID || again.Status != model.WebhookSucceeded || string(rec.bodies[3]) != string(delivery.Payload) {
+		t.Errorf("redelivery = %+v", again)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := New(nil, nil)
+
+	for attempts, want := range map[int]time.Duration{1: 30 * time.Second, 2: time.Minute, 4: 4 * time.Minute, 30: time.Hour} {
+		if got := d.Backoff(attempts); got != want {
+			t.Errorf("Backoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(&receiver{status: http.StatusOK})
+	defer server.Close()
+
+	if _, err := NewClient(false).Get(server.URL); err == nil {
+		t.Error("loopback receiver was reached")
+	}
+
+	if err := ValidateURL("ftp://example.com"); err == nil {
+		t.Error("ftp url is valid")
+	}
+}
@@ -1,17 +1,31 @@
 import { cookies } from "next/headers"
 import { NextResponse } from "next/server"
+import { getSessionToken } from "@/server/serverCookies"
 
 export async function POST() {
     const cookieStore = await cookies();
-    cookieStore.set({
-        name: "kerjainaja_session",
-        value: "",
-        maxAge: -1,
-        path: "/",
-        sameSite: "strict",
-        secure: process.env.NODE_ENV === 'production',
-        httpOnly: process.env.NODE_ENV === 'production',
-    });
+
+    // the refresh token outlives the cookies, so the session is revoked too
+    const token = await getSessionToken();
+    if (token) {
+        await fetch(`${process.env.NEXT_PUBLIC_API_URL}/logout`, {
+            method: "POST",
+            headers: { Authorization: `Bearer ${token}` },
+            cache: "no-store",
+        }).catch(() => null);
+    }
+
+    for (const name of ["kerjainaja_session", "kerjainaja_refresh"]) {
+        cookieStore.set({
+            name,
+            value: "",
+            maxAge: -1,
+            path: "/",
+            sameSite: "strict",
+            secure: process.env.NODE_ENV === 'production',
+            httpOnly: process.env.NODE_ENV === 'production',
+        });
+    }
 
     const response = NextResponse.json({
         data: null,
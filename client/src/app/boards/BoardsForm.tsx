@@ -2,7 +2,7 @@
 
 import Link from "next/link";
 import { useEffect, useState } from "react";
-import { setCookie, getCookie } from "@/server/serverCookies";
+import { setCookie, getSessionToken } from "@/server/serverCookies";
 import { motion } from "framer-motion";
 import { FontAwesomeIcon } from "@fortawesome/react-fontawesome";
 import {
@@ -68,7 +68,7 @@ export default function BoardsForm() {
         setLoading(true);
 
         const headers: Record<string, string> = {};
-        const token = await getCookie("kerjainaja_session");
+        const token = await getSessionToken();
 
         if (token) {
           headers["Authorization"] = `Bearer ${token}`;
@@ -112,7 +112,7 @@ export default function BoardsForm() {
     };
 
     const fetchUser = async () => {
-      const token = await getCookie("kerjainaja_session");
+      const token = await getSessionToken();
 
       if (!token) {
         return;
@@ -158,7 +158,7 @@ export default function BoardsForm() {
   const handleLogout = async () => {
     try {
       setIsLoading(true);
-      const token = await getCookie("kerjainaja_session");
+      const token = await getSessionToken();
       if (!token) {
         toast.error("no token found");
         return;
@@ -208,7 +208,7 @@ export default function BoardsForm() {
 
       const headers: Record<string, string> = {};
 
-      const token = await getCookie("kerjainaja_session");
+      const token = await getSessionToken();
       if (token) {
         headers["Authorization"] = `Bearer ${token}`;
       }
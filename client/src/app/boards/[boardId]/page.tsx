@@ -19,7 +19,7 @@ import {
 } from "@fortawesome/free-solid-svg-icons";
 import { toast } from "react-toastify";
 import "react-toastify/dist/ReactToastify.css";
-import { getCookie } from "@/server/serverCookies";
+import { getSessionToken } from "@/server/serverCookies";
 import { useRouter } from "next/navigation";
 
 type User = {
@@ -341,7 +341,7 @@ export default function BoardPage({
         setIsLoading(true);
         setError(null);
 
-        const token = await getCookie("kerjainaja_session");
+        const token = await getSessionToken();
         const headers = new Headers();
         headers.append("Content-Type", "application/json");
 
@@ -443,7 +443,7 @@ export default function BoardPage({
   // Handler for leave board
   const handleLeaveBoard = async () => {
     try {
-      const token = await getCookie("kerjainaja_session");
+      const token = await getSessionToken();
 
       const headers: Record<string,string> = {
         "Content-Type": "application/json",
@@ -551,7 +551,7 @@ export default function BoardPage({
         };
       });
 
-      const token = await getCookie("kerjainaja_session");
+      const token = await getSessionToken();
       if (!token) {
         toast.error("no token was found");
         return;
@@ -589,7 +589,7 @@ export default function BoardPage({
 
     try {
       setIsLoading(true);
-      const token = await getCookie("kerjainaja_session");
+      const token = await getSessionToken();
 
       const headers: Record<string,string> = {
         "Content-Type": "application/json",
@@ -676,7 +676,7 @@ export default function BoardPage({
         "Content-Type": "application/json",
       };
 
-      const token = await getCookie("kerjainaja_session");
+      const token = await getSessionToken();
       if (token) {
         headers["Authorization"] = `Bearer ${token}`;
       }
@@ -739,7 +739,7 @@ export default function BoardPage({
         "Content-Type": "application/json",
       };
 
-      const token = await getCookie("kerjainaja_session");
+      const token = await getSessionToken();
       if (token) {
         headers["Authorization"] = `Bearer ${token}`;
       }
@@ -787,7 +787,7 @@ export default function BoardPage({
     }
 
     try {
-      const token = await getCookie("kerjainaja_session");
+      const token = await getSessionToken();
       if (!token) {
         toast.error("You need to login first");
         return;
@@ -861,7 +861,7 @@ export default function BoardPage({
         "Content-Type": "application/json",
       };
 
-      const token = await getCookie("kerjainaja_session");
+      const token = await getSessionToken();
       if (token) {
         headers["Authorization"] = `Bearer ${token}`;
       }
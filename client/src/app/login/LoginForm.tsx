@@ -5,7 +5,7 @@ import { useState } from "react";
 import { toast } from "react-toastify";
 import "react-toastify/dist/ReactToastify.css";
 import { FiMail, FiLock, FiEye, FiEyeOff } from "react-icons/fi";
-import { setCookie, getCookie } from "@/server/serverCookies";
+import { setSessionCookies, getSessionToken } from "@/server/serverCookies";
 import Link from "next/link";
 //import { useRouter } from "next/router";
 import { redirect } from "next/navigation";
@@ -30,7 +30,7 @@ export default function LoginForm() {
 
       const headers: Record<string, string> = {};
 
-      const token = await getCookie("kerjainaja_session");
+      const token = await getSessionToken();
 
       if (token) {
         headers["Authorization"] = `Bearer ${token}`;
@@ -80,7 +80,7 @@ export default function LoginForm() {
       });
 
       if (tokenJwt) {
-        setCookie("kerjainaja_session", tokenJwt, {});
+        await setSessionCookies(result.data);
       }
 
       setTimeout(() => {
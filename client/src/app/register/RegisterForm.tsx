@@ -6,7 +6,7 @@ import React from "react";
 import { toast } from "react-toastify";
 import "react-toastify/dist/ReactToastify.css";
 import { FiUser, FiMail, FiLock, FiEye, FiEyeOff } from "react-icons/fi";
-import { setCookie, getCookie } from "@/server/serverCookies";
+import { setSessionCookies, getSessionToken } from "@/server/serverCookies";
 import Link from "next/link";
 
 export default function RegisterForm() {
@@ -29,7 +29,7 @@ export default function RegisterForm() {
       await new Promise((resolve) => setTimeout(resolve, 1500));
 
       const headers: Record<string, string> = {};
-      const token = await getCookie("kerjainaja_session");
+      const token = await getSessionToken();
 
       if (token) {
         headers["Authorization"] = `Bearer ${token}`;
@@ -80,7 +80,7 @@ export default function RegisterForm() {
       });
 
       if (tokenJwt) {
-        setCookie("kerjainaja_session", tokenJwt, {});
+        await setSessionCookies(result.data);
       }
 
       // setTimeout(() => {
@@ -12,7 +12,7 @@ import {
 import { useRouter } from "next/navigation";
 import { toast } from "react-toastify";
 import "react-toastify/dist/ReactToastify.css";
-import { setCookie, getCookie } from "@/server/serverCookies";
+import { setSessionCookies, getSessionToken } from "@/server/serverCookies";
 import React from "react";
 
 type AuthModalProps = {
@@ -54,7 +54,7 @@ export default function AuthModal({
         "Content-Type": "application/json",
       };
 
-      const tokenHeader = await getCookie("kerjainaja_session");
+      const tokenHeader = await getSessionToken();
 
       if (type == "login") {
         
@@ -95,9 +95,7 @@ export default function AuthModal({
           return;
         }
 
-        setCookie("kerjainaja_session", token, {
-          path: "/",
-        });
+        await setSessionCookies(data.data);
 
         toast.success("Success Login");
         await new Promise((resolve) => setTimeout(resolve, 2000));
@@ -28,4 +28,65 @@ export async function setCookie(
         httpOnly: process.env.NODE_ENV === 'production',
         sameSite: options?.sameSite ?? "strict",
     });
-}
\ No newline at end of file
+}
+
+const SESSION_COOKIE = "kerjainaja_session";
+const REFRESH_COOKIE = "kerjainaja_refresh";
+
+type SessionTokens = {
+    token?: string,
+    refresh_token?: string,
+}
+
+// Stores the tokens of a login. Access tokens only live for minutes, the
+// refresh token is what keeps the user logged in.
+export async function setSessionCookies(tokens: SessionTokens) {
+    if (tokens.token) {
+        await setCookie(SESSION_COOKIE, tokens.token, {});
+    }
+
+    if (tokens.refresh_token) {
+        await setCookie(REFRESH_COOKIE, tokens.refresh_token, {
+            maxAge: 60 * 60 * 24 * 30,
+        });
+    }
+}
+
+function expiresSoon(token: string): boolean {
+    try {
+        const payload = JSON.parse(Buffer.from(token.split(".")[1], "base64url").toString());
+        return typeof payload.exp !== "number" || payload.exp * 1000 < Date.now() + 60 * 1000;
+    } catch {
+        return true;
+    }
+}
+
+// Returns the access token to send to the api, refreshing it first when it is
+// about to expire.
+export async function getSessionToken(): Promise<string | undefined> {
+    const token = await getCookie(SESSION_COOKIE);
+    if (token && !expiresSoon(token)) {
+        return token;
+    }
+
+    const refreshToken = await getCookie(REFRESH_COOKIE);
+    if (!refreshToken) {
+        return token;
+    }
+
+    const response = await fetch(`${process.env.NEXT_PUBLIC_API_URL}/refresh`, {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ refresh_token: refreshToken }),
+        cache: "no-store",
+    }).catch(() => null);
+
+    const result = await response?.json().catch(() => null);
+    if (!response?.ok || !result?.data?.token) {
+        return undefined;
+    }
+
+    await setSessionCookies(result.data);
+
+    return result.data.token;
+}